@@ -7,8 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
+	"github.com/muditshukla3/simplebank/authz"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
 	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
 )
 
 type createAccountRequest struct {
@@ -24,13 +26,16 @@ func (server *Server) createAccount(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	arg := db.CreateAccountParams{
-		Owner:    authPayload.Username,
-		Currency: request.Currency,
-		Balance:  0,
+	arg := db.CreateAccountTxParams{
+		CreateAccountParams: db.CreateAccountParams{
+			Owner:    authPayload.Username,
+			Currency: request.Currency,
+			Balance:  0,
+		},
+		Audit: auditRequest(ctx),
 	}
 
-	account, err := server.store.CreateAccount(ctx, arg)
+	account, err := server.store.CreateAccountTx(ctx, arg)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			switch pqErr.Code.Name() {
@@ -47,7 +52,7 @@ func (server *Server) createAccount(ctx *gin.Context) {
 }
 
 type getAccountRequest struct {
-	ID int64 `uri:"id" binding:"required" validate:"min=1"`
+	ID int64 `uri:"id" binding:"required,min=1"`
 }
 
 func (server *Server) getAccount(ctx *gin.Context) {
@@ -69,7 +74,7 @@ func (server *Server) getAccount(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if account.Owner != authPayload.Username {
+	if !authz.CanAccess(authPayload, account.Owner, authz.ViewAnyAccount) {
 		err := errors.New("account doesn't belog to the authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
@@ -78,8 +83,9 @@ func (server *Server) getAccount(ctx *gin.Context) {
 }
 
 type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required" validate:"min=1"`
-	PageSize int32 `form:"page_size" binding:"required" validate:"min=5, max=10"`
+	PageID        int32 `form:"page_id" binding:"required,min=1"`
+	PageSize      int32 `form:"page_size" binding:"required,min=5,max=10"`
+	IncludeClosed bool  `form:"include_closed"`
 }
 
 func (server *Server) listAccounts(ctx *gin.Context) {
@@ -91,9 +97,10 @@ func (server *Server) listAccounts(ctx *gin.Context) {
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
-		Limit:  request.PageSize,
-		Offset: (request.PageID - 1) * request.PageSize,
+		Owner:         authPayload.Username,
+		IncludeClosed: request.IncludeClosed,
+		Limit:         request.PageSize,
+		Offset:        (request.PageID - 1) * request.PageSize,
 	}
 	accounts, err := server.store.ListAccounts(ctx, arg)
 
@@ -108,21 +115,131 @@ func (server *Server) listAccounts(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, accounts)
 }
 
-type deleteAccountRequest struct {
-	ID int64 `uri:"id" binding:"required" validate:"min=1"`
+type closeAccountURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
 }
 
-func (server *Server) deleteAccount(ctx *gin.Context) {
-	var request deleteAccountRequest
-	if err := ctx.ShouldBindUri(&request); err != nil {
+// closeAccountRequest nominates the account that receives the remaining balance, it can be left out for an empty account
+type closeAccountRequest struct {
+	SweepToAccountID int64 `json:"sweep_to_account_id" binding:"omitempty,min=1"`
+}
+
+// closeAccount replaces deleting an account: the entries and transfers are kept and the account moves to the closed status
+func (server *Server) closeAccount(ctx *gin.Context) {
+	var uri closeAccountURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	err := server.store.DeleteAccount(ctx, request.ID)
+	var request closeAccountRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	if request.SweepToAccountID == uri.ID {
+		err := errors.New("cannot sweep an account to itself")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	ctx.JSON(http.StatusOK, "record deleted")
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, account.Owner, authz.OwnerOnly) {
+		err := errors.New("account doesn't belog to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if request.SweepToAccountID != 0 {
+		if _, valid := server.validateAccount(ctx, request.SweepToAccountID, account.Currency); !valid {
+			return
+		}
+	}
+
+	result, err := server.store.CloseAccountTx(ctx, db.CloseAccountTxParams{
+		AccountID:        account.ID,
+		SweepToAccountID: request.SweepToAccountID,
+		Audit:            auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(closeAccountErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+func closeAccountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrAccountNotActive), errors.Is(err, db.ErrAccountNotEmpty), errors.Is(err, db.ErrAccountHasHolds),
+		errors.Is(err, db.ErrAccountHasPendingFundings):
+		return http.StatusUnprocessableEntity
+	default:
+		return transferErrorStatus(err)
+	}
+}
+
+type accountStatusURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type accountStatusRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// freezeAccount is only routed for roles with the FreezeAccount permission
+func (server *Server) freezeAccount(ctx *gin.Context) {
+	server.updateAccountStatus(ctx, util.AccountStatusFrozen)
+}
+
+// unfreezeAccount is only routed for roles with the FreezeAccount permission
+func (server *Server) unfreezeAccount(ctx *gin.Context) {
+	server.updateAccountStatus(ctx, util.AccountStatusActive)
+}
+
+func (server *Server) updateAccountStatus(ctx *gin.Context, status string) {
+	var uri accountStatusURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var request accountStatusRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.UpdateAccountStatusTx(ctx, db.UpdateAccountStatusTxParams{
+		AccountID: uri.ID,
+		Status:    status,
+		Reason:    request.Reason,
+		Audit:     auditRequest(ctx),
+	})
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		case errors.Is(err, db.ErrAccountNotActive), errors.Is(err, db.ErrAccountNotFrozen):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, account)
 }
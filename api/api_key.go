@@ -0,0 +1,215 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const (
+	// apiKeyTag starts every api key so that leaked keys are easy to recognize
+	apiKeyTag = "sb"
+	// apiKeyPrefixBytes is the entropy of the public prefix of a key
+	apiKeyPrefixBytes = 8
+)
+
+// newAPIKey returns a key of the form sb_<prefix>_<secret> and its prefix
+func newAPIKey() (key string, prefix string, err error) {
+	buf := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key prefix: %w", err)
+	}
+	prefix = hex.EncodeToString(buf)
+
+	secret, err := util.NewSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return apiKeyTag + "_" + prefix + "_" + secret, prefix, nil
+}
+
+// apiKeyPrefix returns the prefix of key, if key looks like an api key at all
+func apiKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag || len(parts[1]) != 2*apiKeyPrefixBytes || len(parts[2]) == 0 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// apiKeyResponse describes a key, the key itself is only returned when it is created
+type apiKeyResponse struct {
+	ID                int64         `json:"id"`
+	Name              string        `json:"name"`
+	Prefix            string        `json:"prefix"`
+	Key               string        `json:"key,omitempty"`
+	Scopes            []authz.Scope `json:"scopes"`
+	MaxTransferAmount *int64        `json:"max_transfer_amount"`
+	LastUsedAt        *time.Time    `json:"last_used_at"`
+	ExpiresAt         *time.Time    `json:"expires_at"`
+	RevokedAt         *time.Time    `json:"revoked_at"`
+	CreatedAt         time.Time     `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	response := apiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     make([]authz.Scope, len(apiKey.Scopes)),
+		LastUsedAt: timePtr(apiKey.LastUsedAt),
+		ExpiresAt:  timePtr(apiKey.ExpiresAt),
+		RevokedAt:  timePtr(apiKey.RevokedAt),
+		CreatedAt:  apiKey.CreatedAt,
+	}
+	for i, scope := range apiKey.Scopes {
+		response.Scopes[i] = authz.Scope(scope)
+	}
+	if apiKey.MaxTransferAmount.Valid {
+		response.MaxTransferAmount = &apiKey.MaxTransferAmount.Int64
+	}
+	return response
+}
+
+// timePtr is the inverse of nullTime, for optional times of responses
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+type createAPIKeyRequest struct {
+	Name              string        `json:"name" binding:"required,max=64"`
+	Scopes            []authz.Scope `json:"scopes" binding:"required,min=1"`
+	MaxTransferAmount *int64        `json:"max_transfer_amount" binding:"omitempty,gt=0"`
+	ExpiresAt         *time.Time    `json:"expires_at"`
+}
+
+// createAPIKey issues an api key of the logged-in user for clients that can't log in interactively
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var request createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	scopes := make([]string, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		if !authz.IsAPIKeyScope(scope) {
+			err := fmt.Errorf("api keys can't have the %q scope", scope)
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		// a key can't do more than the token that creates it, or narrowing the scopes at login would be moot
+		if !authz.TokenHasScope(authPayload, scope) {
+			err := fmt.Errorf("the access token doesn't have the %q scope", scope)
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		scopes = append(scopes, string(scope))
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		err := errors.New("expires_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg := db.CreateAPIKeyParams{
+		Name:      request.Name,
+		Scopes:    scopes,
+		ExpiresAt: nullTime(request.ExpiresAt),
+	}
+	if request.MaxTransferAmount != nil {
+		arg.MaxTransferAmount = sql.NullInt64{Int64: *request.MaxTransferAmount, Valid: true}
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg.Username = authPayload.Username
+	arg.Prefix = prefix
+	arg.KeyHash = util.HashSecretToken(key)
+
+	apiKey, err := server.store.CreateAPIKeyTx(ctx, db.CreateAPIKeyTxParams{
+		CreateAPIKeyParams: arg,
+		Audit:              auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := newAPIKeyResponse(apiKey)
+	response.Key = key
+	ctx.JSON(http.StatusOK, response)
+}
+
+type listAPIKeysResponse struct {
+	APIKeys []apiKeyResponse `json:"api_keys"`
+}
+
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	apiKeys, err := server.store.ListAPIKeys(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := listAPIKeysResponse{APIKeys: make([]apiKeyResponse, len(apiKeys))}
+	for i, apiKey := range apiKeys {
+		response.APIKeys[i] = newAPIKeyResponse(apiKey)
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+type revokeAPIKeyRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// revokeAPIKey stops a key of the logged-in user from working, keys of other users are reported as not found
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var request revokeAPIKeyRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	apiKey, err := server.store.RevokeAPIKeyTx(ctx, db.RevokeAPIKeyTxParams{
+		RevokeAPIKeyParams: db.RevokeAPIKeyParams{
+			ID:       request.ID,
+			Username: authPayload.Username,
+		},
+		Audit: auditRequest(ctx),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("api key not found or already revoked")
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAPIKeyResponse(apiKey))
+}
@@ -0,0 +1,504 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/muditshukla3/simplebank/authz"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// randomAPIKey returns a key and the row stored for it
+func randomAPIKey(t *testing.T, username string, scopes ...authz.Scope) (string, db.ApiKey) {
+	key, prefix, err := newAPIKey()
+	require.NoError(t, err)
+
+	apiKey := db.ApiKey{
+		ID:        util.RandomInt(1, 1000),
+		Username:  username,
+		Name:      util.RandomOwner(),
+		Prefix:    prefix,
+		KeyHash:   util.HashSecretToken(key),
+		CreatedAt: time.Now(),
+	}
+	for _, scope := range scopes {
+		apiKey.Scopes = append(apiKey.Scopes, string(scope))
+	}
+	return key, apiKey
+}
+
+func addAPIKeyAuthorization(request *http.Request, key string) {
+	request.Header.Set(authorizationHeaderKey, "ApiKey "+key)
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = util.DepositorRole
+	key, apiKey := randomAPIKey(t, user.Username, authz.ScopeAccountsRead)
+
+	revoked := apiKey
+	revoked.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	expired := apiKey
+	expired.ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+	writeOnly := apiKey
+	writeOnly.Scopes = []string{string(authz.ScopeAccountsWrite)}
+
+	testCases := []struct {
+		name          string
+		key           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(apiKey, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response gin.H
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, user.Username, response["username"])
+				require.Equal(t, user.Role, response["role"])
+			},
+		},
+		{
+			name: "MissingScope",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(writeOnly, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "WrongSecret",
+			key:  key[:len(key)-1] + "x",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(apiKey, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Revoked",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(revoked, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Expired",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(expired, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Malformed",
+			key:  "sb_short_secret",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(authPath,
+				authMiddleware(server.tokenMaker, server.store, server.config.TokenAudience),
+				scopeMiddleware(authz.ScopeAccountsRead),
+				func(ctx *gin.Context) {
+					payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+					ctx.JSON(http.StatusOK, gin.H{"username": payload.Username, "role": payload.Role})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addAPIKeyAuthorization(request, tc.key)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	user, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, user.Username, authz.ScopeAdmin)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"name":                "nightly export",
+				"scopes":              []string{"accounts:read", "transfers:write"},
+				"max_transfer_amount": 100,
+				"expires_at":          time.Now().Add(time.Hour),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKeyTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.CreateAPIKeyTxParams) (db.ApiKey, error) {
+						require.Equal(t, user.Username, txArg.Audit.Actor)
+						arg := txArg.CreateAPIKeyParams
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "nightly export", arg.Name)
+						require.Equal(t, []string{"accounts:read", "transfers:write"}, arg.Scopes)
+						require.Equal(t, sql.NullInt64{Int64: 100, Valid: true}, arg.MaxTransferAmount)
+						require.True(t, arg.ExpiresAt.Valid)
+						require.Len(t, arg.Prefix, 2*apiKeyPrefixBytes)
+
+						return db.ApiKey{
+							ID:                1,
+							Username:          arg.Username,
+							Name:              arg.Name,
+							Prefix:            arg.Prefix,
+							KeyHash:           arg.KeyHash,
+							Scopes:            arg.Scopes,
+							MaxTransferAmount: arg.MaxTransferAmount,
+							ExpiresAt:         arg.ExpiresAt,
+							CreatedAt:         time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response apiKeyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				prefix, ok := apiKeyPrefix(response.Key)
+				require.True(t, ok)
+				require.Equal(t, response.Prefix, prefix)
+				require.Equal(t, int64(100), *response.MaxTransferAmount)
+				require.NotContains(t, recorder.Body.String(), "key_hash")
+			},
+		},
+		{
+			name: "UserScope",
+			body: gin.H{"name": "takeover", "scopes": []string{"user"}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			// a token narrowed at login can't mint a key with more scopes than it has
+			name: "ScopeNotInToken",
+			body: gin.H{"name": "escalation", "scopes": []string{"transfers:write"}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addClaimsAuthorization(t, request, tokenMaker, token.Claims{
+					Username: user.Username,
+					Role:     util.DepositorRole,
+					Duration: time.Minute,
+					Scopes:   []string{string(authz.ScopeUser), string(authz.ScopeAccountsRead)},
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "ScopeInToken",
+			body: gin.H{"name": "dashboard", "scopes": []string{"accounts:read"}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addClaimsAuthorization(t, request, tokenMaker, token.Claims{
+					Username: user.Username,
+					Role:     util.DepositorRole,
+					Duration: time.Minute,
+					Scopes:   []string{string(authz.ScopeUser), string(authz.ScopeAccountsRead)},
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKey{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiresInThePast",
+			body: gin.H{"name": "old", "scopes": []string{"accounts:read"}, "expires_at": time.Now().Add(-time.Hour)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NoScopes",
+			body: gin.H{"name": "nothing", "scopes": []string{}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			// api keys can't mint more api keys, whatever their scopes
+			name: "WithAPIKey",
+			body: gin.H{"name": "child", "scopes": []string{"accounts:read"}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAPIKeyAuthorization(request, key)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(apiKey, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().CreateAPIKeyTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/api_keys", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	user, _ := randomUser(t)
+	_, apiKey := randomAPIKey(t, user.Username, authz.ScopeAccountsRead)
+	revoked := apiKey
+	revoked.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKeyTx(gomock.Any(), EqAudited(db.RevokeAPIKeyTxParams{
+						RevokeAPIKeyParams: db.RevokeAPIKeyParams{ID: apiKey.ID, Username: user.Username},
+					}, user.Username)).
+					Times(1).
+					Return(revoked, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response apiKeyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.NotNil(t, response.RevokedAt)
+				require.Empty(t, response.Key)
+			},
+		},
+		{
+			// also keys of other users
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeAPIKeyTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/api_keys/%d", apiKey.ID)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateTransferAPIKeyCap(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, user.Username, authz.ScopeTransfersWrite)
+	apiKey.MaxTransferAmount = sql.NullInt64{Int64: 100, Valid: true}
+
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount(other.Username)
+	account2.ID = account1.ID + 1
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	testCases := []struct {
+		name          string
+		amount        int64
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "UpToTheCap",
+			amount: 100,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "AboveTheCap",
+			amount: 101,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).Times(1).Return(apiKey, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).Times(1)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          tc.amount,
+				"currency":        util.USD,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAPIKeyAuthorization(request, key)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/muditshukla3/simplebank/audit"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+type eqAuditedMatcher struct {
+	arg   interface{}
+	actor string
+}
+
+// Matches compares the params without their Audit field, which has to name the actor and carry a request id
+func (e eqAuditedMatcher) Matches(x interface{}) bool {
+	if reflect.TypeOf(x) != reflect.TypeOf(e.arg) {
+		return false
+	}
+
+	arg := reflect.New(reflect.TypeOf(x)).Elem()
+	arg.Set(reflect.ValueOf(x))
+	field := arg.FieldByName("Audit")
+	req := field.Interface().(db.AuditRequest)
+	if req.Actor != e.actor || len(req.RequestID) == 0 {
+		return false
+	}
+
+	field.Set(reflect.Zero(field.Type()))
+	return reflect.DeepEqual(e.arg, arg.Interface())
+}
+
+func (e eqAuditedMatcher) String() string {
+	return fmt.Sprintf("matches arg %v audited as %v", e.arg, e.actor)
+}
+
+func EqAudited(arg interface{}, actor string) gomock.Matcher {
+	return eqAuditedMatcher{arg, actor}
+}
+
+func randomAuditEvent(actor string) db.AuditEvent {
+	return db.AuditEvent{
+		ID:         util.RandomInt(1, 1000),
+		Actor:      actor,
+		Action:     db.AuditActionCreateAccount,
+		TargetType: db.AuditTargetAccount,
+		TargetID:   fmt.Sprint(util.RandomInt(1, 1000)),
+		RequestID:  util.RandomString(16),
+		Before:     json.RawMessage("null"),
+		After:      json.RawMessage("{}"),
+		Hash:       util.RandomString(64),
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	banker, _ := randomUser(t)
+	banker.Role = util.BankerRole
+	depositor, _ := randomUser(t)
+
+	n := 5
+	events := make([]db.AuditEvent, n)
+	for i := range events {
+		events[i] = randomAuditEvent(depositor.Username)
+	}
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			query: url.Values{
+				"actor":       {depositor.Username},
+				"target_type": {db.AuditTargetAccount},
+				"page_size":   {fmt.Sprint(n)},
+			},
+			user: banker,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAuditEventsParams{
+					Actor:      nullString(depositor.Username),
+					TargetType: nullString(db.AuditTargetAccount),
+					PageSize:   int32(n),
+				}
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Eq(arg)).Times(1).Return(events, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response listAuditEventsResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Len(t, response.Events, n)
+				require.NotNil(t, response.NextCursor)
+				require.Equal(t, events[n-1].ID, *response.NextCursor)
+			},
+		},
+		{
+			name:  "Depositor",
+			query: url.Values{"page_size": {fmt.Sprint(n)}},
+			user:  depositor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidPageSize",
+			query: url.Values{"page_size": {"100"}},
+			user:  banker,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "FromAfterTo",
+			query: url.Values{
+				"from":      {time.Now().Format(time.RFC3339)},
+				"to":        {time.Now().Add(-time.Hour).Format(time.RFC3339)},
+				"page_size": {fmt.Sprint(n)},
+			},
+			user: banker,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/admin/audit_events?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	event := randomAuditEvent(util.RandomOwner())
+	event.Hash = db.AuditEventHash(event)
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ExecSnapshotTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, fn func(db.Querier) error) error {
+			return fn(store)
+		})
+	store.EXPECT().ListAuditChain(gomock.Any(), gomock.Any()).Times(1).Return([]db.AuditEvent{event}, nil)
+
+	server := NewTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/admin/audit_events/verify", nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationType, util.RandomOwner(), util.BankerRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var report audit.Report
+	err = json.Unmarshal(recorder.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.True(t, report.OK)
+	require.Equal(t, int64(1), report.EventsChecked)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requestID string
+		check     func(t *testing.T, requestID string)
+	}{
+		{
+			name:      "Kept",
+			requestID: "req-123",
+			check: func(t *testing.T, requestID string) {
+				require.Equal(t, "req-123", requestID)
+			},
+		},
+		{
+			name: "Missing",
+			check: func(t *testing.T, requestID string) {
+				require.NotEmpty(t, requestID)
+			},
+		},
+		{
+			name:      "Invalid",
+			requestID: "has spaces",
+			check: func(t *testing.T, requestID string) {
+				require.NotEmpty(t, requestID)
+				require.NotEqual(t, "has spaces", requestID)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			var seen db.AuditRequest
+			router.GET("/audit", requestIDMiddleware(), func(ctx *gin.Context) {
+				seen = auditRequest(ctx)
+				ctx.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/audit", nil)
+			require.NoError(t, err)
+			if len(tc.requestID) > 0 {
+				request.Header.Set(requestIDHeaderKey, tc.requestID)
+			}
+
+			router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			requestID := recorder.Header().Get(requestIDHeaderKey)
+			require.Equal(t, seen.RequestID, requestID)
+			require.Empty(t, seen.Actor)
+			tc.check(t, requestID)
+		})
+	}
+}
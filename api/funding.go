@@ -0,0 +1,208 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/funding"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+type createFundingRequest struct {
+	AccountID int64  `json:"account_id" binding:"required,min=1"`
+	Amount    int64  `json:"amount" binding:"required,gt=0"`
+	Currency  string `json:"currency" binding:"required,currency"`
+}
+
+func (server *Server) createDeposit(ctx *gin.Context) {
+	server.createFunding(ctx, util.FundingKindDeposit)
+}
+
+func (server *Server) createWithdrawal(ctx *gin.Context) {
+	server.createFunding(ctx, util.FundingKindWithdrawal)
+}
+
+// createFunding records a pending funding and hands it to the funding provider.
+// If the provider rejects it the funding is failed right away, which releases a withdrawn amount
+func (server *Server) createFunding(ctx *gin.Context, kind string) {
+	var request createFundingRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if server.fundingProvider == nil {
+		err := errors.New("deposits and withdrawals are not enabled")
+		ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+		return
+	}
+
+	account, valid := server.validateAccount(ctx, request.AccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, account.Owner, authz.OwnerOnly) {
+		err := errors.New("account doesn't belog to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	// money leaving the bank gets the same safeguards as a transfer of the amount
+	if kind == util.FundingKindWithdrawal && !server.checkTransferAmount(ctx, account.Owner, request.Amount) {
+		return
+	}
+
+	result, err := server.store.CreateFundingTx(ctx, db.CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      kind,
+		Amount:    request.Amount,
+		Audit:     auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(transferErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	reference, err := server.fundingProvider.Initiate(ctx, funding.Request{
+		FundingID: result.Funding.ID,
+		Kind:      kind,
+		AccountID: account.ID,
+		Amount:    request.Amount,
+		Currency:  account.Currency,
+	})
+	if err != nil {
+		_, failErr := server.store.CompleteFundingTx(ctx, db.CompleteFundingTxParams{
+			ID:            result.Funding.ID,
+			Status:        util.FundingStatusFailed,
+			FailureReason: err.Error(),
+			Audit:         auditRequest(ctx),
+		})
+		if failErr != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(failErr))
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, errorResponse(err))
+		return
+	}
+
+	result.Funding, err = server.store.SetFundingReference(ctx, db.SetFundingReferenceParams{
+		ID:                result.Funding.ID,
+		ProviderReference: reference,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+type fundingIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getFunding(ctx *gin.Context) {
+	var request fundingIDRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fundingRecord, account, valid := server.authorizedFunding(ctx, request.ID, authz.ViewAnyAccount)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, db.FundingTxResult{Funding: fundingRecord, Account: account})
+}
+
+// confirmFunding asks the provider for the outcome of a pending funding and books it.
+// The outcome is never taken from the client, and a funding that is still pending or already completed
+// is returned unchanged
+func (server *Server) confirmFunding(ctx *gin.Context) {
+	var request fundingIDRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if server.fundingProvider == nil {
+		err := errors.New("deposits and withdrawals are not enabled")
+		ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+		return
+	}
+
+	fundingRecord, account, valid := server.authorizedFunding(ctx, request.ID, authz.OwnerOnly)
+	if !valid {
+		return
+	}
+
+	if fundingRecord.Status != util.FundingStatusPending {
+		ctx.JSON(http.StatusOK, db.FundingTxResult{Funding: fundingRecord, Account: account})
+		return
+	}
+
+	outcome, err := server.fundingProvider.Outcome(ctx, fundingRecord.ProviderReference)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errorResponse(err))
+		return
+	}
+
+	if outcome.Status == util.FundingStatusPending {
+		ctx.JSON(http.StatusOK, db.FundingTxResult{Funding: fundingRecord, Account: account})
+		return
+	}
+
+	result, err := server.store.CompleteFundingTx(ctx, db.CompleteFundingTxParams{
+		ID:            fundingRecord.ID,
+		Status:        outcome.Status,
+		FailureReason: outcome.FailureReason,
+		Audit:         auditRequest(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrFundingNotPending) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// authorizedFunding loads a funding and its account if the authenticated user owns the account
+// or has permission on accounts of other users
+func (server *Server) authorizedFunding(ctx *gin.Context, id int64, permission authz.Permission) (db.Funding, db.Account, bool) {
+	fundingRecord, err := server.store.GetFunding(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return fundingRecord, db.Account{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return fundingRecord, db.Account{}, false
+	}
+
+	account, err := server.store.GetAccount(ctx, fundingRecord.AccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return fundingRecord, account, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, account.Owner, permission) {
+		err := errors.New("account doesn't belog to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return fundingRecord, account, false
+	}
+
+	return fundingRecord, account, true
+}
@@ -0,0 +1,447 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/funding"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// unavailableProvider rejects every funding, like a provider that is down
+type unavailableProvider struct {
+	funding.Provider
+}
+
+func (unavailableProvider) Initiate(ctx context.Context, request funding.Request) (string, error) {
+	return "", errors.New("provider unavailable")
+}
+
+func randomFunding(account db.Account, kind string) db.Funding {
+	return db.Funding{
+		ID:        util.RandomInt(1, 1000),
+		AccountID: account.ID,
+		Kind:      kind,
+		Amount:    util.RandomAmount(),
+		Status:    util.FundingStatusPending,
+	}
+}
+
+func TestCreateFunding(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	account.Currency = util.USD
+
+	deposit := randomFunding(account, util.FundingKindDeposit)
+	withdrawal := randomFunding(account, util.FundingKindWithdrawal)
+
+	testCases := []struct {
+		name          string
+		path          string
+		funding       db.Funding
+		username      string
+		provider      funding.Provider
+		transferLimit int64 // UnverifiedTransferLimit, the user's email isn't verified
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Deposit",
+			path:     "/deposits",
+			funding:  deposit,
+			username: user.Username,
+			provider: funding.NewFakeProvider(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.CreateFundingTxParams{
+					AccountID: account.ID,
+					Kind:      util.FundingKindDeposit,
+					Amount:    deposit.Amount,
+				}
+				store.EXPECT().
+					CreateFundingTx(gomock.Any(), EqAudited(arg, user.Username)).
+					Times(1).
+					Return(db.FundingTxResult{Funding: deposit, Account: account}, nil)
+
+				referenced := deposit
+				referenced.ProviderReference = fmt.Sprintf("fake-deposit-%d", deposit.ID)
+				store.EXPECT().
+					SetFundingReference(gomock.Any(), gomock.Eq(db.SetFundingReferenceParams{
+						ID:                deposit.ID,
+						ProviderReference: referenced.ProviderReference,
+					})).
+					Times(1).
+					Return(referenced, nil)
+				store.EXPECT().CompleteFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var result db.FundingTxResult
+				err := json.Unmarshal(recorder.Body.Bytes(), &result)
+				require.NoError(t, err)
+				require.Equal(t, deposit.ID, result.Funding.ID)
+				require.NotEmpty(t, result.Funding.ProviderReference)
+			},
+		},
+		{
+			name:     "Withdrawal",
+			path:     "/withdrawals",
+			funding:  withdrawal,
+			username: user.Username,
+			provider: funding.NewFakeProvider(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.CreateFundingTxParams{
+					AccountID: account.ID,
+					Kind:      util.FundingKindWithdrawal,
+					Amount:    withdrawal.Amount,
+				}
+				store.EXPECT().
+					CreateFundingTx(gomock.Any(), EqAudited(arg, user.Username)).
+					Times(1).
+					Return(db.FundingTxResult{Funding: withdrawal, Account: account}, nil)
+				store.EXPECT().SetFundingReference(gomock.Any(), gomock.Any()).Times(1).Return(withdrawal, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "InsufficientFunds",
+			path:     "/withdrawals",
+			funding:  withdrawal,
+			username: user.Username,
+			provider: funding.NewFakeProvider(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					CreateFundingTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.FundingTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().SetFundingReference(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "ProviderError",
+			path:     "/withdrawals",
+			funding:  withdrawal,
+			username: user.Username,
+			provider: unavailableProvider{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					CreateFundingTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.FundingTxResult{Funding: withdrawal, Account: account}, nil)
+				arg := db.CompleteFundingTxParams{
+					ID:            withdrawal.ID,
+					Status:        util.FundingStatusFailed,
+					FailureReason: "provider unavailable",
+				}
+				store.EXPECT().CompleteFundingTx(gomock.Any(), EqAudited(arg, user.Username)).Times(1).Return(db.FundingTxResult{}, nil)
+				store.EXPECT().SetFundingReference(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadGateway, recorder.Code)
+			},
+		},
+		{
+			name:          "WithdrawalOverUnverifiedLimit",
+			path:          "/withdrawals",
+			funding:       withdrawal,
+			username:      user.Username,
+			provider:      funding.NewFakeProvider(),
+			transferLimit: withdrawal.Amount - 1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			// deposits bring money in and aren't limited
+			name:          "DepositOverUnverifiedLimit",
+			path:          "/deposits",
+			funding:       deposit,
+			username:      user.Username,
+			provider:      funding.NewFakeProvider(),
+			transferLimit: deposit.Amount - 1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateFundingTx(gomock.Any(), gomock.Any()).Times(1).Return(db.FundingTxResult{Funding: deposit, Account: account}, nil)
+				store.EXPECT().SetFundingReference(gomock.Any(), gomock.Any()).Times(1).Return(deposit, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			path:     "/deposits",
+			funding:  deposit,
+			username: "unauthorized_user",
+			provider: funding.NewFakeProvider(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CreateFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "AccountNotFound",
+			path:     "/deposits",
+			funding:  deposit,
+			username: user.Username,
+			provider: funding.NewFakeProvider(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().CreateFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "Disabled",
+			path:     "/deposits",
+			funding:  deposit,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.fundingProvider = tc.provider
+			server.config.UnverifiedTransferLimit = tc.transferLimit
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"account_id": account.ID,
+				"amount":     tc.funding.Amount,
+				"currency":   util.USD,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmFunding(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	deposit := randomFunding(account, util.FundingKindDeposit)
+
+	settled := deposit
+	settled.Status = util.FundingStatusSettled
+
+	credited := account
+	credited.Balance += deposit.Amount
+
+	testCases := []struct {
+		name          string
+		username      string
+		outcome       func(provider *funding.FakeProvider)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Settled",
+			username: user.Username,
+			outcome: func(provider *funding.FakeProvider) {
+				require.NoError(t, provider.Settle(deposit.ProviderReference))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(deposit, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.CompleteFundingTxParams{
+					ID:     deposit.ID,
+					Status: util.FundingStatusSettled,
+				}
+				store.EXPECT().
+					CompleteFundingTx(gomock.Any(), EqAudited(arg, user.Username)).
+					Times(1).
+					Return(db.FundingTxResult{Funding: settled, Account: credited}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var result db.FundingTxResult
+				err := json.Unmarshal(recorder.Body.Bytes(), &result)
+				require.NoError(t, err)
+				require.Equal(t, util.FundingStatusSettled, result.Funding.Status)
+				require.Equal(t, credited.Balance, result.Account.Balance)
+			},
+		},
+		{
+			name:     "Failed",
+			username: user.Username,
+			outcome: func(provider *funding.FakeProvider) {
+				require.NoError(t, provider.Fail(deposit.ProviderReference, "card declined"))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(deposit, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				arg := db.CompleteFundingTxParams{
+					ID:            deposit.ID,
+					Status:        util.FundingStatusFailed,
+					FailureReason: "card declined",
+				}
+				store.EXPECT().CompleteFundingTx(gomock.Any(), EqAudited(arg, user.Username)).Times(1).Return(db.FundingTxResult{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "StillPending",
+			username: user.Username,
+			outcome:  func(provider *funding.FakeProvider) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(deposit, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CompleteFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var result db.FundingTxResult
+				err := json.Unmarshal(recorder.Body.Bytes(), &result)
+				require.NoError(t, err)
+				require.Equal(t, util.FundingStatusPending, result.Funding.Status)
+			},
+		},
+		{
+			name:     "AlreadyCompleted",
+			username: user.Username,
+			outcome:  func(provider *funding.FakeProvider) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(settled, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(credited, nil)
+				store.EXPECT().CompleteFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "ConcurrentConfirm",
+			username: user.Username,
+			outcome: func(provider *funding.FakeProvider) {
+				require.NoError(t, provider.Settle(deposit.ProviderReference))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(deposit, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					CompleteFundingTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.FundingTxResult{}, db.ErrFundingNotPending)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			username: "unauthorized_user",
+			outcome:  func(provider *funding.FakeProvider) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(deposit, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CompleteFundingTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			outcome:  func(provider *funding.FakeProvider) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetFunding(gomock.Any(), gomock.Eq(deposit.ID)).Times(1).Return(db.Funding{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// the fake provider hands out references of its own, so the funding is stubbed with the one it returned
+			provider := funding.NewFakeProvider()
+			reference, err := provider.Initiate(context.Background(), funding.Request{FundingID: deposit.ID, Kind: deposit.Kind})
+			require.NoError(t, err)
+			deposit.ProviderReference = reference
+			tc.outcome(provider)
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.fundingProvider = provider
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/fundings/%d/confirm", deposit.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
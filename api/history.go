@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+)
+
+type accountHistoryURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// accountHistoryQuery holds the filters shared by the entries and transfers history.
+// Pages are returned newest first, and cursor is the id of the last item of the previous page.
+type accountHistoryQuery struct {
+	From      *time.Time `form:"from"`
+	To        *time.Time `form:"to"`
+	Direction string     `form:"direction" binding:"omitempty,oneof=incoming outgoing"`
+	Cursor    int64      `form:"cursor" binding:"omitempty,min=1"`
+	PageSize  int32      `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+type listAccountEntriesResponse struct {
+	Entries    []db.Entry `json:"entries"`
+	NextCursor *int64     `json:"next_cursor"`
+}
+
+func (server *Server) listAccountEntries(ctx *gin.Context) {
+	account, query, ok := server.bindAccountHistory(ctx)
+	if !ok {
+		return
+	}
+
+	entries, err := server.store.ListAccountEntries(ctx, db.ListAccountEntriesParams{
+		AccountID: account.ID,
+		FromTime:  nullTime(query.From),
+		ToTime:    nullTime(query.To),
+		Direction: nullString(query.Direction),
+		Cursor:    nullInt64(query.Cursor),
+		PageSize:  query.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := listAccountEntriesResponse{Entries: entries}
+	if len(entries) == int(query.PageSize) {
+		response.NextCursor = &entries[len(entries)-1].ID
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+type listAccountTransfersResponse struct {
+	Transfers  []db.Transfer `json:"transfers"`
+	NextCursor *int64        `json:"next_cursor"`
+}
+
+func (server *Server) listAccountTransfers(ctx *gin.Context) {
+	account, query, ok := server.bindAccountHistory(ctx)
+	if !ok {
+		return
+	}
+
+	transfers, err := server.store.ListAccountTransfers(ctx, db.ListAccountTransfersParams{
+		AccountID: account.ID,
+		FromTime:  nullTime(query.From),
+		ToTime:    nullTime(query.To),
+		Direction: nullString(query.Direction),
+		Cursor:    nullInt64(query.Cursor),
+		PageSize:  query.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := listAccountTransfersResponse{Transfers: transfers}
+	if len(transfers) == int(query.PageSize) {
+		response.NextCursor = &transfers[len(transfers)-1].ID
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// bindAccountHistory parses the history request and loads the account,
+// making sure the authenticated user owns it or may view any account
+func (server *Server) bindAccountHistory(ctx *gin.Context) (db.Account, accountHistoryQuery, bool) {
+	var uri accountHistoryURI
+	var query accountHistoryQuery
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Account{}, query, false
+	}
+
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Account{}, query, false
+	}
+
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		err := errors.New("from must be before to")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Account{}, query, false
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return account, query, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return account, query, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, account.Owner, authz.ViewAnyAccount) {
+		err := errors.New("account doesn't belog to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return account, query, false
+	}
+
+	return account, query, true
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: len(s) > 0}
+}
+
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
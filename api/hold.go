@@ -0,0 +1,257 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+)
+
+type placeHoldRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	// ExpiresAt defaults to HoldDuration from now, which is also the latest it may be
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// placeHold reserves money on an account of the authenticated user for a later capture into ToAccountID.
+// The large transfer safeguards apply when the hold is placed, since the capture can't move more than the hold
+func (server *Server) placeHold(ctx *gin.Context) {
+	var request placeHoldRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(server.config.HoldDuration)
+	if request.ExpiresAt != nil {
+		if !request.ExpiresAt.After(now) || request.ExpiresAt.After(expiresAt) {
+			err := errors.New("expires_at must be in the future and within the hold duration")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		expiresAt = *request.ExpiresAt
+	}
+
+	fromAccount, valid := server.validateAccount(ctx, request.FromAccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, fromAccount.Owner, authz.OwnerOnly) {
+		err := errors.New("from account doesn't below to authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if _, valid := server.validateAccount(ctx, request.ToAccountID, request.Currency); !valid {
+		return
+	}
+
+	if !server.checkTransferAmount(ctx, fromAccount.Owner, request.Amount) {
+		return
+	}
+
+	result, err := server.store.PlaceHoldTx(ctx, db.PlaceHoldTxParams{
+		AccountID:   request.FromAccountID,
+		ToAccountID: request.ToAccountID,
+		Amount:      request.Amount,
+		ExpiresAt:   expiresAt,
+		Audit:       auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(transferErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+type holdIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getHold(ctx *gin.Context) {
+	var request holdIDRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// both parties may read a hold, each sees it with their own account so the payee doesn't learn the payer's balance
+	hold, account, valid := server.authorizedHold(ctx, request.ID, authz.ViewAnyAccount, holdPayer, holdPayee)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, db.HoldTxResult{Hold: hold, Account: account})
+}
+
+type captureHoldRequest struct {
+	// Amount defaults to the whole hold, the rest of a partial capture is released
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+// captureHold moves a hold into its destination account, the owner of that account takes the money they were promised
+func (server *Server) captureHold(ctx *gin.Context) {
+	var uri holdIDRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var request captureHoldRequest
+	// the body is optional, an empty one captures the whole hold
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	if _, _, valid := server.authorizedHold(ctx, uri.ID, authz.OwnerOnly, holdPayee); !valid {
+		return
+	}
+
+	result, err := server.store.CaptureHoldTx(ctx, db.CaptureHoldTxParams{
+		HoldID: uri.ID,
+		Amount: request.Amount,
+		Audit:  auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(holdErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// releaseHold gives up a hold on behalf of the owner of its destination account. The payer can't take back
+// the money before the hold expires
+func (server *Server) releaseHold(ctx *gin.Context) {
+	var request holdIDRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, _, valid := server.authorizedHold(ctx, request.ID, authz.OwnerOnly, holdPayee); !valid {
+		return
+	}
+
+	result, err := server.store.ReleaseHoldTx(ctx, db.ReleaseHoldTxParams{
+		HoldID: request.ID,
+		Audit:  auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(holdErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+type listAccountHoldsQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=active captured released expired"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+func (server *Server) listAccountHolds(ctx *gin.Context) {
+	var uri accountHistoryURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var query listAccountHoldsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, account.Owner, authz.ViewAnyAccount) {
+		err := errors.New("account doesn't belog to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	holds, err := server.store.ListAccountHolds(ctx, db.ListAccountHoldsParams{
+		AccountID: account.ID,
+		Status:    nullString(query.Status),
+		Limit:     query.PageSize,
+		Offset:    (query.PageID - 1) * query.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, holds)
+}
+
+// holdPayer and holdPayee pick the account of a hold whose owner is authorized
+func holdPayer(hold db.Hold) int64 { return hold.AccountID }
+func holdPayee(hold db.Hold) int64 { return hold.ToAccountID }
+
+// authorizedHold loads a hold and the first account picked by parties that the authenticated user owns,
+// or that they may access with permission on accounts of other users
+func (server *Server) authorizedHold(ctx *gin.Context, id int64, permission authz.Permission, parties ...func(db.Hold) int64) (db.Hold, db.Account, bool) {
+	hold, err := server.store.GetHold(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return hold, db.Account{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return hold, db.Account{}, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	for _, party := range parties {
+		account, err := server.store.GetAccount(ctx, party(hold))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return hold, account, false
+		}
+
+		if authz.CanAccess(authPayload, account.Owner, permission) {
+			return hold, account, true
+		}
+	}
+
+	err = errors.New("account doesn't belog to the authenticated user")
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	return hold, db.Account{}, false
+}
+
+// holdErrorStatus maps the errors of capturing or releasing a hold to a response status
+func holdErrorStatus(err error) int {
+	switch {
+	case err == sql.ErrNoRows:
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrHoldNotActive), errors.Is(err, db.ErrCaptureExceedsHold):
+		return http.StatusUnprocessableEntity
+	}
+	return transferErrorStatus(err)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/token"
+)
+
+// jwksCacheControl lets verifiers cache the keys for a while instead of fetching them for every token
+const jwksCacheControl = "public, max-age=300"
+
+// getJWKS publishes the public keys that verify our tokens, so other services can check access tokens
+// without being able to mint them. It only exists when tokens are signed with a private key
+func (server *Server) getJWKS(ctx *gin.Context) {
+	publisher, ok := server.tokenMaker.(token.KeyPublisher)
+	if !ok {
+		err := errors.New("tokens are not signed with public keys")
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+
+	ctx.Header("Cache-Control", jwksCacheControl)
+	ctx.JSON(http.StatusOK, publisher.PublicKeys())
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJWKS(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicMaker, err := token.NewPasetoPublicMaker(privateKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		tokenMaker    token.Maker
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			tokenMaker: publicMaker,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Cache-Control"))
+
+				var jwks token.JWKSet
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &jwks))
+				require.Equal(t, publicMaker.(token.KeyPublisher).PublicKeys(), jwks)
+				require.Len(t, jwks.Keys, 1)
+				require.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+			},
+		},
+		{
+			name: "SymmetricTokens",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := NewTestServer(t, mockdb.NewMockStore(ctrl))
+			if tc.tokenMaker != nil {
+				server.tokenMaker = tc.tokenMaker
+			}
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestNewServerTokenType(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "token.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := util.Config{
+		TokenType:           token.JWTPublicType,
+		TokenPrivateKeyFile: keyFile,
+	}
+	server, err := NewServer(config, mockdb.NewMockStore(ctrl), nil)
+	require.NoError(t, err)
+
+	accessToken, _, err := server.tokenMaker.CreateToken(token.Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+	_, err = server.tokenMaker.VerifyToken(accessToken)
+	require.NoError(t, err)
+
+	config.TokenPrivateKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = NewServer(config, mockdb.NewMockStore(ctrl), nil)
+	require.Error(t, err)
+}
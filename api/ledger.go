@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/ledger"
+)
+
+// verifyLedger is only routed for roles with the VerifyLedger permission.
+// Discrepancies are part of a successful report, so the status stays 200 whether or not the ledger is consistent.
+func (server *Server) verifyLedger(ctx *gin.Context) {
+	report, err := ledger.Verify(ctx, server.store)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
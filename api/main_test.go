@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
 	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,10 +16,12 @@ func NewTestServer(t *testing.T, store db.Store) *Server {
 
 	config := util.Config{
 		TokenSymmetricKey:   "UcRefYQrNjcOdpstFsBNFq2yOz9gxThc",
+		TokenAudience:       "simplebank",
 		AccessTokenDuration: time.Minute,
+		HoldDuration:        time.Hour,
 	}
 
-	server, err := NewServer(config, store)
+	server, err := NewServer(config, store, worker.NewPostgresTaskDistributor())
 	require.NoError(t, err)
 	return server
 
@@ -1,22 +1,65 @@
 package api
 
 import (
+	"crypto/subtle"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
 	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationType       = "bearer"
+	authorizationTypeAPIKey = "apikey"
 	authorizationPayloadKey = "authorization_payload"
+	// authorizationAPIKeyKey holds the api key a request was authorized with
+	authorizationAPIKeyKey = "authorization_api_key"
+	requestIDHeaderKey     = "X-Request-ID"
+	requestIDKey           = "request_id"
+	// maxRequestIDLength bounds request ids chosen by clients, longer ones are replaced
+	maxRequestIDLength = 128
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// requestIDMiddleware keeps the X-Request-ID of the request, or makes one up if it is missing or unusable,
+// and echoes it in the response so clients can find their request in the audit log
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// authMiddleware verifies the bearer token or api key of the request. Access tokens must be meant for audience
+// and are rejected once their session has been blocked
+func authMiddleware(tokenMaker token.Maker, store db.Store, audience string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
@@ -32,21 +75,139 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
-		authType := strings.ToLower(fields[0])
-		if authorizationType != authType {
-			err := fmt.Errorf("unsupported authorizaton type %s", authorizationType)
+		switch authType := strings.ToLower(fields[0]); authType {
+		case authorizationType:
+			authorizeAccessToken(ctx, tokenMaker, store, audience, fields[1])
+		case authorizationTypeAPIKey:
+			authorizeAPIKey(ctx, store, fields[1])
+		default:
+			err := fmt.Errorf("unsupported authorizaton type %s", authType)
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
 		}
+	}
+}
+
+func authorizeAccessToken(ctx *gin.Context, tokenMaker token.Maker, store db.Store, audience string, accessToken string) {
+	payload, err := tokenMaker.VerifyToken(accessToken)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
 
-		accessToken := fields[1]
-		payload, err := tokenMaker.VerifyToken(accessToken)
+	// refresh tokens have their own audience, so they don't pass as access tokens either
+	if !payload.HasAudience(audience) {
+		err := fmt.Errorf("token is not meant for %s", audience)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if payload.SessionID != uuid.Nil {
+		session, err := store.GetSession(ctx, payload.SessionID)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if session.IsBlocked {
+			err := errors.New("blocked session")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+	}
+
+	ctx.Set(authorizationPayloadKey, payload)
+	ctx.Next()
+}
+
+// authorizeAPIKey puts a payload for the owner of the api key into the context, as if they had logged in
+// with the scopes of the key
+func authorizeAPIKey(ctx *gin.Context, store db.Store, key string) {
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		err := errors.New("invalid api key")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	apiKey, err := store.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("invalid api key")
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(util.HashSecretToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		err := errors.New("invalid api key")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if apiKey.RevokedAt.Valid {
+		err := errors.New("revoked api key")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if apiKey.ExpiresAt.Valid && time.Now().After(apiKey.ExpiresAt.Time) {
+		err := errors.New("expired api key")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	user, err := store.GetUser(ctx, apiKey.Username)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	payload := &token.Payload{
+		Username:  user.Username,
+		Role:      user.Role,
+		Scopes:    apiKey.Scopes,
+		IssuedAt:  apiKey.CreatedAt,
+		ExpiredAt: apiKey.ExpiresAt.Time,
+	}
+
+	ctx.Set(authorizationPayloadKey, payload)
+	ctx.Set(authorizationAPIKeyKey, apiKey)
+	ctx.Next()
+}
+
+// permissionMiddleware must run after authMiddleware, it rejects roles without permission
+func permissionMiddleware(permission authz.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !authz.HasPermission(authPayload.Role, permission) {
+			err := fmt.Errorf("role %s is not allowed to %s", authPayload.Role, permission)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// scopeMiddleware must run after authMiddleware, it rejects tokens and api keys without scope
+func scopeMiddleware(scope authz.Scope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !authz.TokenHasScope(authPayload, scope) {
+			err := fmt.Errorf("credentials lack the %s scope", scope)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
 
-		ctx.Set(authorizationPayloadKey, payload)
 		ctx.Next()
 	}
 }
@@ -1,14 +1,22 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/authz"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
 	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,24 +26,99 @@ func addAuthorization(
 	tokenMaker token.Maker,
 	authorizationType string,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
-	token, payload, err := tokenMaker.CreateToken(username, duration)
+	token, payload, err := tokenMaker.CreateToken(token.Claims{Username: username, Role: role, Duration: duration})
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
 }
+
+// addSessionAuthorization adds an access token tied to the session sessionID, like the ones issued at login
+func addSessionAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	username string,
+	sessionID uuid.UUID,
+	duration time.Duration,
+) {
+	token, payload, err := tokenMaker.CreateToken(token.Claims{Username: username, Role: util.DepositorRole, SessionID: sessionID, Duration: duration})
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
+	request.Header.Set(authorizationHeaderKey, authorizationHeader)
+}
+
+// addClaimsAuthorization adds an access token that makes claims, like the scoped ones issued at login
+func addClaimsAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, claims token.Claims) {
+	token, payload, err := tokenMaker.CreateToken(claims)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
+	request.Header.Set(authorizationHeaderKey, authorizationHeader)
+}
+
 func TestAuthMiddleware(t *testing.T) {
+	session := db.Session{
+		ID:       uuid.New(),
+		Username: "user",
+		FamilyID: uuid.New(),
+	}
+	blockedSession := session
+	blockedSession.IsBlocked = true
+
 	testCases := []struct {
 		name          string
 		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
+		{
+			name: "SessionOK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addSessionAuthorization(t, request, tokenMaker, "user", session.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "BlockedSession",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addSessionAuthorization(t, request, tokenMaker, "user", session.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(blockedSession, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "SessionNotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addSessionAuthorization(t, request, tokenMaker, "user", session.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "OK",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -45,6 +128,9 @@ func TestAuthMiddleware(t *testing.T) {
 			name: "NoAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
@@ -52,7 +138,10 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "UnSupportedAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, "digest", "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, "digest", "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -61,7 +150,10 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "InvalidAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, "", "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, "", "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -70,7 +162,10 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "InvalidToken",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", -time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, -time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -82,12 +177,18 @@ func TestAuthMiddleware(t *testing.T) {
 		tc := testCases[i]
 
 		t.Run(tc.name, func(t *testing.T) {
-			server := NewTestServer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
 
 			authPath := "/auth"
 
 			server.router.GET(authPath,
-				authMiddleware(server.tokenMaker), func(ctx *gin.Context) {
+				authMiddleware(server.tokenMaker, server.store, server.config.TokenAudience), func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
 			)
@@ -103,3 +204,126 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestScopeMiddleware(t *testing.T) {
+	claims := token.Claims{Username: "user", Role: util.DepositorRole, Duration: time.Minute}
+
+	testCases := []struct {
+		name          string
+		scopes        []string
+		audience      string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			scopes:   []string{"accounts:read", "transfers:write"},
+			audience: "simplebank",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			// tokens from before scopes and audiences
+			name: "NoScopes",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "MissingScope",
+			scopes:   []string{"accounts:read"},
+			audience: "simplebank",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherAudience",
+			scopes:   []string{"transfers:write"},
+			audience: "another-service",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "RefreshToken",
+			scopes:   []string{"transfers:write"},
+			audience: token.RefreshAudience("simplebank"),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := NewTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(authPath,
+				authMiddleware(server.tokenMaker, server.store, server.config.TokenAudience),
+				scopeMiddleware(authz.ScopeTransfersWrite),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			claims := claims
+			claims.Scopes = tc.scopes
+			claims.Audience = tc.audience
+			addClaimsAuthorization(t, request, server.tokenMaker, claims)
+			server.router.ServeHTTP(recorder, request)
+
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReadOnlyToken(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+	server := NewTestServer(t, store)
+	claims := token.Claims{
+		Username: user.Username,
+		Role:     util.DepositorRole,
+		Scopes:   []string{string(authz.ScopeAccountsRead)},
+		Audience: server.config.TokenAudience,
+		Duration: time.Minute,
+	}
+
+	// the dashboard can read the account
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	addClaimsAuthorization(t, request, server.tokenMaker, claims)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// but it can't move money
+	recorder = httptest.NewRecorder()
+	body := strings.NewReader(fmt.Sprintf(`{"from_account_id":%d,"to_account_id":%d,"amount":10,"currency":"%s"}`,
+		account.ID, account.ID+1, account.Currency))
+	request, err = http.NewRequest(http.MethodPost, "/transfers", body)
+	require.NoError(t, err)
+	addClaimsAuthorization(t, request, server.tokenMaker, claims)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
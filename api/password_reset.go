@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// forgotPassword mails a reset link if a user has the email. The response is the same either way,
+// the task is even enqueued for unknown emails, so that it doesn't tell which emails are registered
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var request forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload := &worker.PayloadSendResetPassword{Email: request.Email}
+	if err := server.taskDistributor.DistributeTaskSendResetPassword(ctx, server.store, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a password reset link has been sent to it"})
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// resetPassword sets a new password with the token of a reset link, which logs the user out of every session
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var request resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(request.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash: util.HashSecretToken(request.Token),
+		Password:  hashedPassword,
+		Audit:     auditRequest(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPasswordReset) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
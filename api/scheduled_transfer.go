@@ -0,0 +1,246 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+type createScheduledTransferRequest struct {
+	FromAccountID int64     `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64     `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64     `json:"amount" binding:"required,gt=0"`
+	Currency      string    `json:"currency" binding:"required,currency"`
+	Frequency     string    `json:"frequency" binding:"required,oneof=once daily weekly monthly"`
+	StartAt       time.Time `json:"start_at" binding:"required"`
+}
+
+// createScheduledTransfer sets up a standing order, the scheduler worker executes it from start_at on
+func (server *Server) createScheduledTransfer(ctx *gin.Context) {
+	var request createScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !request.StartAt.After(time.Now()) {
+		err := errors.New("start_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fromAccount, valid := server.validateAccount(ctx, request.FromAccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, fromAccount.Owner, authz.OwnerOnly) {
+		err := errors.New("from account doesn't below to authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if _, valid := server.validateAccount(ctx, request.ToAccountID, request.Currency); !valid {
+		return
+	}
+
+	if !server.checkTransferAmount(ctx, fromAccount.Owner, request.Amount) {
+		return
+	}
+
+	schedule, err := server.store.CreateScheduledTransferTx(ctx, db.CreateScheduledTransferTxParams{
+		CreateScheduledTransferParams: db.CreateScheduledTransferParams{
+			Owner:         authPayload.Username,
+			FromAccountID: request.FromAccountID,
+			ToAccountID:   request.ToAccountID,
+			Amount:        request.Amount,
+			Frequency:     request.Frequency,
+			StartAt:       request.StartAt,
+		},
+		Audit: auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+type listScheduledTransfersRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+func (server *Server) listScheduledTransfers(ctx *gin.Context) {
+	var request listScheduledTransfersRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	schedules, err := server.store.ListScheduledTransfers(ctx, db.ListScheduledTransfersParams{
+		Owner:  authPayload.Username,
+		Limit:  request.PageSize,
+		Offset: (request.PageID - 1) * request.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedules)
+}
+
+type scheduledTransferURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getScheduledTransfer(ctx *gin.Context) {
+	var uri scheduledTransferURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, valid := server.authorizedScheduledTransfer(ctx, uri.ID, authz.ViewAnyTransfer)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+type updateScheduledTransferRequest struct {
+	Amount *int64  `json:"amount" binding:"omitempty,gt=0"`
+	Status *string `json:"status" binding:"omitempty,oneof=active paused"`
+}
+
+// updateScheduledTransfer changes the amount of a schedule or pauses and resumes it.
+// Occurrences missed while a schedule was paused are skipped rather than executed all at once on resume
+func (server *Server) updateScheduledTransfer(ctx *gin.Context) {
+	var uri scheduledTransferURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var request updateScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, valid := server.authorizedScheduledTransfer(ctx, uri.ID, authz.OwnerOnly)
+	if !valid {
+		return
+	}
+
+	if schedule.Status != util.ScheduleStatusActive && schedule.Status != util.ScheduleStatusPaused {
+		err := errors.New("scheduled transfer is " + schedule.Status)
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateScheduledTransferParams{ID: schedule.ID}
+	if request.Amount != nil {
+		if !server.checkTransferAmount(ctx, schedule.Owner, *request.Amount) {
+			return
+		}
+		arg.Amount = sql.NullInt64{Int64: *request.Amount, Valid: true}
+	}
+	if request.Status != nil {
+		arg.Status = sql.NullString{String: *request.Status, Valid: true}
+	}
+
+	if schedule.Status == util.ScheduleStatusPaused && arg.Status.String == util.ScheduleStatusActive {
+		runs, next, ok := nextRunAfter(schedule, time.Now())
+		if !ok {
+			err := errors.New("scheduled transfer has no occurrence left")
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		arg.Runs = sql.NullInt32{Int32: runs, Valid: true}
+		arg.NextRunAt = sql.NullTime{Time: next, Valid: true}
+	}
+
+	schedule, err := server.store.UpdateScheduledTransferTx(ctx, db.UpdateScheduledTransferTxParams{
+		UpdateScheduledTransferParams: arg,
+		Audit:                         auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+// nextRunAfter finds the first occurrence of a schedule that isn't before now,
+// and the number of occurrences that precede it
+func nextRunAfter(schedule db.ScheduledTransfer, now time.Time) (int32, time.Time, bool) {
+	if !schedule.NextRunAt.Before(now) {
+		return schedule.Runs, schedule.NextRunAt, true
+	}
+
+	for runs := schedule.Runs; ; runs++ {
+		next, ok := util.NextScheduledRun(schedule.Frequency, schedule.StartAt, runs)
+		if !ok || !next.Before(now) {
+			return runs, next, ok
+		}
+	}
+}
+
+func (server *Server) deleteScheduledTransfer(ctx *gin.Context) {
+	var uri scheduledTransferURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, valid := server.authorizedScheduledTransfer(ctx, uri.ID, authz.OwnerOnly)
+	if !valid {
+		return
+	}
+
+	err := server.store.DeleteScheduledTransferTx(ctx, db.DeleteScheduledTransferTxParams{
+		ID:    schedule.ID,
+		Audit: auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "scheduled transfer deleted")
+}
+
+func (server *Server) authorizedScheduledTransfer(ctx *gin.Context, id int64, permission authz.Permission) (db.ScheduledTransfer, bool) {
+	schedule, err := server.store.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return schedule, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return schedule, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, schedule.Owner, permission) {
+		err := errors.New("scheduled transfer doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return schedule, false
+	}
+
+	return schedule, true
+}
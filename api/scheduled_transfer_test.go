@@ -0,0 +1,401 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomScheduledTransfer(owner string, fromAccountID, toAccountID int64) db.ScheduledTransfer {
+	startAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	return db.ScheduledTransfer{
+		ID:            util.RandomInt(1, 1000),
+		Owner:         owner,
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        util.RandomAmount(),
+		Frequency:     util.FrequencyMonthly,
+		StartAt:       startAt,
+		NextRunAt:     startAt,
+		Status:        util.ScheduleStatusActive,
+	}
+}
+
+func TestCreateScheduledTransfer(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account2.ID = account1.ID + 1
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	schedule := randomScheduledTransfer(user1.Username, account1.ID, account2.ID)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          schedule.Amount,
+				"currency":        util.USD,
+				"frequency":       util.FrequencyMonthly,
+				"start_at":        schedule.StartAt,
+			},
+			username: user1.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				arg := db.CreateScheduledTransferParams{
+					Owner:         user1.Username,
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        schedule.Amount,
+					Frequency:     util.FrequencyMonthly,
+					StartAt:       schedule.StartAt,
+				}
+				store.EXPECT().
+					CreateScheduledTransferTx(gomock.Any(), EqAudited(db.CreateScheduledTransferTxParams{CreateScheduledTransferParams: arg}, user1.Username)).
+					Times(1).
+					Return(schedule, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got db.ScheduledTransfer
+				err := json.Unmarshal(recorder.Body.Bytes(), &got)
+				require.NoError(t, err)
+				require.Equal(t, schedule.ID, got.ID)
+			},
+		},
+		{
+			name: "StartInThePast",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          schedule.Amount,
+				"currency":        util.USD,
+				"frequency":       util.FrequencyDaily,
+				"start_at":        time.Now().Add(-time.Hour),
+			},
+			username: user1.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedFrequency",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          schedule.Amount,
+				"currency":        util.USD,
+				"frequency":       "yearly",
+				"start_at":        schedule.StartAt,
+			},
+			username: user1.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SameAccount",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account1.ID,
+				"amount":          schedule.Amount,
+				"currency":        util.USD,
+				"frequency":       util.FrequencyDaily,
+				"start_at":        schedule.StartAt,
+			},
+			username: user1.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          schedule.Amount,
+				"currency":        util.USD,
+				"frequency":       util.FrequencyWeekly,
+				"start_at":        schedule.StartAt,
+			},
+			username: user2.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().CreateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/scheduled-transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateScheduledTransfer(t *testing.T) {
+	user, _ := randomUser(t)
+	schedule := randomScheduledTransfer(user.Username, 1, 2)
+
+	// paused long enough to miss two monthly occurrences
+	paused := schedule
+	paused.Status = util.ScheduleStatusPaused
+	paused.StartAt = time.Now().AddDate(0, 0, -40)
+	paused.NextRunAt = paused.StartAt
+
+	completed := schedule
+	completed.Status = util.ScheduleStatusCompleted
+
+	testCases := []struct {
+		name          string
+		schedule      db.ScheduledTransfer
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Pause",
+			schedule: schedule,
+			body:     gin.H{"status": util.ScheduleStatusPaused, "amount": 10},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(schedule.ID)).Times(1).Return(schedule, nil)
+				arg := db.UpdateScheduledTransferParams{
+					ID:     schedule.ID,
+					Amount: sql.NullInt64{Int64: 10, Valid: true},
+					Status: sql.NullString{String: util.ScheduleStatusPaused, Valid: true},
+				}
+				store.EXPECT().
+					UpdateScheduledTransferTx(gomock.Any(), EqAudited(db.UpdateScheduledTransferTxParams{UpdateScheduledTransferParams: arg}, user.Username)).
+					Times(1).
+					Return(schedule, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "ResumeSkipsMissedRuns",
+			schedule: paused,
+			body:     gin.H{"status": util.ScheduleStatusActive},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(paused.ID)).Times(1).Return(paused, nil)
+				next, _ := util.NextScheduledRun(paused.Frequency, paused.StartAt, 2)
+				arg := db.UpdateScheduledTransferParams{
+					ID:        paused.ID,
+					Status:    sql.NullString{String: util.ScheduleStatusActive, Valid: true},
+					Runs:      sql.NullInt32{Int32: 2, Valid: true},
+					NextRunAt: sql.NullTime{Time: next, Valid: true},
+				}
+				store.EXPECT().
+					UpdateScheduledTransferTx(gomock.Any(), EqAudited(db.UpdateScheduledTransferTxParams{UpdateScheduledTransferParams: arg}, user.Username)).
+					Times(1).
+					Return(paused, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Completed",
+			schedule: completed,
+			body:     gin.H{"status": util.ScheduleStatusActive},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(completed.ID)).Times(1).Return(completed, nil)
+				store.EXPECT().UpdateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidStatus",
+			schedule: schedule,
+			body:     gin.H{"status": util.ScheduleStatusCompleted},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			schedule: schedule,
+			body:     gin.H{"status": util.ScheduleStatusPaused},
+			username: "unauthorized_user",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(schedule.ID)).Times(1).Return(schedule, nil)
+				store.EXPECT().UpdateScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/scheduled-transfers/%d", tc.schedule.ID)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteScheduledTransfer(t *testing.T) {
+	user, _ := randomUser(t)
+	schedule := randomScheduledTransfer(user.Username, 1, 2)
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(schedule.ID)).Times(1).Return(schedule, nil)
+				arg := db.DeleteScheduledTransferTxParams{ID: schedule.ID}
+				store.EXPECT().DeleteScheduledTransferTx(gomock.Any(), EqAudited(arg, user.Username)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			// bankers may view any schedule but only the owner may cancel it
+			name:     "Banker",
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetScheduledTransfer(gomock.Any(), gomock.Eq(schedule.ID)).Times(1).Return(schedule, nil)
+				store.EXPECT().DeleteScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetScheduledTransfer(gomock.Any(), gomock.Eq(schedule.ID)).
+					Times(1).
+					Return(db.ScheduledTransfer{}, sql.ErrNoRows)
+				store.EXPECT().DeleteScheduledTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/scheduled-transfers/%d", schedule.ID)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -6,27 +6,59 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/muditshukla3/simplebank/authz"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/funding"
+	"github.com/muditshukla3/simplebank/fx"
+	"github.com/muditshukla3/simplebank/lockout"
 	"github.com/muditshukla3/simplebank/token"
 	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
 )
 
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config          util.Config
+	store           db.Store
+	tokenMaker      token.Maker
+	rateProvider    fx.RateProvider
+	fundingProvider funding.Provider
+	taskDistributor worker.TaskDistributor
+	loginGuard      *lockout.Guard
+	router          *gin.Engine
 }
 
-func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) (*Server, error) {
+	tokenMaker, err := token.NewMaker(config.TokenType, token.KeySource{
+		KeyFile:              config.TokenKeyFile,
+		SymmetricKey:         config.TokenSymmetricKey,
+		RetiredSymmetricKeys: config.TokenRetiredSymmetricKeys,
+		PrivateKeyFile:       config.TokenPrivateKeyFile,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:          config,
+		store:           store,
+		tokenMaker:      tokenMaker,
+		taskDistributor: taskDistributor,
+		loginGuard:      lockout.NewGuard(store, lockout.PolicyFromConfig(config)),
+	}
+
+	// cross-currency transfers stay disabled unless a rates file is configured
+	if len(config.FXRatesFile) > 0 {
+		server.rateProvider, err = fx.LoadStaticRateProvider(config.FXRatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create rate provider: %w", err)
+		}
+	}
+
+	// deposits and withdrawals stay disabled unless a funding provider is configured
+	if len(config.FundingProvider) > 0 {
+		server.fundingProvider, err = funding.NewProvider(config.FundingProvider)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create funding provider: %w", err)
+		}
 	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
@@ -34,25 +66,83 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 	}
 
 	//add routes to router
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, fmt.Errorf("cannot set up router: %w", err)
+	}
 	return server, nil
 }
 
-func (server *Server) setupRouter() {
+func (server *Server) setupRouter() error {
 	router := gin.Default()
+	// the client ip keys the login throttling, so X-Forwarded-For is only believed from the configured proxies
+	if err := router.SetTrustedProxies(server.config.TrustedProxies); err != nil {
+		return err
+	}
+	router.Use(requestIDMiddleware())
 	router.POST("/users", server.createUser)
 	router.POST("/users/login", server.loginUser)
+	router.POST("/users/login/mfa", server.loginUserMFA)
+	router.GET("/verify_email", server.verifyEmail)
+	router.GET("/.well-known/jwks.json", server.getJWKS)
+	router.POST("/users/password/forgot", server.forgotPassword)
+	router.POST("/users/password/reset", server.resetPassword)
+
+	authRoutes := router.Group("/", authMiddleware(server.tokenMaker, server.store, server.config.TokenAudience))
+
+	// renewing keeps the scopes of the session, so any of its access tokens may do it
+	authRoutes.POST("/token/renew_access", server.renewAccessToken)
+
+	// every other group declares the scope it requires, tokens and api keys without it are rejected
+	userRoutes := authRoutes.Group("/users", scopeMiddleware(authz.ScopeUser))
+	userRoutes.POST("/logout", server.logoutUser)
+	userRoutes.PATCH("/:username", server.updateUser)
+	userRoutes.POST("/totp", server.enrollTOTP)
+	userRoutes.POST("/totp/confirm", server.confirmTOTP)
+	userRoutes.GET("/sessions", server.listSessions)
+	userRoutes.DELETE("/sessions/:id", server.blockSession)
+	userRoutes.POST("/api_keys", server.createAPIKey)
+	userRoutes.GET("/api_keys", server.listAPIKeys)
+	userRoutes.DELETE("/api_keys/:id", server.revokeAPIKey)
+
+	readRoutes := authRoutes.Group("/", scopeMiddleware(authz.ScopeAccountsRead))
+	readRoutes.GET("/accounts/:id", server.getAccount)
+	readRoutes.GET("/accounts", server.listAccounts)
+	readRoutes.GET("/accounts/:id/entries", server.listAccountEntries)
+	readRoutes.GET("/accounts/:id/transfers", server.listAccountTransfers)
+	readRoutes.GET("/transfers/:id", server.getTransfer)
+	readRoutes.GET("/scheduled-transfers", server.listScheduledTransfers)
+	readRoutes.GET("/scheduled-transfers/:id", server.getScheduledTransfer)
+	readRoutes.GET("/fundings/:id", server.getFunding)
+	readRoutes.GET("/accounts/:id/holds", server.listAccountHolds)
+	readRoutes.GET("/holds/:id", server.getHold)
+
+	accountRoutes := authRoutes.Group("/accounts", scopeMiddleware(authz.ScopeAccountsWrite))
+	accountRoutes.POST("", server.createAccount)
+	accountRoutes.POST("/:id/close", server.closeAccount)
+
+	transferRoutes := authRoutes.Group("/", scopeMiddleware(authz.ScopeTransfersWrite))
+	transferRoutes.POST("/transfers", server.createTransfer)
+	transferRoutes.POST("/scheduled-transfers", server.createScheduledTransfer)
+	transferRoutes.PATCH("/scheduled-transfers/:id", server.updateScheduledTransfer)
+	transferRoutes.DELETE("/scheduled-transfers/:id", server.deleteScheduledTransfer)
+	transferRoutes.POST("/holds", server.placeHold)
+	transferRoutes.POST("/holds/:id/capture", server.captureHold)
+	transferRoutes.POST("/holds/:id/release", server.releaseHold)
 
-	authRoute := router.Group("/").Use(authMiddleware(server.tokenMaker))
-	authRoute.POST("/token/renew_access", server.renewAccessToken)
-	authRoute.POST("/accounts", server.createAccount)
-	authRoute.GET("/accounts/:id", server.getAccount)
-	authRoute.GET("/accounts", server.listAccounts)
-	authRoute.DELETE("/accounts/:id", server.deleteAccount)
+	fundingRoutes := authRoutes.Group("/", scopeMiddleware(authz.ScopeFundingsWrite))
+	fundingRoutes.POST("/deposits", server.createDeposit)
+	fundingRoutes.POST("/withdrawals", server.createWithdrawal)
+	fundingRoutes.POST("/fundings/:id/confirm", server.confirmFunding)
 
-	authRoute.POST("/transfers", server.createTransfer)
+	adminRoutes := authRoutes.Group("/", scopeMiddleware(authz.ScopeAdmin))
+	adminRoutes.POST("/accounts/:id/freeze", permissionMiddleware(authz.FreezeAccount), server.freezeAccount)
+	adminRoutes.POST("/accounts/:id/unfreeze", permissionMiddleware(authz.FreezeAccount), server.unfreezeAccount)
+	adminRoutes.GET("/admin/ledger/verify", permissionMiddleware(authz.VerifyLedger), server.verifyLedger)
+	adminRoutes.GET("/admin/audit_events", permissionMiddleware(authz.ViewAuditLog), server.listAuditEvents)
+	adminRoutes.GET("/admin/audit_events/verify", permissionMiddleware(authz.ViewAuditLog), server.verifyAuditLog)
 
 	server.router = router
+	return nil
 }
 
 //run the server
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+)
+
+// sessionResponse leaves out the refresh token, which must never be sent back to the client
+type sessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIp  string    `json:"client_ip"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	Current   bool      `json:"current"`
+}
+
+func newSessionResponse(session db.Session, current uuid.UUID) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+		Current:   session.ID == current,
+	}
+}
+
+// logoutUser blocks the session of the access token, which also revokes its refresh token
+func (server *Server) logoutUser(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.SessionID == uuid.Nil {
+		err := errors.New("access token is not tied to a session")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	server.blockUserSession(ctx, authPayload, authPayload.SessionID)
+}
+
+type listSessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+func (server *Server) listSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	sessions, err := server.store.ListActiveSessions(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := listSessionsResponse{Sessions: make([]sessionResponse, len(sessions))}
+	for i, session := range sessions {
+		response.Sessions[i] = newSessionResponse(session, authPayload.SessionID)
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+type blockSessionRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// blockSession signs out another device of the authenticated user
+func (server *Server) blockSession(ctx *gin.Context) {
+	var request blockSessionRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	id, err := uuid.Parse(request.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	server.blockUserSession(ctx, authPayload, id)
+}
+
+// blockUserSession blocks the whole family of a session, so the refresh tokens rotated from the same login
+// and the access tokens issued with them stop working
+func (server *Server) blockUserSession(ctx *gin.Context, authPayload *token.Payload, id uuid.UUID) {
+	session, err := server.store.GetSession(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if session.Username != authPayload.Username {
+		err := errors.New("session doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.BlockSessionFamilyTx(ctx, db.BlockSessionFamilyTxParams{
+		FamilyID: session.FamilyID,
+		Audit:    auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "session blocked")
+}
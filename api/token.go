@@ -2,20 +2,28 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
 )
 
 type renewAccessTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+// renewAccessTokenResponse also carries the rotated refresh token, the one in the request can't be used again
 type renewAccessTokenResponse struct {
-	AccessToekn          string    `json:"access_token"`
-	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	AccessToekn           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	Scopes                []string  `json:"scopes"`
 }
 
 func (server *Server) renewAccessToken(ctx *gin.Context) {
@@ -32,6 +40,14 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
+
+	// access tokens have their own audience, so they can't be presented as refresh tokens
+	if !refreshPayload.HasAudience(token.RefreshAudience(server.config.TokenAudience)) {
+		err := errors.New("token is not a refresh token")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
 	session, err := server.store.GetSession(ctx, refreshPayload.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -65,19 +81,97 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
-	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
-		refreshPayload.Username, server.config.AccessTokenDuration,
-	)
 
+	if session.UsedAt.Valid {
+		server.rejectRefreshTokenReuse(ctx, session)
+		return
+	}
+
+	// the role is read again so that a role change applies from the next renewal
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the session keeps its scopes, refresh tokens from before scopes get those of a new login
+	scopes := refreshPayload.Scopes
+	if scopes == nil {
+		scopes = authz.ScopeStrings(authz.LoginScopes)
+	}
+
+	refreshToken, nextRefreshPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username: refreshPayload.Username,
+		Role:     user.Role,
+		Scopes:   scopes,
+		Audience: token.RefreshAudience(server.config.TokenAudience),
+		Duration: server.config.RefreshTokenDuration,
+	})
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username:  refreshPayload.Username,
+		Role:      user.Role,
+		SessionID: nextRefreshPayload.ID,
+		Scopes:    scopes,
+		Audience:  server.config.TokenAudience,
+		Duration:  server.config.AccessTokenDuration,
+	})
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		UsedSessionID: session.ID,
+		NextSession: db.CreateSessionParams{
+			ID:           nextRefreshPayload.ID,
+			Username:     session.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    ctx.Request.UserAgent(),
+			ClientIp:     ctx.ClientIP(),
+			IsBlocked:    false,
+			ExpiresAt:    nextRefreshPayload.ExpiredAt,
+			FamilyID:     session.FamilyID,
+		},
+		Audit: auditRequest(ctx),
+	})
 	if err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			server.rejectRefreshTokenReuse(ctx, session)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	response := renewAccessTokenResponse{
-		AccessToekn:          accessToken,
-		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+		AccessToekn:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: nextRefreshPayload.ExpiredAt,
+		Scopes:                accessPayload.Scopes,
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// rejectRefreshTokenReuse blocks every session rotated from the same login.
+// A used refresh token showing up again means it leaked, so neither holder should keep the session
+func (server *Server) rejectRefreshTokenReuse(ctx *gin.Context, session db.Session) {
+	_, err := server.store.BlockSessionFamilyTx(ctx, db.BlockSessionFamilyTxParams{
+		FamilyID: session.FamilyID,
+		Audit:    auditRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusUnauthorized, errorResponse(db.ErrRefreshTokenReused))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"io/ioutil"
@@ -20,16 +21,18 @@ import (
 )
 
 func generateRandomSession(t *testing.T) db.Session {
+	return generateSession(t, token.Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Hour})
+}
+
+// generateSession returns a session whose refresh token makes claims
+func generateSession(t *testing.T, claims token.Claims) db.Session {
 	maker, err := token.NewPasetoMaker("UcRefYQrNjcOdpstFsBNFq2yOz9gxThc")
 	require.NoError(t, err)
 
-	username := util.RandomOwner()
-	duration := time.Hour
-
 	issuedAt := time.Now()
-	expiredAt := issuedAt.Add(duration)
+	expiredAt := issuedAt.Add(claims.Duration)
 
-	token, payload, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(claims)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -40,21 +43,38 @@ func generateRandomSession(t *testing.T) db.Session {
 		IsBlocked:    false,
 		ExpiresAt:    expiredAt,
 		RefreshToken: token,
+		FamilyID:     payload.ID,
 	}
 }
+
 func TestRenewAccessToken(t *testing.T) {
 	session := generateRandomSession(t)
 	blockedSession := generateRandomSession(t)
 	expiredSession := generateRandomSession(t)
 	expiredSession.ExpiresAt = time.Now().AddDate(0, 0, -1)
 	blockedSession.IsBlocked = true
+	usedSession := generateRandomSession(t)
+	usedSession.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
 	tokenResponse := renewAccessTokenResponse{
 		AccessToekn:          session.RefreshToken,
 		AccessTokenExpiresAt: session.ExpiresAt,
 	}
 
 	maker, _ := token.NewPasetoMaker("UcRefYQrNjcOdpstFsBNFq2yOz9gxThc")
-	difftoken, _, _ := maker.CreateToken(util.RandomOwner(), time.Minute)
+	difftoken, _, _ := maker.CreateToken(token.Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	scopedSession := generateSession(t, token.Claims{
+		Username: util.RandomOwner(),
+		Role:     util.DepositorRole,
+		Scopes:   []string{"accounts:read"},
+		Audience: token.RefreshAudience("simplebank"),
+		Duration: time.Hour,
+	})
+	accessToken, _, _ := maker.CreateToken(token.Claims{
+		Username: util.RandomOwner(),
+		Role:     util.DepositorRole,
+		Audience: "simplebank",
+		Duration: time.Minute,
+	})
 
 	testCases := []struct {
 		name          string
@@ -69,13 +89,27 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetSession(gomock.Any(), gomock.Eq(session.ID)).
 					Times(1).
 					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(session.Username)).
+					Times(1).
+					Return(db.User{Username: session.Username, Role: util.DepositorRole}, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RotateSessionTxParams) (db.Session, error) {
+						require.Equal(t, session.ID, arg.UsedSessionID)
+						require.Equal(t, session.FamilyID, arg.NextSession.FamilyID)
+						require.Equal(t, session.Username, arg.NextSession.Username)
+						require.NotEqual(t, session.RefreshToken, arg.NextSession.RefreshToken)
+						return db.Session{ID: arg.NextSession.ID, FamilyID: arg.NextSession.FamilyID}, nil
+					})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				//check response
@@ -83,13 +117,121 @@ func TestRenewAccessToken(t *testing.T) {
 				requireBodyMatchSession(t, recorder.Body, tokenResponse)
 			},
 		},
+		{
+			name: "KeepsScopes",
+			body: gin.H{
+				"refresh_token": scopedSession.RefreshToken,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(scopedSession.ID)).
+					Times(1).
+					Return(scopedSession, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(scopedSession.Username)).
+					Times(1).
+					Return(db.User{Username: scopedSession.Username, Role: util.DepositorRole}, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response renewAccessTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, []string{"accounts:read"}, response.Scopes)
+			},
+		},
+		{
+			name: "AccessTokenAsRefreshToken",
+			body: gin.H{
+				"refresh_token": accessToken,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UsedToken",
+			body: gin.H{
+				"refresh_token": usedSession.RefreshToken,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(usedSession.ID)).
+					Times(1).
+					Return(usedSession, nil)
+				store.EXPECT().
+					BlockSessionFamilyTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.BlockSessionFamilyTxParams) ([]db.Session, error) {
+						require.Equal(t, usedSession.FamilyID, arg.FamilyID)
+						return []db.Session{usedSession}, nil
+					})
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				//check response
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ConcurrentRenewal",
+			body: gin.H{
+				"refresh_token": session.RefreshToken,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(session.Username)).
+					Times(1).
+					Return(db.User{Username: session.Username, Role: util.DepositorRole}, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, db.ErrRefreshTokenReused)
+				store.EXPECT().
+					BlockSessionFamilyTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.BlockSessionFamilyTxParams) ([]db.Session, error) {
+						require.Equal(t, session.FamilyID, arg.FamilyID)
+						return []db.Session{session}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				//check response
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "InvalidBody",
 			body: gin.H{
 				"refre_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -107,7 +249,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -126,7 +268,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -145,7 +287,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -164,7 +306,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": session.RefreshToken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -183,7 +325,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": difftoken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -202,7 +344,7 @@ func TestRenewAccessToken(t *testing.T) {
 				"refresh_token": difftoken,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationType, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationType, "user", util.DepositorRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -252,4 +394,6 @@ func requireBodyMatchSession(t *testing.T, body *bytes.Buffer, response renewAcc
 	require.NoError(t, err)
 	require.NotEmpty(t, renewResponse.AccessToekn)
 	require.WithinDuration(t, renewResponse.AccessTokenExpiresAt, time.Now(), time.Minute)
+	require.NotEmpty(t, renewResponse.RefreshToken)
+	require.NotEqual(t, response.AccessToekn, renewResponse.RefreshToken)
 }
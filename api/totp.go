@@ -0,0 +1,297 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/totp"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const (
+	totpIssuer = "Simple Bank"
+	// totpCodeHeader carries the fresh totp code required for large transfers
+	totpCodeHeader = "X-TOTP-Code"
+	// mfaChallengeDuration is how long the second step of a login may take
+	mfaChallengeDuration = 5 * time.Minute
+	// maxMFAAttempts limits the codes that can be tried against one challenge, after that the user must log in again
+	maxMFAAttempts = 5
+)
+
+type enrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// enrollTOTP generates a new secret for the logged-in user, two-factor authentication
+// stays off until a code of it is confirmed with confirmTOTP
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.EnrollTOTPTx(ctx, db.EnrollTOTPTxParams{
+		SetTOTPSecretParams: db.SetTOTPSecretParams{
+			Username:   authPayload.Username,
+			TotpSecret: secret,
+		},
+		Audit: auditRequest(ctx),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("two-factor authentication is already enabled")
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Username, secret),
+	})
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// confirmTOTPResponse lists the recovery codes, they are only shown this once
+type confirmTOTPResponse struct {
+	User          UserResponse `json:"user"`
+	RecoveryCodes []string     `json:"recovery_codes"`
+}
+
+// confirmTOTP enables two-factor authentication once the user sends a valid code of the enrolled secret
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var request confirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.TotpEnabled || len(user.TotpSecret) == 0 {
+		ctx.JSON(http.StatusConflict, errorResponse(db.ErrTOTPNotPending))
+		return
+	}
+
+	step, ok := totp.Validate(user.TotpSecret, request.Code, time.Now())
+	if !ok {
+		err := errors.New("invalid totp code")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.EnableTOTPTxParams{
+		Username: user.Username,
+		Step:     step,
+		Audit:    auditRequest(ctx),
+	}
+	for _, code := range recoveryCodes {
+		arg.RecoveryCodeHashes = append(arg.RecoveryCodeHashes, util.HashSecretToken(code))
+	}
+
+	user, err = server.store.EnableTOTPTx(ctx, arg)
+	if err != nil {
+		if errors.Is(err, db.ErrTOTPNotPending) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTOTPResponse{
+		User:          newUserResponse(user),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// loginMFAResponse replaces the tokens of loginUserResponse for users with two-factor authentication
+type loginMFAResponse struct {
+	MFARequired       bool      `json:"mfa_required"`
+	MFAToken          string    `json:"mfa_token"`
+	MFATokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+}
+
+// createMFAChallenge answers a login with correct password by a challenge token that loginUserMFA redeems.
+// The token is opaque and stored hashed, so it can't be used as an access token
+func (server *Server) createMFAChallenge(ctx *gin.Context, user db.User) {
+	mfaToken, err := util.NewSecretToken()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.store.CreateMFAChallenge(ctx, db.CreateMFAChallengeParams{
+		Username:  user.Username,
+		TokenHash: util.HashSecretToken(mfaToken),
+		UserAgent: ctx.Request.UserAgent(),
+		ClientIp:  ctx.ClientIP(),
+		ExpiresAt: time.Now().Add(mfaChallengeDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginMFAResponse{
+		MFARequired:       true,
+		MFAToken:          mfaToken,
+		MFATokenExpiresAt: challenge.ExpiresAt,
+	})
+}
+
+// loginUserMFARequest takes either a totp code or one of the recovery codes, and the scopes like loginUserRequest
+type loginUserMFARequest struct {
+	MFAToken     string        `json:"mfa_token" binding:"required"`
+	Code         string        `json:"code" binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string        `json:"recovery_code" binding:"required_without=Code"`
+	Scopes       []authz.Scope `json:"scopes"`
+}
+
+// loginUserMFA completes the login of a user with two-factor authentication
+func (server *Server) loginUserMFA(ctx *gin.Context) {
+	var request loginUserMFARequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	scopes, err := loginScopes(request.Scopes)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.store.AttemptMFAChallenge(ctx, db.AttemptMFAChallengeParams{
+		TokenHash:   util.HashSecretToken(request.MFAToken),
+		MaxAttempts: maxMFAAttempts,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("mfa token is invalid, expired or used up")
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// wrong codes count as failed logins of the user, so new challenges don't give a fresh set of guesses
+	now := time.Now()
+	retryAfter, err := server.loginGuard.Check(ctx, challenge.Username, ctx.ClientIP(), now)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if retryAfter > 0 {
+		loginThrottled(ctx, retryAfter)
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, challenge.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var valid bool
+	if len(request.RecoveryCode) > 0 {
+		valid, err = server.useRecoveryCode(ctx, user, request.RecoveryCode)
+	} else {
+		valid, err = server.useTOTPCode(ctx, user, request.Code)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !valid {
+		if err := server.loginGuard.Fail(ctx, user.Username, ctx.ClientIP(), now); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		err := errors.New("invalid two-factor authentication code")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	// a challenge logs in once, even if two requests with valid codes race
+	completed, err := server.store.CompleteMFAChallenge(ctx, challenge.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if completed == 0 {
+		err := errors.New("mfa token has already been used")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if err := server.loginGuard.Succeed(ctx, user.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response, err := server.createLoginSession(ctx, user, scopes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// useTOTPCode reports whether code is valid for user and wasn't used before, and marks it used
+func (server *Server) useTOTPCode(ctx *gin.Context, user db.User, code string) (bool, error) {
+	if !user.TotpEnabled {
+		return false, nil
+	}
+
+	step, ok := totp.Validate(user.TotpSecret, code, time.Now())
+	if !ok || step <= user.TotpLastUsedStep {
+		return false, nil
+	}
+
+	rows, err := server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		Username:         user.Username,
+		TotpLastUsedStep: step,
+	})
+	return rows == 1, err
+}
+
+// useRecoveryCode reports whether code is an unused recovery code of user, and marks it used
+func (server *Server) useRecoveryCode(ctx *gin.Context, user db.User, code string) (bool, error) {
+	return server.store.UseRecoveryCodeTx(ctx, db.UseRecoveryCodeTxParams{
+		UseRecoveryCodeParams: db.UseRecoveryCodeParams{
+			Username: user.Username,
+			CodeHash: util.HashSecretToken(strings.ToLower(strings.TrimSpace(code))),
+		},
+		Audit: auditRequest(ctx),
+	})
+}
@@ -0,0 +1,648 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/lockout"
+	"github.com/muditshukla3/simplebank/totp"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// randomTOTPUser returns a user who enrolled a totp secret but hasn't confirmed it yet
+func randomTOTPUser(t *testing.T) db.User {
+	user, _ := randomUser(t)
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	user.TotpSecret = secret
+	return user
+}
+
+func currentTOTPCode(t *testing.T, user db.User) string {
+	code, err := totp.GenerateCode(user.TotpSecret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					EnrollTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.EnrollTOTPTxParams) (db.User, error) {
+						require.Equal(t, user.Username, txArg.Audit.Actor)
+						arg := txArg.SetTOTPSecretParams
+						require.Equal(t, user.Username, arg.Username)
+						require.NotEmpty(t, arg.TotpSecret)
+
+						enrolled := user
+						enrolled.TotpSecret = arg.TotpSecret
+						return enrolled, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response enrollTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.NotEmpty(t, response.Secret)
+				require.Contains(t, response.URI, "otpauth://totp/")
+				require.Contains(t, response.URI, response.Secret)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().EnrollTOTPTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	user := randomTOTPUser(t)
+	enabled := user
+	enabled.TotpEnabled = true
+	notEnrolled, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		code          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: user,
+			code: func(t *testing.T) string { return currentTOTPCode(t, user) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					EnableTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.EnableTOTPTxParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, totp.Step(time.Now()), arg.Step)
+						require.Len(t, arg.RecoveryCodeHashes, totp.RecoveryCodeCount)
+						return enabled, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response confirmTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.True(t, response.User.TOTPEnabled)
+				require.Len(t, response.RecoveryCodes, totp.RecoveryCodeCount)
+			},
+		},
+		{
+			name: "InvalidCode",
+			user: user,
+			code: func(t *testing.T) string { return "000000" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			user: notEnrolled,
+			code: func(t *testing.T) string { return "123456" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(notEnrolled.Username)).Times(1).Return(notEnrolled, nil)
+				store.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			user: enabled,
+			code: func(t *testing.T) string { return currentTOTPCode(t, enabled) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(enabled.Username)).Times(1).Return(enabled, nil)
+				store.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "MalformedCode",
+			user: user,
+			code: func(t *testing.T) string { return "12ab56" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"code": tc.code(t)})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/confirm", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, tc.user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserMFA(t *testing.T) {
+	user := randomTOTPUser(t)
+	user.TotpEnabled = true
+
+	mfaToken, err := util.NewSecretToken()
+	require.NoError(t, err)
+	challenge := db.MfaChallenge{ID: 1, Username: user.Username, Attempts: 1}
+	attempt := db.AttemptMFAChallengeParams{
+		TokenHash:   util.HashSecretToken(mfaToken),
+		MaxAttempts: maxMFAAttempts,
+	}
+	recoveryCode := "1a2b3-c4d5e"
+
+	testCases := []struct {
+		name          string
+		body          func(t *testing.T) gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "TOTPCodeOK",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, user)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Eq(db.UseTOTPStepParams{
+						Username:         user.Username,
+						TotpLastUsedStep: totp.Step(time.Now()),
+					})).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().CompleteMFAChallenge(gomock.Any(), gomock.Eq(challenge.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.NotEmpty(t, response.AccessToekn)
+				require.NotEmpty(t, response.RefreshToken)
+			},
+		},
+		{
+			name: "RecoveryCodeOK",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UseRecoveryCodeTx(gomock.Any(), EqAudited(db.UseRecoveryCodeTxParams{
+						UseRecoveryCodeParams: db.UseRecoveryCodeParams{
+							Username: user.Username,
+							CodeHash: util.HashSecretToken(recoveryCode),
+						},
+					}, "")).
+					Times(1).
+					Return(true, nil)
+				store.EXPECT().CompleteMFAChallenge(gomock.Any(), gomock.Eq(challenge.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ReplayedTOTPCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, user)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				used := user
+				used.TotpLastUsedStep = totp.Step(time.Now())
+
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(used, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CompleteMFAChallenge(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UsedRecoveryCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCodeTx(gomock.Any(), gomock.Any()).Times(1).Return(false, nil)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ChallengeUsedUp",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, user)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ChallengeCompletedConcurrently",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Eq(attempt)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCodeTx(gomock.Any(), gomock.Any()).Times(1).Return(true, nil)
+				store.EXPECT().CompleteMFAChallenge(gomock.Any(), gomock.Eq(challenge.ID)).Times(1).Return(int64(0), nil)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NoCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body(t))
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserMFALockout(t *testing.T) {
+	user := randomTOTPUser(t)
+	user.TotpEnabled = true
+
+	mfaToken, err := util.NewSecretToken()
+	require.NoError(t, err)
+	challenge := db.MfaChallenge{ID: 1, Username: user.Username, Attempts: 1}
+	recoveryCode := "1a2b3-c4d5e"
+
+	policy := lockout.Policy{
+		MaxFailures:     5,
+		Window:          15 * time.Minute,
+		BaseDelay:       time.Second,
+		LockoutDuration: 15 * time.Minute,
+	}
+	usernameKey := db.GetLoginThrottleParams{Kind: lockout.KindUsername, Subject: user.Username}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OKForgetsFailures",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Any()).Times(1).Return(challenge, nil)
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCodeTx(gomock.Any(), gomock.Any()).Times(1).Return(true, nil)
+				store.EXPECT().CompleteMFAChallenge(gomock.Any(), gomock.Eq(challenge.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().
+					DeleteLoginThrottle(gomock.Any(), gomock.Eq(db.DeleteLoginThrottleParams(usernameKey))).
+					Times(1)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "WrongCodeCountsAsFailure",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Any()).Times(1).Return(challenge, nil)
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UseRecoveryCodeTx(gomock.Any(), gomock.Any()).Times(1).Return(false, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginThrottle, error) {
+						require.Equal(t, lockout.KindUsername, arg.Kind)
+						require.Equal(t, user.Username, arg.Subject)
+						return db.LoginThrottle{Kind: arg.Kind, Subject: arg.Subject, Failures: 1}, nil
+					})
+				store.EXPECT().DelayLogin(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().DeleteLoginThrottle(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Locked",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AttemptMFAChallenge(gomock.Any(), gomock.Any()).Times(1).Return(challenge, nil)
+				store.EXPECT().
+					GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).
+					Times(1).
+					Return(db.LoginThrottle{Failures: 5, NextAttemptAt: time.Now().Add(10 * time.Minute)}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UseRecoveryCodeTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.loginGuard = lockout.NewGuard(store, policy)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateTransferRequiresTOTP(t *testing.T) {
+	user := randomTOTPUser(t)
+	user.TotpEnabled = true
+	user.IsEmailVerified = true
+	other, _ := randomUser(t)
+
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount(other.Username)
+	account2.ID = account1.ID + 1
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	threshold := int64(100)
+	body := gin.H{
+		"from_account_id": account1.ID,
+		"to_account_id":   account2.ID,
+		"amount":          threshold + 1,
+		"currency":        util.USD,
+	}
+
+	testCases := []struct {
+		name          string
+		code          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(t *testing.T) string { return currentTOTPCode(t, user) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "MissingCode",
+			code: func(t *testing.T) string { return "" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "ReplayedCode",
+			code: func(t *testing.T) string { return currentTOTPCode(t, user) },
+			buildStubs: func(store *mockdb.MockStore) {
+				// a concurrent request used the code first
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.config.TOTPTransferThreshold = threshold
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			if code := tc.code(t); len(code) > 0 {
+				request.Header.Set(totpCodeHeader, code)
+			}
+
+			addAuthorization(t, request, server.tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateTransferTOTPRetry(t *testing.T) {
+	user := randomTOTPUser(t)
+	user.TotpEnabled = true
+	user.IsEmailVerified = true
+
+	request := transferRequest{
+		FromAccountID: util.RandomInt(1, 1000),
+		ToAccountID:   util.RandomInt(1001, 2000),
+		Amount:        101,
+		Currency:      util.USD,
+	}
+	requestHash, err := hashTransferRequest(request)
+	require.NoError(t, err)
+
+	stored := db.TransferTxResult{Transfer: db.Transfer{ID: util.RandomInt(1, 1000), Amount: request.Amount}}
+	result, err := json.Marshal(stored)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the retry carries the code the first request already used, it gets the stored transfer anyway
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetIdempotencyKey(gomock.Any(), gomock.Eq(db.GetIdempotencyKeyParams{Username: user.Username, IdempotencyKey: "key-1"})).
+		Times(1).
+		Return(db.IdempotencyKey{Username: user.Username, IdempotencyKey: "key-1", RequestHash: requestHash, Result: result}, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().IdempotentTransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+	server := NewTestServer(t, store)
+	server.config.TOTPTransferThreshold = 100
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	httpRequest, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+	httpRequest.Header.Set(totpCodeHeader, currentTOTPCode(t, user))
+	httpRequest.Header.Set(idempotencyKeyHeader, "key-1")
+
+	addAuthorization(t, httpRequest, server.tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, httpRequest)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "true", recorder.Header().Get(idempotentReplayedHeader))
+
+	var got db.TransferTxResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+	require.Equal(t, stored.Transfer.ID, got.Transfer.ID)
+}
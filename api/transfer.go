@@ -1,21 +1,35 @@
 package api
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/muditshukla3/simplebank/authz"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/fx"
 	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
 )
 
 type transferRequest struct {
-	FromAccountID int64  `json:"from_account_id" binding:"required" validate:"min=1"`
-	ToAccountID   int64  `json:"to_account_id" binding:"required" validate:"min=1"`
-	Amount        int64  `json:"amount" binding:"required" validate:"gt=0"`
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"required,currency"`
+	// ToCurrency opts into a cross-currency transfer, it defaults to Currency
+	ToCurrency string `json:"to_currency" binding:"omitempty,currency"`
 }
 
 func (server *Server) createTransfer(ctx *gin.Context) {
@@ -27,32 +41,166 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 	}
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
+	idempotencyKey := ctx.GetHeader(idempotencyKeyHeader)
+	var requestHash string
+	if len(idempotencyKey) > 0 {
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			err := fmt.Errorf("idempotency key must be at most %d characters", maxIdempotencyKeyLength)
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		var err error
+		requestHash, err = hashTransferRequest(request)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		// a retry is answered before the checks below, which would use up its totp code a second time
+		if server.replayTransfer(ctx, authPayload.Username, idempotencyKey, requestHash) {
+			return
+		}
+	}
+
 	fromAccount, valid := server.validateAccount(ctx, request.FromAccountID, request.Currency)
 	if !valid {
 		return
 	}
-	if fromAccount.Owner != authPayload.Username {
+	if !authz.CanAccess(authPayload, fromAccount.Owner, authz.OwnerOnly) {
 		err := errors.New("from account doesn't below to authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
-	_, valid = server.validateAccount(ctx, request.ToAccountID, request.Currency)
+	toCurrency := request.ToCurrency
+	if len(toCurrency) == 0 {
+		toCurrency = request.Currency
+	}
+	_, valid = server.validateAccount(ctx, request.ToAccountID, toCurrency)
 	if !valid {
 		return
 	}
+	if !server.checkTransferAmount(ctx, fromAccount.Owner, request.Amount) {
+		return
+	}
 	arg := db.TransferTxParams{
 		FromAccountID: request.FromAccountID,
 		ToAccountID:   request.ToAccountID,
 		Amount:        request.Amount,
+		Audit:         auditRequest(ctx),
 	}
 
-	result, err := server.store.TransferTx(ctx, arg)
+	if toCurrency != request.Currency {
+		if !server.applyExchangeRate(ctx, &arg, request.Currency, toCurrency) {
+			return
+		}
+	}
+
+	if len(idempotencyKey) == 0 {
+		result, err := server.store.TransferTx(ctx, arg)
+		if err != nil {
+			ctx.JSON(transferErrorStatus(err), errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+		return
+	}
+
+	result, err := server.store.IdempotentTransferTx(ctx, db.IdempotentTransferTxParams{
+		TransferTxParams: arg,
+		Username:         authPayload.Username,
+		IdempotencyKey:   idempotencyKey,
+		RequestHash:      requestHash,
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(transferErrorStatus(err), errorResponse(err))
 		return
 	}
 
+	if result.Replayed {
+		ctx.Header(idempotentReplayedHeader, "true")
+	}
+	ctx.JSON(http.StatusOK, result.TransferTxResult)
+}
+
+// replayTransfer answers with the stored result if the idempotency key has already been used for a transfer
+func (server *Server) replayTransfer(ctx *gin.Context, username, idempotencyKey, requestHash string) bool {
+	key, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username:       username,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return true
+	}
+
+	result, err := db.ReplayIdempotentTransfer(key, requestHash)
+	if err != nil {
+		ctx.JSON(transferErrorStatus(err), errorResponse(err))
+		return true
+	}
+
+	ctx.Header(idempotentReplayedHeader, "true")
 	ctx.JSON(http.StatusOK, result)
+	return true
+}
+
+// applyExchangeRate fills in the credited amount and the quote of a cross-currency transfer
+func (server *Server) applyExchangeRate(ctx *gin.Context, arg *db.TransferTxParams, from, to string) bool {
+	if server.rateProvider == nil {
+		err := fmt.Errorf("cross-currency transfers are not enabled: %s vs %s", from, to)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return false
+	}
+
+	quote, err := server.rateProvider.Quote(ctx, from, to)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	arg.ToAmount = quote.Convert(arg.Amount)
+	if arg.ToAmount <= 0 {
+		err := errors.New("amount is too small to be converted")
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return false
+	}
+	arg.ExchangeRate = quote.Rate
+	arg.SpreadBps = quote.SpreadBps
+	return true
+}
+
+// transferErrorStatus maps the errors returned by the transfer transactions to an http status
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrInsufficientFunds), errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountNotActive):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrIdempotencyKeyReused):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrInvalidTransferAmount), errors.Is(err, db.ErrSameAccountTransfer):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// hashTransferRequest fingerprints a transfer request so that a reused idempotency key
+// can be told apart from a genuine retry
+func hashTransferRequest(request transferRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 func (server *Server) validateAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
@@ -72,5 +220,103 @@ func (server *Server) validateAccount(ctx *gin.Context, accountID int64, currenc
 		return account, false
 	}
 
+	if account.Status != util.AccountStatusActive {
+		err := fmt.Errorf("account [%d] is %s", accountID, account.Status)
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return account, false
+	}
+
 	return account, true
 }
+
+// checkTransferAmount applies the safeguards of large transfers: api keys can't go above their cap, above the
+// UnverifiedTransferLimit the email of the user must be verified, above the TOTPTransferThreshold users with
+// two-factor authentication must send a fresh code in the X-TOTP-Code header
+func (server *Server) checkTransferAmount(ctx *gin.Context, username string, amount int64) bool {
+	if key, ok := ctx.Get(authorizationAPIKeyKey); ok {
+		apiKey := key.(db.ApiKey)
+		if apiKey.MaxTransferAmount.Valid && amount > apiKey.MaxTransferAmount.Int64 {
+			err := fmt.Errorf("api key can't transfer more than %d", apiKey.MaxTransferAmount.Int64)
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return false
+		}
+	}
+
+	emailLimit := server.config.UnverifiedTransferLimit
+	needsEmail := emailLimit > 0 && amount > emailLimit
+	totpThreshold := server.config.TOTPTransferThreshold
+	needsTOTP := totpThreshold > 0 && amount > totpThreshold
+	if !needsEmail && !needsTOTP {
+		return true
+	}
+
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	if needsEmail && !user.IsEmailVerified {
+		err := fmt.Errorf("email must be verified to transfer more than %d", emailLimit)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return false
+	}
+
+	if needsTOTP && user.TotpEnabled {
+		valid, err := server.useTOTPCode(ctx, user, ctx.GetHeader(totpCodeHeader))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		if !valid {
+			err := fmt.Errorf("a fresh totp code is required to transfer more than %d", totpThreshold)
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return false
+		}
+	}
+	return true
+}
+
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getTransfer returns a transfer to the owner of either account, or to a role that may view any transfer
+func (server *Server) getTransfer(ctx *gin.Context) {
+	var request getTransferRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx, request.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authz.HasPermission(authPayload.Role, authz.ViewAnyTransfer) {
+		ctx.JSON(http.StatusOK, transfer)
+		return
+	}
+
+	for _, accountID := range []int64{transfer.FromAccountID, transfer.ToAccountID} {
+		account, err := server.store.GetAccount(ctx, accountID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if account.Owner == authPayload.Username {
+			ctx.JSON(http.StatusOK, transfer)
+			return
+		}
+	}
+
+	err = errors.New("transfer doesn't belong to the authenticated user")
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+}
@@ -3,14 +3,21 @@ package api
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/muditshukla3/simplebank/authz"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
 	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
 )
 
 type createUserRequest struct {
@@ -25,6 +32,9 @@ type UserResponse struct {
 	Password          string    `json:"password"`
 	Email             string    `json:"email"`
 	FullName          string    `json:"full_name"`
+	Role              string    `json:"role"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	TOTPEnabled       bool      `json:"totp_enabled"`
 	CreatedAt         time.Time `json:"created_at"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 }
@@ -34,6 +44,9 @@ func newUserResponse(user db.User) UserResponse {
 		Username:          user.Username,
 		Email:             user.Email,
 		FullName:          user.FullName,
+		Role:              user.Role,
+		IsEmailVerified:   user.IsEmailVerified,
+		TOTPEnabled:       user.TotpEnabled,
 		CreatedAt:         user.CreatedAt,
 		PasswordChangedAt: user.PasswordChangedAt,
 	}
@@ -50,14 +63,24 @@ func (server *Server) createUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	arg := db.CreateUserParams{
-		Username: request.Username,
-		Password: hashedPassword,
-		FullName: request.FullName,
-		Email:    request.Email,
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username: request.Username,
+			Password: hashedPassword,
+			FullName: request.FullName,
+			Email:    request.Email,
+		},
+		// the task is enqueued in the transaction of the user, so no email goes out for a user that wasn't created
+		AfterCreate: func(q db.Querier, user db.User) error {
+			payload := &worker.PayloadSendVerifyEmail{Username: user.Username}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload)
+		},
+		Audit: auditRequest(ctx),
 	}
+	// signing up is public, the new user is the actor
+	arg.Audit.Actor = request.Username
 
-	user, err := server.store.CreateUser(ctx, arg)
+	user, err := server.store.CreateUserTx(ctx, arg)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			switch pqErr.Code.Name() {
@@ -74,9 +97,12 @@ func (server *Server) createUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, resp)
 }
 
+// loginUserRequest may narrow the scopes of the tokens, e.g. to accounts:read for a dashboard.
+// Without scopes the tokens get all of them
 type loginUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string        `json:"username" binding:"required"`
+	Password string        `json:"password" binding:"required"`
+	Scopes   []authz.Scope `json:"scopes"`
 }
 
 type loginUserResponse struct {
@@ -85,9 +111,13 @@ type loginUserResponse struct {
 	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
 	RefreshToken          string       `json:"refresh_token"`
 	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	Scopes                []string     `json:"scopes"`
 	User                  UserResponse `json:"user"`
 }
 
+// dummyPasswordHash stands in for the password of unknown usernames in loginUser
+var dummyPasswordHash, _ = util.HashPassword("not the password of any user")
+
 func (server *Server) loginUser(ctx *gin.Context) {
 	var request loginUserRequest
 
@@ -96,54 +126,136 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
-	user, err := server.store.GetUser(ctx, request.Username)
+	scopes, err := loginScopes(request.Scopes)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	err = util.CheckPassword(request.Password, user.Password)
+	now := time.Now()
+	retryAfter, err := server.loginGuard.Check(ctx, request.Username, ctx.ClientIP(), now)
 	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if retryAfter > 0 {
+		loginThrottled(ctx, retryAfter)
+		return
+	}
+
+	// unknown usernames are checked against a dummy hash and answered like wrong passwords,
+	// so neither the status nor the timing tells whether a username exists
+	user, err := server.store.GetUser(ctx, request.Username)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	found := err == nil
+	hashedPassword := dummyPasswordHash
+	if found {
+		hashedPassword = user.Password
+	}
+
+	if err := util.CheckPassword(request.Password, hashedPassword); err != nil || !found {
+		if err := server.loginGuard.Fail(ctx, request.Username, ctx.ClientIP(), now); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		err := errors.New("incorrect username or password")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
-	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
-		user.Username, server.config.AccessTokenDuration,
-	)
+	// with two-factor authentication the tokens are only issued by loginUserMFA,
+	// which also forgets the failures once the second factor passed
+	if user.TotpEnabled {
+		server.createMFAChallenge(ctx, user)
+		return
+	}
 
-	if err != nil {
+	if err := server.loginGuard.Succeed(ctx, user.Username); err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
-		user.Username,
-		server.config.RefreshTokenDuration,
-	)
-
+	response, err := server.createLoginSession(ctx, user, scopes)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	session, err := server.store.CreateSession(context.Background(), db.CreateSessionParams{
-		ID:           refreshPayload.ID,
-		Username:     user.Username,
-		RefreshToken: refreshToken,
-		UserAgent:    ctx.Request.UserAgent(),
-		ClientIp:     ctx.ClientIP(),
-		IsBlocked:    false,
-		ExpiresAt:    refreshPayload.ExpiredAt,
+	ctx.JSON(http.StatusOK, response)
+}
+
+// loginThrottled answers a login that has to wait retryAfter because of earlier failures
+func loginThrottled(ctx *gin.Context, retryAfter time.Duration) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	err := errors.New("too many failed logins, try again later")
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+}
+
+// loginScopes are the scopes of the tokens of a login that asked for requested
+func loginScopes(requested []authz.Scope) ([]string, error) {
+	if len(requested) == 0 {
+		return authz.ScopeStrings(authz.LoginScopes), nil
+	}
+
+	for _, scope := range requested {
+		if !authz.IsScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return authz.ScopeStrings(requested), nil
+}
+
+// createLoginSession issues the access and refresh tokens with scopes of a new session of user
+func (server *Server) createLoginSession(ctx *gin.Context, user db.User, scopes []string) (loginUserResponse, error) {
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username: user.Username,
+		Role:     user.Role,
+		Scopes:   scopes,
+		Audience: token.RefreshAudience(server.config.TokenAudience),
+		Duration: server.config.RefreshTokenDuration,
 	})
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		return loginUserResponse{}, err
+	}
+
+	// the session id is the refresh token id, access tokens carry it so that blocking the session revokes them
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: refreshPayload.ID,
+		Scopes:    scopes,
+		Audience:  server.config.TokenAudience,
+		Duration:  server.config.AccessTokenDuration,
+	})
+
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	// logging in is public, the user logging in is the actor
+	sessionAudit := auditRequest(ctx)
+	sessionAudit.Actor = user.Username
+
+	session, err := server.store.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+		CreateSessionParams: db.CreateSessionParams{
+			ID:           refreshPayload.ID,
+			Username:     user.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    ctx.Request.UserAgent(),
+			ClientIp:     ctx.ClientIP(),
+			IsBlocked:    false,
+			ExpiresAt:    refreshPayload.ExpiredAt,
+			FamilyID:     refreshPayload.ID,
+		},
+		Audit: sessionAudit,
+	})
+
+	if err != nil {
+		return loginUserResponse{}, err
 	}
 
 	response := loginUserResponse{
@@ -152,8 +264,90 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
 		RefreshToken:          refreshToken,
 		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		Scopes:                accessPayload.Scopes,
 		User:                  newUserResponse(user),
 	}
+	return response, nil
+}
 
-	ctx.JSON(http.StatusOK, response)
+type updateUserURI struct {
+	Username string `uri:"username" binding:"required"`
+}
+
+// updateUserRequest changes only the fields that are set
+type updateUserRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Password *string `json:"password" binding:"omitempty,min=6"`
+}
+
+// updateUser lets users change their own details, roles with the UpdateAnyUser permission may change anyone's.
+// A new password logs the user out of every session, a new email has to be verified again
+func (server *Server) updateUser(ctx *gin.Context) {
+	var uri updateUserURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var request updateUserRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if request.FullName == nil && request.Email == nil && request.Password == nil {
+		err := errors.New("nothing to update")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !authz.CanAccess(authPayload, uri.Username, authz.UpdateAnyUser) {
+		err := errors.New("cannot update other user's info")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{Username: uri.Username},
+		Audit:            auditRequest(ctx),
+	}
+	if request.FullName != nil {
+		arg.FullName = sql.NullString{String: *request.FullName, Valid: true}
+	}
+	if request.Email != nil {
+		arg.Email = sql.NullString{String: *request.Email, Valid: true}
+		arg.AfterUpdate = func(q db.Querier, user db.User) error {
+			if user.IsEmailVerified {
+				return nil
+			}
+			payload := &worker.PayloadSendVerifyEmail{Username: user.Username}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload)
+		}
+	}
+	if request.Password != nil {
+		hashedPassword, err := util.HashPassword(*request.Password)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		arg.Password = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	user, err := server.store.UpdateUserTx(ctx, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			ctx.JSON(http.StatusForbidden, errorResponse(pqErr))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,13 +11,18 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/lib/pq"
+	"github.com/muditshukla3/simplebank/authz"
 	mockdb "github.com/muditshukla3/simplebank/db/mock"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/lockout"
+	"github.com/muditshukla3/simplebank/token"
 	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,13 +53,13 @@ func requireBodyMatchUser(t *testing.T, body *bytes.Buffer, user db.User) {
 	require.Empty(t, gotUser.Password)
 }
 
-type eqCreateUserParamsMatcher struct {
+type eqCreateUserTxParamsMatcher struct {
 	arg      db.CreateUserParams
 	password string
 }
 
-func (e eqCreateUserParamsMatcher) Matches(x interface{}) bool {
-	arg, ok := x.(db.CreateUserParams)
+func (e eqCreateUserTxParamsMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.CreateUserTxParams)
 	if !ok {
 		return false
 	}
@@ -64,14 +70,14 @@ func (e eqCreateUserParamsMatcher) Matches(x interface{}) bool {
 	}
 
 	e.arg.Password = arg.Password
-	return reflect.DeepEqual(e.arg, arg)
+	return reflect.DeepEqual(e.arg, arg.CreateUserParams) && arg.AfterCreate != nil
 }
-func (e eqCreateUserParamsMatcher) String() string {
+func (e eqCreateUserTxParamsMatcher) String() string {
 	return fmt.Sprintf("matches arg %v and password %v", e.arg, e.password)
 }
 
-func EqCreateUserParams(arg db.CreateUserParams, password string) gomock.Matcher {
-	return eqCreateUserParamsMatcher{arg, password}
+func EqCreateUserTxParams(arg db.CreateUserParams, password string) gomock.Matcher {
+	return eqCreateUserTxParamsMatcher{arg, password}
 }
 func TestCreateUser(t *testing.T) {
 	user, password := randomUser(t)
@@ -98,8 +104,20 @@ func TestCreateUser(t *testing.T) {
 				}
 				//build stubs
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
-					Times(1).Return(user, nil)
+					CreateUserTx(gomock.Any(), EqCreateUserTxParams(arg, password)).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateUserTxParams) (db.User, error) {
+						return user, arg.AfterCreate(store, user)
+					})
+				// the verification email is enqueued with the queries passed to AfterCreate
+				store.EXPECT().
+					CreateTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendVerifyEmail, arg.Type)
+						require.JSONEq(t, fmt.Sprintf(`{"username":%q}`, user.Username), string(arg.Payload))
+						return db.Task{ID: 1, Type: arg.Type}, nil
+					})
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -116,7 +134,7 @@ func TestCreateUser(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				//build stubs
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -134,7 +152,7 @@ func TestCreateUser(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				//build stubs
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
 					Return(db.User{}, &pq.Error{Code: "23505"})
 			},
@@ -154,7 +172,7 @@ func TestCreateUser(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				//build stubs
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -194,6 +212,8 @@ func TestCreateUser(t *testing.T) {
 func TestLoginUser(t *testing.T) {
 
 	user, password := randomUser(t)
+	mfaUser := user
+	mfaUser.TotpEnabled = true
 
 	testCases := []struct {
 		name          string
@@ -213,11 +233,86 @@ func TestLoginUser(t *testing.T) {
 					Times(1).
 					Return(user, nil)
 				store.EXPECT().
-					CreateSession(gomock.Any(), gomock.Any()).
+					CreateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, authz.ScopeStrings(authz.LoginScopes), response.Scopes)
+			},
+		},
+		{
+			name: "NarrowedScopes",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{"accounts:read"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSessionTx(gomock.Any(), gomock.Any()).
 					Times(1)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, []string{"accounts:read"}, response.Scopes)
+			},
+		},
+		{
+			name: "UnknownScope",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{"accounts:everything"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "MFARequired",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(mfaUser, nil)
+				store.EXPECT().
+					CreateMFAChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateMFAChallengeParams) (db.MfaChallenge, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.WithinDuration(t, time.Now().Add(mfaChallengeDuration), arg.ExpiresAt, time.Second)
+						return db.MfaChallenge{ID: 1, Username: arg.Username, ExpiresAt: arg.ExpiresAt}, nil
+					})
+				// no tokens before the second factor
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response map[string]interface{}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, true, response["mfa_required"])
+				require.NotEmpty(t, response["mfa_token"])
+				require.NotContains(t, response, "access_token")
 			},
 		},
 		{
@@ -263,7 +358,9 @@ func TestLoginUser(t *testing.T) {
 					Return(db.User{}, sql.ErrNoRows)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				// answered like a wrong password
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "incorrect username or password")
 			},
 		},
 		{
@@ -310,3 +407,479 @@ func TestLoginUser(t *testing.T) {
 		})
 	}
 }
+
+func TestLoginUserLockout(t *testing.T) {
+	user, password := randomUser(t)
+	clientIP := "10.0.0.1"
+
+	policy := lockout.Policy{
+		MaxFailures:     5,
+		MaxIPFailures:   50,
+		Window:          15 * time.Minute,
+		BaseDelay:       time.Second,
+		LockoutDuration: 15 * time.Minute,
+	}
+	usernameKey := db.GetLoginThrottleParams{Kind: lockout.KindUsername, Subject: user.Username}
+	ipKey := db.GetLoginThrottleParams{Kind: lockout.KindIP, Subject: clientIP}
+
+	testCases := []struct {
+		name           string
+		password       string
+		forwardedFor   string
+		trustedProxies []string
+		buildStubs     func(store *mockdb.MockStore)
+		checkResponse  func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OKForgetsUsernameFailures",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).Times(2).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					DeleteLoginThrottle(gomock.Any(), gomock.Eq(db.DeleteLoginThrottleParams(usernameKey))).
+					Times(1)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			// a correct password alone doesn't forget the failures of a user with two-factor authentication
+			name:     "TOTPUserKeepsFailures",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				totpUser := user
+				totpUser.TotpEnabled = true
+
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).Times(2).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totpUser, nil)
+				store.EXPECT().DeleteLoginThrottle(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Any()).Times(1).Return(db.MfaChallenge{}, nil)
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "mfa_token")
+			},
+		},
+		{
+			name:     "WrongPasswordDelaysUsername",
+			password: "password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).Times(2).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginThrottle, error) {
+						require.WithinDuration(t, arg.FailedAt.Add(-policy.Window), arg.ResetBefore, 0)
+						if arg.Kind == lockout.KindUsername {
+							require.Equal(t, user.Username, arg.Subject)
+						} else {
+							require.Equal(t, clientIP, arg.Subject)
+						}
+						return db.LoginThrottle{Kind: arg.Kind, Subject: arg.Subject, Failures: 3}, nil
+					})
+				// only the username waits, the client ip is far from its limit
+				store.EXPECT().
+					DelayLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.DelayLoginParams) error {
+						require.Equal(t, lockout.KindUsername, arg.Kind)
+						require.WithinDuration(t, time.Now().Add(4*time.Second), arg.NextAttemptAt, time.Second)
+						return nil
+					})
+				store.EXPECT().CreateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "UnknownUsernameIsCounted",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).Times(2).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginThrottle, error) {
+						return db.LoginThrottle{Kind: arg.Kind, Subject: arg.Subject, Failures: 5}, nil
+					})
+				store.EXPECT().
+					DelayLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.DelayLoginParams) error {
+						require.Equal(t, lockout.KindUsername, arg.Kind)
+						require.WithinDuration(t, time.Now().Add(policy.LockoutDuration), arg.NextAttemptAt, time.Second)
+						return nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "incorrect username or password")
+			},
+		},
+		{
+			name:     "UsernameLocked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).
+					Times(1).
+					Return(db.LoginThrottle{Failures: 5, NextAttemptAt: time.Now().Add(time.Minute)}, nil)
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(ipKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				// even the right password is rejected without being checked
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "ClientIPLocked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetLoginThrottle(gomock.Any(), gomock.Eq(ipKey)).
+					Times(1).
+					Return(db.LoginThrottle{Failures: 50, NextAttemptAt: time.Now().Add(10 * time.Minute)}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Equal(t, "600", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			// without trusted proxies a forged header can't move the failures to a fresh ip
+			name:         "ForwardedForIgnored",
+			password:     password,
+			forwardedFor: "203.0.113.9",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetLoginThrottle(gomock.Any(), gomock.Eq(ipKey)).
+					Times(1).
+					Return(db.LoginThrottle{Failures: 50, NextAttemptAt: time.Now().Add(10 * time.Minute)}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:           "TrustedProxyForwardedFor",
+			password:       password,
+			forwardedFor:   "203.0.113.9",
+			trustedProxies: []string{clientIP},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Eq(usernameKey)).Times(1).Return(db.LoginThrottle{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetLoginThrottle(gomock.Any(), gomock.Eq(db.GetLoginThrottleParams{Kind: lockout.KindIP, Subject: "203.0.113.9"})).
+					Times(1).
+					Return(db.LoginThrottle{Failures: 50, NextAttemptAt: time.Now().Add(10 * time.Minute)}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).Times(1).Return(db.LoginThrottle{}, sql.ErrConnDone)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.loginGuard = lockout.NewGuard(store, policy)
+			server.config.TrustedProxies = tc.trustedProxies
+			require.NoError(t, server.setupRouter())
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"username": user.Username, "password": tc.password})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.RemoteAddr = clientIP + ":41234"
+			if len(tc.forwardedFor) > 0 {
+				request.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+	other, _ := randomUser(t)
+
+	newName := util.RandomOwner()
+	newEmail := util.RandomEmail()
+	newPassword := util.RandomString(8)
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "FullNameOK",
+			username: user.Username,
+			body:     gin.H{"full_name": newName},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpdateUserTxParams) (db.User, error) {
+						require.Equal(t, db.UpdateUserParams{
+							Username: user.Username,
+							FullName: sql.NullString{String: newName, Valid: true},
+						}, arg.UpdateUserParams)
+						require.Nil(t, arg.AfterUpdate)
+
+						updated := user
+						updated.FullName = newName
+						return updated, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response UserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, newName, response.FullName)
+				require.Empty(t, response.Password)
+			},
+		},
+		{
+			name:     "EmailOK",
+			username: user.Username,
+			body:     gin.H{"email": newEmail},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Email = newEmail
+				updated.IsEmailVerified = false
+
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpdateUserTxParams) (db.User, error) {
+						require.Equal(t, sql.NullString{String: newEmail, Valid: true}, arg.Email)
+						require.NoError(t, arg.AfterUpdate(store, updated))
+						return updated, nil
+					})
+				// the new email has to be verified again
+				store.EXPECT().
+					CreateTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendVerifyEmail, arg.Type)
+						return db.Task{ID: 1, Type: arg.Type}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "PasswordOK",
+			username: user.Username,
+			body:     gin.H{"password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpdateUserTxParams) (db.User, error) {
+						require.True(t, arg.Password.Valid)
+						require.NoError(t, util.CheckPassword(newPassword, arg.Password.String))
+						require.False(t, arg.FullName.Valid)
+						require.False(t, arg.Email.Valid)
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "BankerOK",
+			username: user.Username,
+			body:     gin.H{"full_name": newName},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, other.Username, util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: user.Username,
+			body:     gin.H{"full_name": newName},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, other.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NoAuthorization",
+			username: user.Username,
+			body:     gin.H{"full_name": newName},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NothingToUpdate",
+			username: user.Username,
+			body:     gin.H{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidEmail",
+			username: user.Username,
+			body:     gin.H{"email": "invalid-email"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "ShortPassword",
+			username: user.Username,
+			body:     gin.H{"password": "123"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "UserNotFound",
+			username: user.Username,
+			body:     gin.H{"full_name": newName},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "DuplicateEmail",
+			username: user.Username,
+			body:     gin.H{"email": other.Email},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationType, user.Username, util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pq.Error{Code: "23505"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s", tc.username)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+)
+
+type verifyEmailRequest struct {
+	EmailID    int64  `form:"email_id" binding:"required,min=1"`
+	SecretCode string `form:"secret_code" binding:"required,len=32"`
+}
+
+type verifyEmailResponse struct {
+	IsVerified bool         `json:"is_verified"`
+	User       UserResponse `json:"user"`
+}
+
+// verifyEmail consumes the code of the link mailed to a new user, it doesn't require a login
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var request verifyEmailRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		EmailID:    request.EmailID,
+		SecretCode: request.SecretCode,
+		Audit:      auditRequest(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidVerifyEmail) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verifyEmailResponse{
+		IsVerified: result.User.IsEmailVerified,
+		User:       newUserResponse(result.User),
+	})
+}
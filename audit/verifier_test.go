@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// runSnapshot makes the mocked ExecSnapshotTx run fn against the mock itself
+func runSnapshot(store *mockdb.MockStore) {
+	store.EXPECT().
+		ExecSnapshotTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, fn func(db.Querier) error) error {
+			return fn(store)
+		})
+}
+
+// randomChain builds n events that each link to the hash of the one before
+func randomChain(n int) []db.AuditEvent {
+	events := make([]db.AuditEvent, n)
+	prevHash := ""
+	for i := range events {
+		events[i] = db.AuditEvent{
+			ID:         int64(i + 1),
+			Actor:      util.RandomOwner(),
+			Action:     db.AuditActionCreateTransfer,
+			TargetType: db.AuditTargetTransfer,
+			TargetID:   util.RandomString(6),
+			RequestID:  util.RandomString(16),
+			Before:     json.RawMessage("null"),
+			After:      json.RawMessage(`{"amount":10}`),
+			PrevHash:   prevHash,
+			CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		}
+		events[i].Hash = db.AuditEventHash(events[i])
+		prevHash = events[i].Hash
+	}
+	return events
+}
+
+func TestAuditEventHash(t *testing.T) {
+	event := randomChain(1)[0]
+	require.Len(t, event.Hash, 64)
+	require.Equal(t, event.Hash, db.AuditEventHash(event))
+
+	// the time zone of the stored time doesn't change the hash
+	event.CreatedAt = event.CreatedAt.In(time.FixedZone("test", 3600))
+	require.Equal(t, event.Hash, db.AuditEventHash(event))
+
+	changed := event
+	changed.After = json.RawMessage(`{"amount":11}`)
+	require.NotEqual(t, event.Hash, db.AuditEventHash(changed))
+
+	// moving a character from one field to the next changes the hash
+	changed = event
+	changed.Actor = event.Actor + "x"
+	changed.Action = "x" + event.Action
+	require.NotEqual(t, event.Hash, db.AuditEventHash(changed))
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, report Report, err error)
+	}{
+		{
+			name: "Intact",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().
+					ListAuditChain(gomock.Any(), gomock.Eq(db.ListAuditChainParams{ID: 0, Limit: batchSize})).
+					Times(1).
+					Return(randomChain(3), nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.True(t, report.OK)
+				require.Equal(t, int64(3), report.EventsChecked)
+				require.Zero(t, report.BrokenEventID)
+				require.False(t, report.CheckedAt.IsZero())
+			},
+		},
+		{
+			name: "Batches",
+			buildStubs: func(store *mockdb.MockStore) {
+				chain := randomChain(batchSize + 1)
+				runSnapshot(store)
+				store.EXPECT().
+					ListAuditChain(gomock.Any(), gomock.Eq(db.ListAuditChainParams{ID: 0, Limit: batchSize})).
+					Times(1).
+					Return(chain[:batchSize], nil)
+				store.EXPECT().
+					ListAuditChain(gomock.Any(), gomock.Eq(db.ListAuditChainParams{ID: batchSize, Limit: batchSize})).
+					Times(1).
+					Return(chain[batchSize:], nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.True(t, report.OK)
+				require.Equal(t, int64(batchSize+1), report.EventsChecked)
+			},
+		},
+		{
+			name: "Tampered",
+			buildStubs: func(store *mockdb.MockStore) {
+				chain := randomChain(3)
+				chain[1].Actor = util.RandomOwner()
+				runSnapshot(store)
+				store.EXPECT().ListAuditChain(gomock.Any(), gomock.Any()).Times(1).Return(chain, nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.False(t, report.OK)
+				require.Equal(t, int64(1), report.EventsChecked)
+				require.Equal(t, int64(2), report.BrokenEventID)
+				require.NotEmpty(t, report.Reason)
+			},
+		},
+		{
+			name: "Removed",
+			buildStubs: func(store *mockdb.MockStore) {
+				chain := randomChain(3)
+				runSnapshot(store)
+				store.EXPECT().
+					ListAuditChain(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.AuditEvent{chain[0], chain[2]}, nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.False(t, report.OK)
+				require.Equal(t, int64(3), report.BrokenEventID)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().ListAuditChain(gomock.Any(), gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.False(t, report.OK)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			report, err := Verify(context.Background(), store)
+			tc.checkResponse(t, report, err)
+		})
+	}
+}
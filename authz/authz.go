@@ -0,0 +1,46 @@
+// Package authz decides what an authenticated user may do on resources they don't own.
+// Owners keep full access to their own accounts; every other access has to be granted to the user's role.
+package authz
+
+import (
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+type Permission string
+
+const (
+	// OwnerOnly is granted to no role, so only the owner passes a check against it
+	OwnerOnly       Permission = ""
+	ViewAnyAccount  Permission = "view_any_account"
+	FreezeAccount   Permission = "freeze_account"
+	ViewAnyTransfer Permission = "view_any_transfer"
+	VerifyLedger    Permission = "verify_ledger"
+	UpdateAnyUser   Permission = "update_any_user"
+	ViewAuditLog    Permission = "view_audit_log"
+)
+
+var rolePermissions = map[string][]Permission{
+	util.DepositorRole: {},
+	util.BankerRole:    {ViewAnyAccount, FreezeAccount, ViewAnyTransfer, VerifyLedger, UpdateAnyUser, ViewAuditLog},
+}
+
+// HasPermission reports whether role has been granted permission
+func HasPermission(role string, permission Permission) bool {
+	if permission == OwnerOnly {
+		return false
+	}
+
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the token holder may act on a resource owned by owner,
+// either because they own it or because their role has permission
+func CanAccess(payload *token.Payload, owner string, permission Permission) bool {
+	return payload.Username == owner || HasPermission(payload.Role, permission)
+}
@@ -0,0 +1,69 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasPermission(t *testing.T) {
+	require.True(t, HasPermission(util.BankerRole, ViewAnyAccount))
+	require.True(t, HasPermission(util.BankerRole, FreezeAccount))
+	require.True(t, HasPermission(util.BankerRole, ViewAnyTransfer))
+	require.True(t, HasPermission(util.BankerRole, VerifyLedger))
+	require.True(t, HasPermission(util.BankerRole, UpdateAnyUser))
+	require.True(t, HasPermission(util.BankerRole, ViewAuditLog))
+	require.False(t, HasPermission(util.BankerRole, OwnerOnly))
+
+	require.False(t, HasPermission(util.DepositorRole, ViewAnyAccount))
+	require.False(t, HasPermission(util.DepositorRole, FreezeAccount))
+	require.False(t, HasPermission(util.DepositorRole, ViewAnyTransfer))
+	require.False(t, HasPermission(util.DepositorRole, VerifyLedger))
+	require.False(t, HasPermission(util.DepositorRole, UpdateAnyUser))
+	require.False(t, HasPermission(util.DepositorRole, ViewAuditLog))
+
+	require.False(t, HasPermission("", ViewAnyAccount))
+	require.False(t, HasPermission("root", ViewAnyAccount))
+}
+
+func TestCanAccess(t *testing.T) {
+	owner := util.RandomOwner()
+
+	depositor := &token.Payload{Username: owner, Role: util.DepositorRole}
+	require.True(t, CanAccess(depositor, owner, ViewAnyAccount))
+	require.True(t, CanAccess(depositor, owner, OwnerOnly))
+
+	other := &token.Payload{Username: util.RandomOwner(), Role: util.DepositorRole}
+	require.False(t, CanAccess(other, owner, ViewAnyAccount))
+
+	banker := &token.Payload{Username: util.RandomOwner(), Role: util.BankerRole}
+	require.True(t, CanAccess(banker, owner, ViewAnyAccount))
+	require.False(t, CanAccess(banker, owner, OwnerOnly))
+}
+
+func TestHasScope(t *testing.T) {
+	scopes := []Scope{ScopeAccountsRead, ScopeTransfersWrite}
+	require.True(t, HasScope(scopes, ScopeAccountsRead))
+	require.True(t, HasScope(scopes, ScopeTransfersWrite))
+	require.False(t, HasScope(scopes, ScopeAccountsWrite))
+	require.False(t, HasScope(nil, ScopeAccountsRead))
+
+	require.True(t, IsAPIKeyScope(ScopeAdmin))
+	require.False(t, IsAPIKeyScope(ScopeUser))
+	require.False(t, IsAPIKeyScope("transfers:everything"))
+
+	require.True(t, IsScope(ScopeUser))
+	require.False(t, IsScope("transfers:everything"))
+	require.Equal(t, []string{"accounts:read", "transfers:write"}, ScopeStrings(scopes))
+}
+
+func TestTokenHasScope(t *testing.T) {
+	payload := &token.Payload{Scopes: ScopeStrings([]Scope{ScopeAccountsRead})}
+	require.True(t, TokenHasScope(payload, ScopeAccountsRead))
+	require.False(t, TokenHasScope(payload, ScopeTransfersWrite))
+
+	// tokens issued before scopes existed keep every scope
+	require.True(t, TokenHasScope(&token.Payload{}, ScopeTransfersWrite))
+}
@@ -0,0 +1,59 @@
+package authz
+
+import "github.com/muditshukla3/simplebank/token"
+
+// Scope limits what a credential may do on behalf of its user. Routes declare the scope they need,
+// credentials without it are rejected even if the user could do it
+type Scope string
+
+const (
+	ScopeAccountsRead   Scope = "accounts:read"
+	ScopeAccountsWrite  Scope = "accounts:write"
+	ScopeTransfersWrite Scope = "transfers:write"
+	ScopeFundingsWrite  Scope = "fundings:write"
+	// ScopeAdmin covers the routes guarded by role permissions, the role is checked on top of it
+	ScopeAdmin Scope = "admin"
+	// ScopeUser manages the user itself: profile, sessions, two-factor authentication and api keys.
+	// It is never granted to api keys, so a leaked key can't take over the user
+	ScopeUser Scope = "user"
+)
+
+// LoginScopes are the scopes of the tokens issued at login, unless the client asks for fewer
+var LoginScopes = []Scope{ScopeUser, ScopeAccountsRead, ScopeAccountsWrite, ScopeTransfersWrite, ScopeFundingsWrite, ScopeAdmin}
+
+// APIKeyScopes are the scopes an api key may be created with
+var APIKeyScopes = []Scope{ScopeAccountsRead, ScopeAccountsWrite, ScopeTransfersWrite, ScopeFundingsWrite, ScopeAdmin}
+
+// IsAPIKeyScope reports whether an api key may be granted scope
+func IsAPIKeyScope(scope Scope) bool {
+	return HasScope(APIKeyScopes, scope)
+}
+
+// IsScope reports whether scope is known
+func IsScope(scope Scope) bool {
+	return HasScope(LoginScopes, scope)
+}
+
+// ScopeStrings converts scopes for token payloads and api keys
+func ScopeStrings(scopes []Scope) []string {
+	strs := make([]string, len(scopes))
+	for i, scope := range scopes {
+		strs[i] = string(scope)
+	}
+	return strs
+}
+
+// TokenHasScope reports whether the token or api key that payload came from grants scope
+func TokenHasScope(payload *token.Payload, scope Scope) bool {
+	return payload.HasScope(string(scope))
+}
+
+// HasScope reports whether scopes include scope
+func HasScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS "sessions" DROP COLUMN IF EXISTS "used_at";
+
+ALTER TABLE IF EXISTS "sessions" DROP COLUMN IF EXISTS "family_id";
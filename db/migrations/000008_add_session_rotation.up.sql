@@ -0,0 +1,13 @@
+ALTER TABLE "sessions" ADD COLUMN "family_id" uuid;
+
+UPDATE "sessions" SET "family_id" = "id";
+
+ALTER TABLE "sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+ALTER TABLE "sessions" ADD COLUMN "used_at" timestamptz;
+
+CREATE INDEX ON "sessions" ("family_id");
+
+COMMENT ON COLUMN "sessions"."family_id" IS 'id of the login session this one was rotated from';
+
+COMMENT ON COLUMN "sessions"."used_at" IS 'set once the refresh token has been exchanged for a successor';
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "closed_at";
+
+COMMENT ON COLUMN "accounts"."status" IS 'active or frozen';
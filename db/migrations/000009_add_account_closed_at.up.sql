@@ -0,0 +1,3 @@
+ALTER TABLE "accounts" ADD COLUMN "closed_at" timestamptz;
+
+COMMENT ON COLUMN "accounts"."status" IS 'active, frozen or closed';
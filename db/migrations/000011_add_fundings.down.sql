@@ -0,0 +1,9 @@
+ALTER TABLE IF EXISTS "entries" DROP COLUMN IF EXISTS "funding_id";
+
+DROP TABLE IF EXISTS "fundings";
+
+DELETE FROM "entries" WHERE "account_id" IN (SELECT "id" FROM "accounts" WHERE "owner" = 'simplebank');
+
+DELETE FROM "accounts" WHERE "owner" = 'simplebank';
+
+DELETE FROM "users" WHERE "username" = 'simplebank';
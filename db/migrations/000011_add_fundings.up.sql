@@ -0,0 +1,38 @@
+CREATE TABLE "fundings" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "kind" varchar NOT NULL,
+  "amount" bigint NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "provider_reference" varchar NOT NULL DEFAULT '',
+  "failure_reason" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "completed_at" timestamptz
+);
+
+ALTER TABLE "fundings" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "fundings" ADD CONSTRAINT "funding_amount_positive" CHECK ("amount" > 0);
+
+CREATE INDEX ON "fundings" ("account_id");
+
+ALTER TABLE "entries" ADD COLUMN "funding_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("funding_id") REFERENCES "fundings" ("id");
+
+CREATE INDEX ON "entries" ("funding_id");
+
+-- the system user can't log in, its empty password never matches a bcrypt hash
+INSERT INTO "users" ("username", "password", "full_name", "email", "role")
+VALUES ('simplebank', '', 'Simple Bank settlement', 'settlement@simplebank.invalid', 'system');
+
+INSERT INTO "accounts" ("owner", "balance", "currency")
+VALUES ('simplebank', 0, 'USD'), ('simplebank', 0, 'EUR'), ('simplebank', 0, 'CAD');
+
+COMMENT ON COLUMN "fundings"."kind" IS 'deposit or withdrawal';
+
+COMMENT ON COLUMN "fundings"."status" IS 'pending, settled or failed';
+
+COMMENT ON COLUMN "fundings"."provider_reference" IS 'id of the funding at the external provider';
+
+COMMENT ON COLUMN "entries"."funding_id" IS 'deposit or withdrawal that produced the entry, if any';
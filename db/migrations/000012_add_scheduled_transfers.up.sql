@@ -0,0 +1,39 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "frequency" varchar NOT NULL,
+  "start_at" timestamptz NOT NULL,
+  "next_run_at" timestamptz NOT NULL,
+  "runs" integer NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "last_run_at" timestamptz,
+  "last_transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("last_transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_amount_positive" CHECK ("amount" > 0);
+
+CREATE INDEX ON "scheduled_transfers" ("owner");
+
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "status" = 'active';
+
+COMMENT ON COLUMN "scheduled_transfers"."frequency" IS 'once, daily, weekly or monthly';
+
+COMMENT ON COLUMN "scheduled_transfers"."runs" IS 'occurrences executed or skipped so far';
+
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'active, paused, completed or failed';
+
+COMMENT ON COLUMN "scheduled_transfers"."attempts" IS 'failed attempts of the current occurrence';
@@ -0,0 +1,22 @@
+CREATE TABLE "tasks" (
+  "id" bigserial PRIMARY KEY,
+  "type" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "run_at" timestamptz NOT NULL DEFAULT (now()),
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "completed_at" timestamptz
+);
+
+CREATE INDEX ON "tasks" ("run_at") WHERE "status" = 'pending';
+
+CREATE INDEX ON "tasks" ("status");
+
+COMMENT ON COLUMN "tasks"."status" IS 'pending, completed or dead';
+
+COMMENT ON COLUMN "tasks"."attempts" IS 'times the task has been picked up by a processor';
+
+COMMENT ON COLUMN "tasks"."run_at" IS 'when the task is due, pushed back by the lease of the processor running it';
@@ -0,0 +1,35 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "users" ADD COLUMN "totp_enabled" bool NOT NULL DEFAULT false;
+
+ALTER TABLE "users" ADD COLUMN "totp_last_used_step" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("username", "code_hash");
+
+CREATE TABLE "mfa_challenges" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "user_agent" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expires_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "mfa_challenges" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+COMMENT ON COLUMN "users"."totp_last_used_step" IS 'the last accepted totp period, codes of it and earlier periods are rejected as replays';
+
+COMMENT ON COLUMN "mfa_challenges"."token_hash" IS 'sha256 of the challenge token returned by the login, the token itself is not stored';
@@ -0,0 +1,14 @@
+CREATE TABLE "login_throttles" (
+  "kind" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "failures" int NOT NULL DEFAULT 0,
+  "last_failed_at" timestamptz NOT NULL DEFAULT (now()),
+  "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("kind", "subject")
+);
+
+COMMENT ON COLUMN "login_throttles"."kind" IS 'username or ip';
+
+COMMENT ON COLUMN "login_throttles"."failures" IS 'failed logins since the last success, counted again from one after a quiet window';
+
+COMMENT ON COLUMN "login_throttles"."next_attempt_at" IS 'logins of the subject are rejected before this time';
@@ -0,0 +1,25 @@
+CREATE TABLE "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "key_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "max_transfer_amount" bigint,
+  "last_used_at" timestamptz,
+  "expires_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "api_keys" ("username");
+
+COMMENT ON COLUMN "api_keys"."prefix" IS 'public part of the key to look it up by';
+
+COMMENT ON COLUMN "api_keys"."key_hash" IS 'sha256 of the whole key, the key itself is not stored';
+
+COMMENT ON COLUMN "api_keys"."max_transfer_amount" IS 'largest transfer the key may make, no limit when null';
+
+COMMENT ON COLUMN "api_keys"."expires_at" IS 'the key never expires when null';
@@ -0,0 +1,11 @@
+ALTER TABLE "accounts" ADD COLUMN "status_reason" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "accounts" ADD COLUMN "status_changed_at" timestamptz NOT NULL DEFAULT (now());
+
+UPDATE "accounts" SET "status_changed_at" = COALESCE("closed_at", "created_at");
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_status_check" CHECK ("status" IN ('active', 'frozen', 'closed'));
+
+COMMENT ON COLUMN "accounts"."status_reason" IS 'why the account was last frozen or unfrozen';
+
+COMMENT ON COLUMN "accounts"."status_changed_at" IS 'when the status last changed';
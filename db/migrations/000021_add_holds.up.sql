@@ -0,0 +1,40 @@
+ALTER TABLE "accounts" ADD COLUMN "held_amount" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts" ADD COLUMN "available_balance" bigint NOT NULL GENERATED ALWAYS AS ("balance" - "held_amount") STORED;
+
+COMMENT ON COLUMN "accounts"."held_amount" IS 'sum of the active holds on the account';
+
+COMMENT ON COLUMN "accounts"."available_balance" IS 'balance minus held_amount, what debits may spend';
+
+CREATE TABLE "holds" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "captured_amount" bigint NOT NULL DEFAULT 0,
+  "transfer_id" bigint,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "closed_at" timestamptz
+);
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_amount_check" CHECK ("amount" > 0 AND "captured_amount" BETWEEN 0 AND "amount");
+
+CREATE INDEX ON "holds" ("account_id");
+
+CREATE INDEX ON "holds" ("expires_at") WHERE "status" = 'active';
+
+COMMENT ON COLUMN "holds"."amount" IS 'reserved on account_id, must be positive';
+
+COMMENT ON COLUMN "holds"."status" IS 'active, captured, released or expired';
+
+COMMENT ON COLUMN "holds"."captured_amount" IS 'moved to to_account_id by the capture, the rest was released';
+
+COMMENT ON COLUMN "holds"."transfer_id" IS 'the transfer of the capture';
@@ -0,0 +1 @@
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_amount_check" CHECK ("amount" > 0 AND "to_amount" > 0);
@@ -0,0 +1,3 @@
+UPDATE "scheduled_transfers" SET "status" = 'failed' WHERE "status" = 'cancelled';
+
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'active, paused, completed or failed';
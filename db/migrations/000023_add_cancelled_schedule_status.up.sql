@@ -0,0 +1 @@
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'active, paused, completed, failed or cancelled';
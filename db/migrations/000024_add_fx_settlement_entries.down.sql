@@ -0,0 +1,19 @@
+-- the settlement entries of a transfer are those on a settlement account that is neither side of the transfer,
+-- same-currency transfers to or from a settlement account keep their entries
+WITH "removed" AS (
+  DELETE FROM "entries" e
+  USING "transfers" t, "accounts" s
+  WHERE e."transfer_id" = t."id"
+    AND s."id" = e."account_id"
+    AND s."owner" = 'simplebank'
+    AND e."account_id" <> t."from_account_id"
+    AND e."account_id" <> t."to_account_id"
+  RETURNING e."account_id", e."amount"
+)
+UPDATE "accounts" a SET "balance" = a."balance" - legs."total"
+FROM (
+  SELECT "account_id", SUM("amount") AS "total"
+  FROM "removed"
+  GROUP BY "account_id"
+) legs
+WHERE a."id" = legs."account_id";
@@ -0,0 +1,26 @@
+-- cross-currency transfers are posted through the settlement accounts of both currencies,
+-- book the settlement entries of the transfers made before and add only those to the settlement balances
+WITH "backfilled" AS (
+  INSERT INTO "entries" ("account_id", "amount", "transfer_id", "created_at")
+  SELECT s."id", t."amount", t."id", t."created_at"
+  FROM "transfers" t
+  JOIN "accounts" fa ON fa."id" = t."from_account_id"
+  JOIN "accounts" ta ON ta."id" = t."to_account_id"
+  JOIN "accounts" s ON s."owner" = 'simplebank' AND s."currency" = fa."currency"
+  WHERE fa."currency" <> ta."currency"
+  UNION ALL
+  SELECT s."id", -t."to_amount", t."id", t."created_at"
+  FROM "transfers" t
+  JOIN "accounts" fa ON fa."id" = t."from_account_id"
+  JOIN "accounts" ta ON ta."id" = t."to_account_id"
+  JOIN "accounts" s ON s."owner" = 'simplebank' AND s."currency" = ta."currency"
+  WHERE fa."currency" <> ta."currency"
+  RETURNING "account_id", "amount"
+)
+UPDATE "accounts" a SET "balance" = a."balance" + legs."total"
+FROM (
+  SELECT "account_id", SUM("amount") AS "total"
+  FROM "backfilled"
+  GROUP BY "account_id"
+) legs
+WHERE a."id" = legs."account_id";
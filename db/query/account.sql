@@ -17,10 +17,11 @@ FOR NO KEY UPDATE;
 
 -- name: ListAccounts :many
 SELECT * FROM accounts
-WHERE owner = $1
+WHERE owner = sqlc.arg(owner)
+AND (status <> 'closed' OR sqlc.arg(include_closed)::boolean)
 ORDER BY id
-LIMIT $2
-OFFSET $3;
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
 
 -- name: AddAccountBalance :one
 UPDATE accounts SET balance = balance + sqlc.arg(amount)
@@ -28,4 +29,20 @@ WHERE id = sqlc.arg(id)
 RETURNING *;
 
 -- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1;
\ No newline at end of file
+DELETE FROM accounts WHERE id = $1;
+
+-- name: UpdateAccountStatus :one
+UPDATE accounts SET status = sqlc.arg(status), status_reason = sqlc.arg(status_reason), status_changed_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+
+-- name: CloseAccount :one
+UPDATE accounts SET status = 'closed', closed_at = now(), status_changed_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: AddAccountHeldAmount :one
+UPDATE accounts SET held_amount = held_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
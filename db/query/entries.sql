@@ -1,8 +1,8 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
-  account_id, amount
+  account_id, amount, transfer_id, funding_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4
 )
 RETURNING *;
 
@@ -23,4 +23,18 @@ WHERE id = $1
 RETURNING *;
 
 -- name: DeleteEntry :exec
-DELETE FROM entries WHERE id = $1;
\ No newline at end of file
+DELETE FROM entries WHERE id = $1;
+
+-- name: ListAccountEntries :many
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id)
+  AND (sqlc.narg(from_time)::timestamptz IS NULL OR created_at >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamptz IS NULL OR created_at < sqlc.narg(to_time))
+  AND (
+    sqlc.narg(direction)::varchar IS NULL OR
+    (sqlc.narg(direction) = 'incoming' AND amount > 0) OR
+    (sqlc.narg(direction) = 'outgoing' AND amount < 0)
+  )
+  AND (sqlc.narg(cursor)::bigint IS NULL OR id < sqlc.narg(cursor))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,37 @@
+-- name: CreateFunding :one
+INSERT INTO fundings (
+  account_id, kind, amount
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetFunding :one
+SELECT * FROM fundings
+WHERE id = $1 LIMIT 1;
+
+-- name: GetFundingForUpdate :one
+SELECT * FROM fundings
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: SetFundingReference :one
+UPDATE fundings SET provider_reference = sqlc.arg(provider_reference)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CompleteFunding :one
+UPDATE fundings SET
+  status = sqlc.arg(status),
+  failure_reason = sqlc.arg(failure_reason),
+  completed_at = now()
+WHERE id = sqlc.arg(id) AND status = 'pending'
+RETURNING *;
+
+-- name: GetSettlementAccount :one
+SELECT * FROM accounts
+WHERE owner = 'simplebank' AND currency = $1 LIMIT 1;
+
+-- name: CountPendingFundings :one
+SELECT count(*) FROM fundings
+WHERE account_id = $1 AND status = 'pending';
@@ -0,0 +1,38 @@
+-- name: CreateHold :one
+INSERT INTO holds (
+  account_id, to_account_id, amount, expires_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetExpiredHoldForUpdate :one
+-- concurrent sweepers skip the hold another one is expiring
+SELECT * FROM holds
+WHERE status = 'active' AND expires_at <= sqlc.arg(now)
+ORDER BY expires_at
+LIMIT 1
+FOR NO KEY UPDATE SKIP LOCKED;
+
+-- name: ListAccountHolds :many
+SELECT * FROM holds
+WHERE account_id = sqlc.arg(account_id)
+AND (sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: CloseHold :one
+UPDATE holds
+SET status = sqlc.arg(status), captured_amount = sqlc.arg(captured_amount), transfer_id = sqlc.narg(transfer_id), closed_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
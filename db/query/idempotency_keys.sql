@@ -0,0 +1,21 @@
+-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username, idempotency_key, request_hash
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2 LIMIT 1;
+
+-- name: UpdateIdempotencyKeyResult :one
+UPDATE idempotency_keys SET result = $3
+WHERE username = $1 AND idempotency_key = $2
+RETURNING *;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2;
@@ -0,0 +1,31 @@
+-- name: ListBalanceDiscrepancies :many
+SELECT a.id AS account_id, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: ListTransferDiscrepancies :many
+-- a cross-currency transfer has two more entries on the settlement accounts of its currencies
+SELECT
+  t.id AS transfer_id,
+  COUNT(e.id) FILTER (WHERE e.account_id = t.from_account_id AND e.amount = -t.amount) AS debit_entries,
+  COUNT(e.id) FILTER (WHERE e.account_id = t.to_account_id AND e.amount = t.to_amount) AS credit_entries,
+  COUNT(e.id) AS linked_entries
+FROM transfers t
+JOIN accounts fa ON fa.id = t.from_account_id
+JOIN accounts ta ON ta.id = t.to_account_id
+LEFT JOIN entries e ON e.transfer_id = t.id
+GROUP BY t.id, fa.currency, ta.currency
+HAVING COUNT(e.id) FILTER (WHERE e.account_id = t.from_account_id AND e.amount = -t.amount) <> 1
+  OR COUNT(e.id) FILTER (WHERE e.account_id = t.to_account_id AND e.amount = t.to_amount) <> 1
+  OR COUNT(e.id) <> CASE WHEN fa.currency = ta.currency THEN 2 ELSE 4 END
+ORDER BY t.id;
+
+-- name: SumEntriesByCurrency :many
+SELECT a.currency, SUM(e.amount)::bigint AS total
+FROM entries e
+JOIN accounts a ON a.id = e.account_id
+GROUP BY a.currency
+ORDER BY a.currency;
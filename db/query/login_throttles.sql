@@ -0,0 +1,26 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE kind = $1 AND subject = $2 LIMIT 1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (
+  kind, subject, failures, last_failed_at
+) VALUES (
+  sqlc.arg(kind), sqlc.arg(subject), 1, sqlc.arg(failed_at)
+)
+ON CONFLICT (kind, subject) DO UPDATE SET
+  failures = CASE
+    WHEN login_throttles.last_failed_at < sqlc.arg(reset_before)::timestamptz THEN 1
+    ELSE login_throttles.failures + 1
+  END,
+  last_failed_at = EXCLUDED.last_failed_at
+RETURNING *;
+
+-- name: DelayLogin :exec
+UPDATE login_throttles SET
+  next_attempt_at = $3
+WHERE kind = $1 AND subject = $2;
+
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE kind = $1 AND subject = $2;
@@ -0,0 +1,22 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (
+  username, token_hash, expired_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: UsePasswordReset :one
+UPDATE password_resets SET
+  used_at = now()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expired_at > now()
+RETURNING *;
+
+-- name: ExpirePasswordResets :exec
+UPDATE password_resets SET
+  expired_at = now()
+WHERE username = $1
+  AND used_at IS NULL
+  AND expired_at > now();
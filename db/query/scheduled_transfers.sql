@@ -0,0 +1,61 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $6
+)
+RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: GetScheduledTransferForUpdate :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers SET
+  amount = COALESCE(sqlc.narg(amount), amount),
+  status = COALESCE(sqlc.narg(status), status),
+  runs = COALESCE(sqlc.narg(runs), runs),
+  next_run_at = COALESCE(sqlc.narg(next_run_at), next_run_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteScheduledTransfer :exec
+DELETE FROM scheduled_transfers WHERE id = $1;
+
+-- name: GetDueScheduledTransferForUpdate :one
+SELECT * FROM scheduled_transfers
+WHERE status = 'active' AND next_run_at <= sqlc.arg(now)
+ORDER BY next_run_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateScheduledTransferRun :one
+UPDATE scheduled_transfers SET
+  runs = sqlc.arg(runs),
+  next_run_at = sqlc.arg(next_run_at),
+  status = sqlc.arg(status),
+  attempts = sqlc.arg(attempts),
+  last_error = sqlc.arg(last_error),
+  last_run_at = sqlc.arg(last_run_at),
+  last_transfer_id = COALESCE(sqlc.narg(last_transfer_id), last_transfer_id)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CancelAccountScheduledTransfers :many
+UPDATE scheduled_transfers SET
+  status = 'cancelled'
+WHERE (from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id))
+  AND status IN ('active', 'paused')
+RETURNING *;
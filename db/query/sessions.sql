@@ -6,9 +6,10 @@ INSERT INTO sessions (
   user_agent,
   client_ip,
   is_blocked,
-  expires_at
+  expires_at,
+  family_id
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7
+  $1, $2, $3, $4, $5, $6, $7, $8
 )
 RETURNING *;
 
@@ -17,4 +18,26 @@ SELECT * FROM sessions
 WHERE id = $1 LIMIT 1;
 
 -- name: DeleteSession :exec
-DELETE FROM sessions WHERE id = $1;
\ No newline at end of file
+DELETE FROM sessions WHERE id = $1;
+
+-- name: MarkSessionUsed :one
+UPDATE sessions SET used_at = now()
+WHERE id = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: BlockSessionFamily :many
+UPDATE sessions SET is_blocked = true
+WHERE family_id = $1
+RETURNING *;
+
+-- name: ListActiveSessions :many
+SELECT * FROM sessions
+WHERE username = $1
+  AND is_blocked = false
+  AND used_at IS NULL
+  AND expires_at > now()
+ORDER BY created_at DESC;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions SET is_blocked = true
+WHERE username = $1;
@@ -0,0 +1,48 @@
+-- name: CreateTask :one
+INSERT INTO tasks (
+  type, payload, max_attempts, run_at
+) VALUES (
+  sqlc.arg(type), sqlc.arg(payload), sqlc.arg(max_attempts), sqlc.arg(run_at)
+)
+RETURNING *;
+
+-- name: GetTask :one
+SELECT * FROM tasks
+WHERE id = $1 LIMIT 1;
+
+-- name: ClaimTask :one
+UPDATE tasks SET
+  attempts = attempts + 1,
+  run_at = sqlc.arg(lease_until)
+WHERE id = (
+  SELECT t.id FROM tasks t
+  WHERE t.status = 'pending' AND t.run_at <= sqlc.arg(now)
+  ORDER BY t.run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteTask :execrows
+UPDATE tasks SET status = 'completed', last_error = '', completed_at = now()
+WHERE id = sqlc.arg(id) AND attempts = sqlc.arg(attempts) AND status = 'pending';
+
+-- name: RetryTask :execrows
+UPDATE tasks SET run_at = sqlc.arg(run_at), last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id) AND attempts = sqlc.arg(attempts) AND status = 'pending';
+
+-- name: DeadLetterTask :execrows
+UPDATE tasks SET status = 'dead', last_error = sqlc.arg(last_error), completed_at = now()
+WHERE id = sqlc.arg(id) AND attempts = sqlc.arg(attempts) AND status = 'pending';
+
+-- name: ListDeadTasks :many
+SELECT * FROM tasks
+WHERE status = 'dead'
+ORDER BY id
+LIMIT $1
+OFFSET $2;
+
+-- name: RequeueDeadTask :one
+UPDATE tasks SET status = 'pending', attempts = 0, run_at = now(), completed_at = NULL
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
@@ -0,0 +1,64 @@
+-- name: SetTOTPSecret :one
+UPDATE users SET
+  totp_secret = $2
+WHERE username = $1
+  AND totp_enabled = false
+RETURNING *;
+
+-- name: EnableTOTP :one
+UPDATE users SET
+  totp_enabled = true,
+  totp_last_used_step = $2
+WHERE username = $1
+  AND totp_enabled = false
+  AND totp_secret <> ''
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+UPDATE users SET
+  totp_last_used_step = $2
+WHERE username = $1
+  AND totp_enabled = true
+  AND totp_last_used_step < $2;
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username, code_hash
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET
+  used_at = now()
+WHERE username = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
+
+-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (
+  username, token_hash, user_agent, client_ip, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: AttemptMFAChallenge :one
+UPDATE mfa_challenges SET
+  attempts = attempts + 1
+WHERE token_hash = sqlc.arg(token_hash)
+  AND used_at IS NULL
+  AND expires_at > now()
+  AND attempts < sqlc.arg(max_attempts)::int
+RETURNING *;
+
+-- name: CompleteMFAChallenge :execrows
+UPDATE mfa_challenges SET
+  used_at = now()
+WHERE id = $1
+  AND used_at IS NULL;
@@ -1,8 +1,8 @@
 -- name: CreateTransfer :one
 INSERT INTO transfers (
-  from_account_id, to_account_id, amount
+  from_account_id, to_account_id, amount, to_amount, exchange_rate, spread_bps
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
@@ -20,4 +20,17 @@ LIMIT $3
 OFFSET $4;
 
 -- name: DeleteTransfer :exec
-DELETE FROM transfers WHERE id = $1;
\ No newline at end of file
+DELETE FROM transfers WHERE id = $1;
+
+-- name: ListAccountTransfers :many
+SELECT * FROM transfers
+WHERE (
+    (sqlc.narg(direction)::varchar IS NULL AND (from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id))) OR
+    (sqlc.narg(direction) = 'outgoing' AND from_account_id = sqlc.arg(account_id)) OR
+    (sqlc.narg(direction) = 'incoming' AND to_account_id = sqlc.arg(account_id))
+  )
+  AND (sqlc.narg(from_time)::timestamptz IS NULL OR created_at >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamptz IS NULL OR created_at < sqlc.narg(to_time))
+  AND (sqlc.narg(cursor)::bigint IS NULL OR id < sqlc.narg(cursor))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size);
@@ -11,4 +11,30 @@ SELECT * FROM users
 WHERE username = $1 LIMIT 1;
 
 -- name: DeleteUser :exec
-DELETE FROM users WHERE username = $1;
\ No newline at end of file
+DELETE FROM users WHERE username = $1;
+
+-- name: SetUserEmailVerified :one
+UPDATE users SET
+  is_email_verified = true
+WHERE username = sqlc.arg(username)
+  AND email = sqlc.arg(email)
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users SET
+  password = COALESCE(sqlc.narg(password), password),
+  password_changed_at = COALESCE(sqlc.narg(password_changed_at), password_changed_at),
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  is_email_verified = is_email_verified AND COALESCE(sqlc.narg(email) = email, true)
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 LIMIT 1;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE username = $1 LIMIT 1
+FOR NO KEY UPDATE;
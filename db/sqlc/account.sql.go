@@ -12,7 +12,7 @@ import (
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance
 `
 
 type AddAccountBalanceParams struct {
@@ -29,6 +29,70 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+	)
+	return i, err
+}
+
+const addAccountHeldAmount = `-- name: AddAccountHeldAmount :one
+UPDATE accounts SET held_amount = held_amount + $1
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance
+`
+
+type AddAccountHeldAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, addAccountHeldAmount, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+	)
+	return i, err
+}
+
+const closeAccount = `-- name: CloseAccount :one
+UPDATE accounts SET status = 'closed', closed_at = now(), status_changed_at = now()
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance
+`
+
+func (q *Queries) CloseAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, closeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
 	)
 	return i, err
 }
@@ -39,7 +103,7 @@ INSERT INTO accounts (
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance
 `
 
 type CreateAccountParams struct {
@@ -57,6 +121,13 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
 	)
 	return i, err
 }
@@ -71,7 +142,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -84,12 +155,19 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance FROM accounts
 WHERE id = $1 LIMIT 1 
 FOR NO KEY UPDATE
 `
@@ -103,26 +181,40 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance FROM accounts
 WHERE owner = $1
+AND (status <> 'closed' OR $2::boolean)
 ORDER BY id
-LIMIT $2
+LIMIT $4
 OFFSET $3
 `
 
 type ListAccountsParams struct {
-	Owner  string `json:"owner"`
-	Limit  int32  `json:"limit"`
-	Offset int32  `json:"offset"`
+	Owner         string `json:"owner"`
+	IncludeClosed bool   `json:"include_closed"`
+	Offset        int32  `json:"offset"`
+	Limit         int32  `json:"limit"`
 }
 
 func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
-	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Owner, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listAccounts,
+		arg.Owner,
+		arg.IncludeClosed,
+		arg.Offset,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +228,13 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
+			&i.ClosedAt,
+			&i.StatusReason,
+			&i.StatusChangedAt,
+			&i.HeldAmount,
+			&i.AvailableBalance,
 		); err != nil {
 			return nil, err
 		}
@@ -149,3 +248,35 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	}
 	return items, nil
 }
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :one
+UPDATE accounts SET status = $1, status_reason = $2, status_changed_at = now()
+WHERE id = $3
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance
+`
+
+type UpdateAccountStatusParams struct {
+	Status       string `json:"status"`
+	StatusReason string `json:"status_reason"`
+	ID           int64  `json:"id"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountStatus, arg.Status, arg.StatusReason, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+	)
+	return i, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
@@ -93,6 +94,56 @@ func TestListAccounts(t *testing.T) {
 	cleanUpUser(t, user.Username)
 }
 
+func TestListAccountsClosed(t *testing.T) {
+	account, _, user, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+
+	_, err = testQueries.CloseAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+
+	arg := ListAccountsParams{
+		Owner:  account.Owner,
+		Limit:  5,
+		Offset: 0,
+	}
+	accounts, err := testQueries.ListAccounts(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+
+	arg.IncludeClosed = true
+	accounts, err = testQueries.ListAccounts(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, util.AccountStatusClosed, accounts[0].Status)
+
+	// cleanup account
+	cleanUpAccount(t, int(account.ID))
+	// cleanup user
+	cleanUpUser(t, user.Username)
+}
+
+func TestUpdateAccountStatus(t *testing.T) {
+	account1, _, user, _ := createRandomTestAccount(t)
+	require.Equal(t, util.AccountStatusActive, account1.Status)
+
+	account2, err := testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:           account1.ID,
+		Status:       util.AccountStatusFrozen,
+		StatusReason: "suspicious activity",
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, account1.Balance, account2.Balance)
+	require.Equal(t, util.AccountStatusFrozen, account2.Status)
+	require.Equal(t, "suspicious activity", account2.StatusReason)
+	require.WithinDuration(t, time.Now(), account2.StatusChangedAt, time.Second)
+
+	// cleanup account
+	cleanUpAccount(t, int(account1.ID))
+	// cleanup user
+	cleanUpUser(t, user.Username)
+}
+
 func cleanUpAccount(t *testing.T, accountId int) {
 	err := testQueries.DeleteAccount(context.Background(), int64(accountId))
 	require.NoError(t, err)
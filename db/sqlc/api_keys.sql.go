@@ -0,0 +1,181 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: api_keys.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  username, name, prefix, key_hash, scopes, max_transfer_amount, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, username, name, prefix, key_hash, scopes, max_transfer_amount, last_used_at, expires_at, revoked_at, created_at
+`
+
+type CreateAPIKeyParams struct {
+	Username          string        `json:"username"`
+	Name              string        `json:"name"`
+	Prefix            string        `json:"prefix"`
+	KeyHash           string        `json:"key_hash"`
+	Scopes            []string      `json:"scopes"`
+	MaxTransferAmount sql.NullInt64 `json:"max_transfer_amount"`
+	ExpiresAt         sql.NullTime  `json:"expires_at"`
+}
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.Username,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		pq.Array(arg.Scopes),
+		arg.MaxTransferAmount,
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.MaxTransferAmount,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeyByPrefix = `-- name: GetAPIKeyByPrefix :one
+SELECT id, username, name, prefix, key_hash, scopes, max_transfer_amount, last_used_at, expires_at, revoked_at, created_at FROM api_keys
+WHERE prefix = $1 LIMIT 1
+`
+
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByPrefix, prefix)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.MaxTransferAmount,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT id, username, name, prefix, key_hash, scopes, max_transfer_amount, last_used_at, expires_at, revoked_at, created_at FROM api_keys
+WHERE username = $1
+ORDER BY id
+`
+
+func (q *Queries) ListAPIKeys(ctx context.Context, username string) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIKeys, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApiKey{}
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			pq.Array(&i.Scopes),
+			&i.MaxTransferAmount,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :one
+UPDATE api_keys SET
+  revoked_at = now()
+WHERE id = $1
+  AND username = $2
+  AND revoked_at IS NULL
+RETURNING id, username, name, prefix, key_hash, scopes, max_transfer_amount, last_used_at, expires_at, revoked_at, created_at
+`
+
+type RevokeAPIKeyParams struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, revokeAPIKey, arg.ID, arg.Username)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.MaxTransferAmount,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const revokeUserAPIKeys = `-- name: RevokeUserAPIKeys :exec
+UPDATE api_keys SET
+  revoked_at = now()
+WHERE username = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserAPIKeys(ctx context.Context, username string) error {
+	_, err := q.db.ExecContext(ctx, revokeUserAPIKeys, username)
+	return err
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys SET
+  last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+`
+
+func (q *Queries) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, touchAPIKey, id)
+	return err
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAPIKey(t *testing.T, username string) ApiKey {
+	arg := CreateAPIKeyParams{
+		Username:          username,
+		Name:              util.RandomOwner(),
+		Prefix:            util.RandomString(16),
+		KeyHash:           util.HashSecretToken(util.RandomString(32)),
+		Scopes:            []string{"accounts:read", "transfers:write"},
+		MaxTransferAmount: sql.NullInt64{Int64: 100, Valid: true},
+		ExpiresAt:         sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	apiKey, err := testQueries.CreateAPIKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, apiKey.Username)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.KeyHash, apiKey.KeyHash)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.Equal(t, arg.MaxTransferAmount, apiKey.MaxTransferAmount)
+	require.WithinDuration(t, arg.ExpiresAt.Time, apiKey.ExpiresAt.Time, time.Second)
+	require.False(t, apiKey.LastUsedAt.Valid)
+	require.False(t, apiKey.RevokedAt.Valid)
+	return apiKey
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	found, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, found.ID)
+
+	_, err = testQueries.GetAPIKeyByPrefix(context.Background(), util.RandomString(16))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomAPIKey(t, user.Username)
+	}
+	createRandomAPIKey(t, createRandomUser(t).Username)
+
+	apiKeys, err := testQueries.ListAPIKeys(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 3)
+	for _, apiKey := range apiKeys {
+		require.Equal(t, user.Username, apiKey.Username)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	// only the owner can revoke a key
+	_, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey.ID, Username: createRandomUser(t).Username})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	revoked, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.True(t, revoked.RevokedAt.Valid)
+
+	_, err = testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey.ID, Username: user.Username})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestAPIKeyTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	req := randomAuditRequest(user.Username)
+	apiKey, err := store.CreateAPIKeyTx(context.Background(), CreateAPIKeyTxParams{
+		CreateAPIKeyParams: CreateAPIKeyParams{
+			Username: user.Username,
+			Name:     util.RandomOwner(),
+			Prefix:   util.RandomString(16),
+			KeyHash:  util.HashSecretToken(util.RandomString(32)),
+			Scopes:   []string{"accounts:read"},
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+
+	event := getAuditEvent(t, req)
+	require.Equal(t, AuditActionCreateAPIKey, event.Action)
+	require.Equal(t, fmt.Sprint(apiKey.ID), event.TargetID)
+	require.NotContains(t, string(event.After), apiKey.KeyHash)
+
+	req = randomAuditRequest(user.Username)
+	revoked, err := store.RevokeAPIKeyTx(context.Background(), RevokeAPIKeyTxParams{
+		RevokeAPIKeyParams: RevokeAPIKeyParams{ID: apiKey.ID, Username: user.Username},
+		Audit:              req,
+	})
+	require.NoError(t, err)
+	require.True(t, revoked.RevokedAt.Valid)
+	require.Equal(t, AuditActionRevokeAPIKey, getAuditEvent(t, req).Action)
+}
+
+func TestTouchAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	require.NoError(t, testQueries.TouchAPIKey(context.Background(), apiKey.ID))
+	touched, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.True(t, touched.LastUsedAt.Valid)
+
+	// a second use within the minute isn't written
+	require.NoError(t, testQueries.TouchAPIKey(context.Background(), apiKey.ID))
+	again, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, touched.LastUsedAt.Time, again.LastUsedAt.Time)
+}
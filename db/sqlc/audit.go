@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// actions recorded in audit_events
+const (
+	AuditActionCreateAccount   = "account.create"
+	AuditActionCloseAccount    = "account.close"
+	AuditActionFreezeAccount   = "account.freeze"
+	AuditActionUnfreezeAccount = "account.unfreeze"
+	AuditActionCreateTransfer  = "transfer.create"
+	AuditActionCreateFunding   = "funding.create"
+	AuditActionCompleteFunding = "funding.complete"
+	AuditActionPlaceHold       = "hold.place"
+	AuditActionCaptureHold     = "hold.capture"
+	AuditActionReleaseHold     = "hold.release"
+	AuditActionExpireHold      = "hold.expire"
+	AuditActionCreateSession   = "session.create"
+	AuditActionRotateSession   = "session.rotate"
+	AuditActionCreateUser      = "user.create"
+	AuditActionUpdateUser      = "user.update"
+	AuditActionResetPassword   = "user.reset_password"
+	AuditActionEnableTOTP      = "user.enable_totp"
+	AuditActionVerifyEmail     = "user.verify_email"
+	AuditActionEnrollTOTP      = "user.enroll_totp"
+	AuditActionUseRecoveryCode = "user.use_recovery_code"
+	AuditActionBlockSession    = "session.block"
+	AuditActionCreateAPIKey    = "api_key.create"
+	AuditActionRevokeAPIKey    = "api_key.revoke"
+	AuditActionCreateSchedule  = "scheduled_transfer.create"
+	AuditActionUpdateSchedule  = "scheduled_transfer.update"
+	AuditActionDeleteSchedule  = "scheduled_transfer.delete"
+)
+
+// target types of audit events
+const (
+	AuditTargetAccount  = "account"
+	AuditTargetTransfer = "transfer"
+	AuditTargetFunding  = "funding"
+	AuditTargetHold     = "hold"
+	AuditTargetSession  = "session"
+	AuditTargetUser     = "user"
+	AuditTargetAPIKey   = "api_key"
+	AuditTargetSchedule = "scheduled_transfer"
+)
+
+// AuditActorScheduler is the actor of the transfers made by the scheduled transfer worker
+const AuditActorScheduler = "scheduler"
+
+// AuditActorHoldSweeper is the actor of the holds released by the expiry sweeper
+const AuditActorHoldSweeper = "hold-sweeper"
+
+// AuditRequest attributes the audit events of a transaction to the request that caused them
+type AuditRequest struct {
+	Actor     string
+	RequestID string
+	ClientIP  string
+	UserAgent string
+}
+
+// auditEvent is a state change to record, Before and After are marshaled to json
+type auditEvent struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// appendAuditEvent records event at the end of the hash chain, with the queries of the transaction that made the change.
+// The chain stays locked until that transaction ends, so it must be the last write of the transaction
+// to keep the lock short and to never wait for row locks while holding it
+func appendAuditEvent(ctx context.Context, q *Queries, req AuditRequest, event auditEvent) error {
+	before, err := json.Marshal(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(event.After)
+	if err != nil {
+		return err
+	}
+
+	if err := q.LockAuditChain(ctx); err != nil {
+		return err
+	}
+
+	prevHash, err := q.GetLastAuditHash(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	arg := CreateAuditEventParams{
+		Actor:      req.Actor,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		RequestID:  req.RequestID,
+		ClientIp:   req.ClientIP,
+		UserAgent:  req.UserAgent,
+		Before:     before,
+		After:      after,
+		PrevHash:   prevHash,
+		// postgres keeps microseconds, the hash must cover the time as it is stored
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	arg.Hash = AuditEventHash(AuditEvent{
+		Actor:      arg.Actor,
+		Action:     arg.Action,
+		TargetType: arg.TargetType,
+		TargetID:   arg.TargetID,
+		RequestID:  arg.RequestID,
+		ClientIp:   arg.ClientIp,
+		UserAgent:  arg.UserAgent,
+		Before:     arg.Before,
+		After:      arg.After,
+		PrevHash:   arg.PrevHash,
+		CreatedAt:  arg.CreatedAt,
+	})
+
+	_, err = q.CreateAuditEvent(ctx, arg)
+	return err
+}
+
+// AuditEventHash is the sha256 over the previous hash and every field of event but its id and own hash.
+// Each field is prefixed with its length, so no two different events hash the same input
+func AuditEventHash(event AuditEvent) string {
+	fields := []string{
+		event.PrevHash,
+		event.Actor,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.RequestID,
+		event.ClientIp,
+		event.UserAgent,
+		string(event.Before),
+		string(event.After),
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	hash := sha256.New()
+	for _, field := range fields {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+		hash.Write(length[:])
+		hash.Write([]byte(field))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// auditUser is what the audit log keeps of a user, without the password hash and totp secret
+type auditUser struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	Role              string    `json:"role"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	TotpEnabled       bool      `json:"totp_enabled"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+func newAuditUser(user User) auditUser {
+	return auditUser{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		Role:              user.Role,
+		IsEmailVerified:   user.IsEmailVerified,
+		TotpEnabled:       user.TotpEnabled,
+		PasswordChangedAt: user.PasswordChangedAt,
+	}
+}
+
+// auditSession is what the audit log keeps of a session, without the refresh token
+type auditSession struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIp  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	FamilyID  uuid.UUID `json:"family_id"`
+}
+
+func newAuditSession(session Session) auditSession {
+	return auditSession{
+		ID:        session.ID,
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		ExpiresAt: session.ExpiresAt,
+		FamilyID:  session.FamilyID,
+	}
+}
+
+// auditAPIKey is what the audit log keeps of an api key, without the key hash
+type auditAPIKey struct {
+	ID                int64         `json:"id"`
+	Username          string        `json:"username"`
+	Name              string        `json:"name"`
+	Prefix            string        `json:"prefix"`
+	Scopes            []string      `json:"scopes"`
+	MaxTransferAmount sql.NullInt64 `json:"max_transfer_amount"`
+	ExpiresAt         sql.NullTime  `json:"expires_at"`
+	RevokedAt         sql.NullTime  `json:"revoked_at"`
+}
+
+func newAuditAPIKey(apiKey ApiKey) auditAPIKey {
+	return auditAPIKey{
+		ID:                apiKey.ID,
+		Username:          apiKey.Username,
+		Name:              apiKey.Name,
+		Prefix:            apiKey.Prefix,
+		Scopes:            apiKey.Scopes,
+		MaxTransferAmount: apiKey.MaxTransferAmount,
+		ExpiresAt:         apiKey.ExpiresAt,
+		RevokedAt:         apiKey.RevokedAt,
+	}
+}
@@ -0,0 +1,216 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: audit_events.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor, action, target_type, target_id, request_id, client_ip, user_agent, before, after, prev_hash, hash, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+)
+RETURNING id, actor, action, target_type, target_id, request_id, client_ip, user_agent, before, after, prev_hash, hash, created_at
+`
+
+type CreateAuditEventParams struct {
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	RequestID  string          `json:"request_id"`
+	ClientIp   string          `json:"client_ip"`
+	UserAgent  string          `json:"user_agent"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEvent,
+		arg.Actor,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.RequestID,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.Before,
+		arg.After,
+		arg.PrevHash,
+		arg.Hash,
+		arg.CreatedAt,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Actor,
+		&i.Action,
+		&i.TargetType,
+		&i.TargetID,
+		&i.RequestID,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.Before,
+		&i.After,
+		&i.PrevHash,
+		&i.Hash,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastAuditHash = `-- name: GetLastAuditHash :one
+SELECT hash FROM audit_events
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastAuditHash(ctx context.Context) (string, error) {
+	row := q.db.QueryRowContext(ctx, getLastAuditHash)
+	var hash string
+	err := row.Scan(&hash)
+	return hash, err
+}
+
+const listAuditChain = `-- name: ListAuditChain :many
+SELECT id, actor, action, target_type, target_id, request_id, client_ip, user_agent, before, after, prev_hash, hash, created_at FROM audit_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListAuditChainParams struct {
+	ID    int64 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+func (q *Queries) ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditChain, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.RequestID,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.Before,
+			&i.After,
+			&i.PrevHash,
+			&i.Hash,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, actor, action, target_type, target_id, request_id, client_ip, user_agent, before, after, prev_hash, hash, created_at FROM audit_events
+WHERE ($1::varchar IS NULL OR actor = $1)
+  AND ($2::varchar IS NULL OR action = $2)
+  AND ($3::varchar IS NULL OR target_type = $3)
+  AND ($4::varchar IS NULL OR target_id = $4)
+  AND ($5::varchar IS NULL OR request_id = $5)
+  AND ($6::timestamptz IS NULL OR created_at >= $6)
+  AND ($7::timestamptz IS NULL OR created_at < $7)
+  AND ($8::bigint IS NULL OR id < $8)
+ORDER BY id DESC
+LIMIT $9
+`
+
+type ListAuditEventsParams struct {
+	Actor      sql.NullString `json:"actor"`
+	Action     sql.NullString `json:"action"`
+	TargetType sql.NullString `json:"target_type"`
+	TargetID   sql.NullString `json:"target_id"`
+	RequestID  sql.NullString `json:"request_id"`
+	FromTime   sql.NullTime   `json:"from_time"`
+	ToTime     sql.NullTime   `json:"to_time"`
+	Cursor     sql.NullInt64  `json:"cursor"`
+	PageSize   int32          `json:"page_size"`
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.Actor,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.RequestID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Cursor,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.RequestID,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.Before,
+			&i.After,
+			&i.PrevHash,
+			&i.Hash,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAuditChain = `-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'))
+`
+
+// serializes the appends until the transaction ends, so that every event links to the one before it
+func (q *Queries) LockAuditChain(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockAuditChain)
+	return err
+}
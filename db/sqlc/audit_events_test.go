@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomAuditRequest(actor string) AuditRequest {
+	return AuditRequest{
+		Actor:     actor,
+		RequestID: util.RandomString(16),
+		ClientIP:  "127.0.0.1",
+		UserAgent: "go-test",
+	}
+}
+
+// getAuditEvent returns the only event recorded for the request req
+func getAuditEvent(t *testing.T, req AuditRequest) AuditEvent {
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		RequestID: sql.NullString{String: req.RequestID, Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	event := events[0]
+	require.Equal(t, req.Actor, event.Actor)
+	require.Equal(t, req.ClientIP, event.ClientIp)
+	require.Equal(t, req.UserAgent, event.UserAgent)
+	require.Equal(t, AuditEventHash(event), event.Hash)
+	return event
+}
+
+func TestCreateAccountTxAudit(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	req := randomAuditRequest(user.Username)
+
+	account, err := store.CreateAccountTx(context.Background(), CreateAccountTxParams{
+		CreateAccountParams: CreateAccountParams{
+			Owner:    user.Username,
+			Currency: util.RandomCurrency(),
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+
+	event := getAuditEvent(t, req)
+	require.Equal(t, AuditActionCreateAccount, event.Action)
+	require.Equal(t, AuditTargetAccount, event.TargetType)
+	require.Equal(t, fmt.Sprint(account.ID), event.TargetID)
+	require.JSONEq(t, "null", string(event.Before))
+
+	var after Account
+	require.NoError(t, json.Unmarshal(event.After, &after))
+	require.Equal(t, account.ID, after.ID)
+
+	// the next event links to this one
+	next := randomAuditRequest(user.Username)
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account.ID, Audit: next})
+	require.NoError(t, err)
+
+	closeEvent := getAuditEvent(t, next)
+	require.Equal(t, AuditActionCloseAccount, closeEvent.Action)
+	require.Greater(t, closeEvent.ID, event.ID)
+	require.NotEmpty(t, closeEvent.PrevHash)
+}
+
+func TestUpdateUserTxAudit(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	req := randomAuditRequest(user.Username)
+
+	newName := util.RandomOwner()
+	_, err := store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: user.Username,
+			FullName: sql.NullString{String: newName, Valid: true},
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+
+	event := getAuditEvent(t, req)
+	require.Equal(t, AuditActionUpdateUser, event.Action)
+	require.Equal(t, user.Username, event.TargetID)
+
+	var before, after map[string]interface{}
+	require.NoError(t, json.Unmarshal(event.Before, &before))
+	require.NoError(t, json.Unmarshal(event.After, &after))
+	require.Equal(t, user.FullName, before["full_name"])
+	require.Equal(t, newName, after["full_name"])
+
+	// secrets stay out of the audit log
+	require.NotContains(t, after, "password")
+	require.NotContains(t, after, "totp_secret")
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	req := randomAuditRequest(user.Username)
+
+	_, err := store.CreateAccountTx(context.Background(), CreateAccountTxParams{
+		CreateAccountParams: CreateAccountParams{Owner: user.Username, Currency: util.RandomCurrency()},
+		Audit:               req,
+	})
+	require.NoError(t, err)
+	event := getAuditEvent(t, req)
+
+	_, err = testDB.Exec("UPDATE audit_events SET actor = 'someone else' WHERE id = $1", event.ID)
+	require.Error(t, err)
+
+	_, err = testDB.Exec("DELETE FROM audit_events WHERE id = $1", event.ID)
+	require.Error(t, err)
+
+	require.Equal(t, event, getAuditEvent(t, req))
+}
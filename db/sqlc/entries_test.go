@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -90,6 +91,59 @@ func TestListEntries(t *testing.T) {
 	cleanUpUser(t, user.Username)
 }
 
+func TestListAccountEntries(t *testing.T) {
+	account, _, user, _ := createRandomTestAccount(t)
+
+	incoming, err := testQueries.CreateEntry(context.Background(), CreateEntryParams{AccountID: account.ID, Amount: 10})
+	require.NoError(t, err)
+	outgoing, err := testQueries.CreateEntry(context.Background(), CreateEntryParams{AccountID: account.ID, Amount: -10})
+	require.NoError(t, err)
+
+	// newest entries come first
+	entries, err := testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account.ID,
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, outgoing.ID, entries[0].ID)
+	require.Equal(t, incoming.ID, entries[1].ID)
+
+	entries, err = testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account.ID,
+		Direction: sql.NullString{String: "incoming", Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, incoming.ID, entries[0].ID)
+
+	entries, err = testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account.ID,
+		Cursor:    sql.NullInt64{Int64: outgoing.ID, Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, incoming.ID, entries[0].ID)
+
+	entries, err = testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account.ID,
+		FromTime:  sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	//clean up entries
+	cleanUpEntry(t, incoming.ID)
+	cleanUpEntry(t, outgoing.ID)
+	//clean up account
+	cleanUpAccount(t, int(account.ID))
+	//clean up user
+	cleanUpUser(t, user.Username)
+}
+
 func cleanUpEntry(t *testing.T, id int64) {
 	err := testQueries.DeleteEntry(context.Background(), id)
 	require.NoError(t, err)
@@ -0,0 +1,184 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: fundings.sql
+
+package db
+
+import (
+	"context"
+)
+
+const completeFunding = `-- name: CompleteFunding :one
+UPDATE fundings SET
+  status = $1,
+  failure_reason = $2,
+  completed_at = now()
+WHERE id = $3 AND status = 'pending'
+RETURNING id, account_id, kind, amount, status, provider_reference, failure_reason, created_at, completed_at
+`
+
+type CompleteFundingParams struct {
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+	ID            int64  `json:"id"`
+}
+
+func (q *Queries) CompleteFunding(ctx context.Context, arg CompleteFundingParams) (Funding, error) {
+	row := q.db.QueryRowContext(ctx, completeFunding, arg.Status, arg.FailureReason, arg.ID)
+	var i Funding
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Kind,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderReference,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const countPendingFundings = `-- name: CountPendingFundings :one
+SELECT count(*) FROM fundings
+WHERE account_id = $1 AND status = 'pending'
+`
+
+func (q *Queries) CountPendingFundings(ctx context.Context, accountID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPendingFundings, accountID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createFunding = `-- name: CreateFunding :one
+INSERT INTO fundings (
+  account_id, kind, amount
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, account_id, kind, amount, status, provider_reference, failure_reason, created_at, completed_at
+`
+
+type CreateFundingParams struct {
+	AccountID int64  `json:"account_id"`
+	Kind      string `json:"kind"`
+	Amount    int64  `json:"amount"`
+}
+
+func (q *Queries) CreateFunding(ctx context.Context, arg CreateFundingParams) (Funding, error) {
+	row := q.db.QueryRowContext(ctx, createFunding, arg.AccountID, arg.Kind, arg.Amount)
+	var i Funding
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Kind,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderReference,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getFunding = `-- name: GetFunding :one
+SELECT id, account_id, kind, amount, status, provider_reference, failure_reason, created_at, completed_at FROM fundings
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetFunding(ctx context.Context, id int64) (Funding, error) {
+	row := q.db.QueryRowContext(ctx, getFunding, id)
+	var i Funding
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Kind,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderReference,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getFundingForUpdate = `-- name: GetFundingForUpdate :one
+SELECT id, account_id, kind, amount, status, provider_reference, failure_reason, created_at, completed_at FROM fundings
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetFundingForUpdate(ctx context.Context, id int64) (Funding, error) {
+	row := q.db.QueryRowContext(ctx, getFundingForUpdate, id)
+	var i Funding
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Kind,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderReference,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getSettlementAccount = `-- name: GetSettlementAccount :one
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status, closed_at, status_reason, status_changed_at, held_amount, available_balance FROM accounts
+WHERE owner = 'simplebank' AND currency = $1 LIMIT 1
+`
+
+func (q *Queries) GetSettlementAccount(ctx context.Context, currency string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getSettlementAccount, currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+		&i.ClosedAt,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+	)
+	return i, err
+}
+
+const setFundingReference = `-- name: SetFundingReference :one
+UPDATE fundings SET provider_reference = $1
+WHERE id = $2
+RETURNING id, account_id, kind, amount, status, provider_reference, failure_reason, created_at, completed_at
+`
+
+type SetFundingReferenceParams struct {
+	ProviderReference string `json:"provider_reference"`
+	ID                int64  `json:"id"`
+}
+
+func (q *Queries) SetFundingReference(ctx context.Context, arg SetFundingReferenceParams) (Funding, error) {
+	row := q.db.QueryRowContext(ctx, setFundingReference, arg.ProviderReference, arg.ID)
+	var i Funding
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Kind,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderReference,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositFundingTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+
+	settlement, err := testQueries.GetSettlementAccount(context.Background(), account.Currency)
+	require.NoError(t, err)
+	require.Equal(t, util.SettlementOwner, settlement.Owner)
+
+	amount := util.RandomAmount()
+	created, err := store.CreateFundingTx(context.Background(), CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      util.FundingKindDeposit,
+		Amount:    amount,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.FundingStatusPending, created.Funding.Status)
+
+	// a pending deposit doesn't move money yet
+	require.Equal(t, account.Balance, created.Account.Balance)
+
+	completed, err := store.CompleteFundingTx(context.Background(), CompleteFundingTxParams{
+		ID:     created.Funding.ID,
+		Status: util.FundingStatusSettled,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.FundingStatusSettled, completed.Funding.Status)
+	require.True(t, completed.Funding.CompletedAt.Valid)
+	require.Equal(t, account.Balance+amount, completed.Account.Balance)
+
+	updatedSettlement, err := testQueries.GetAccount(context.Background(), settlement.ID)
+	require.NoError(t, err)
+	require.LessOrEqual(t, updatedSettlement.Balance, settlement.Balance-amount)
+
+	// a funding is completed only once
+	_, err = store.CompleteFundingTx(context.Background(), CompleteFundingTxParams{
+		ID:     created.Funding.ID,
+		Status: util.FundingStatusSettled,
+	})
+	require.ErrorIs(t, err, ErrFundingNotPending)
+}
+
+func TestWithdrawalFundingTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+
+	_, err = store.CreateFundingTx(context.Background(), CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      util.FundingKindWithdrawal,
+		Amount:    account.Balance + 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the withdrawn amount is reserved as soon as the withdrawal starts
+	created, err := store.CreateFundingTx(context.Background(), CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      util.FundingKindWithdrawal,
+		Amount:    account.Balance,
+	})
+	require.NoError(t, err)
+	require.Zero(t, created.Account.Balance)
+
+	// and released again when it fails
+	completed, err := store.CompleteFundingTx(context.Background(), CompleteFundingTxParams{
+		ID:            created.Funding.ID,
+		Status:        util.FundingStatusFailed,
+		FailureReason: "account closed at the receiving bank",
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.FundingStatusFailed, completed.Funding.Status)
+	require.Equal(t, "account closed at the receiving bank", completed.Funding.FailureReason)
+	require.Equal(t, account.Balance, completed.Account.Balance)
+}
@@ -0,0 +1,217 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: holds.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const closeHold = `-- name: CloseHold :one
+UPDATE holds
+SET status = $1, captured_amount = $2, transfer_id = $3, closed_at = now()
+WHERE id = $4
+RETURNING id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at
+`
+
+type CloseHoldParams struct {
+	Status         string        `json:"status"`
+	CapturedAmount int64         `json:"captured_amount"`
+	TransferID     sql.NullInt64 `json:"transfer_id"`
+	ID             int64         `json:"id"`
+}
+
+func (q *Queries) CloseHold(ctx context.Context, arg CloseHoldParams) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, closeHold,
+		arg.Status,
+		arg.CapturedAmount,
+		arg.TransferID,
+		arg.ID,
+	)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Status,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const createHold = `-- name: CreateHold :one
+INSERT INTO holds (
+  account_id, to_account_id, amount, expires_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at
+`
+
+type CreateHoldParams struct {
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, createHold,
+		arg.AccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ExpiresAt,
+	)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Status,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getExpiredHoldForUpdate = `-- name: GetExpiredHoldForUpdate :one
+SELECT id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at FROM holds
+WHERE status = 'active' AND expires_at <= $1
+ORDER BY expires_at
+LIMIT 1
+FOR NO KEY UPDATE SKIP LOCKED
+`
+
+// concurrent sweepers skip the hold another one is expiring
+func (q *Queries) GetExpiredHoldForUpdate(ctx context.Context, now time.Time) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, getExpiredHoldForUpdate, now)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Status,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getHold = `-- name: GetHold :one
+SELECT id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at FROM holds
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetHold(ctx context.Context, id int64) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, getHold, id)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Status,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getHoldForUpdate = `-- name: GetHoldForUpdate :one
+SELECT id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at FROM holds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetHoldForUpdate(ctx context.Context, id int64) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, getHoldForUpdate, id)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Status,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const listAccountHolds = `-- name: ListAccountHolds :many
+SELECT id, account_id, to_account_id, amount, status, captured_amount, transfer_id, expires_at, created_at, closed_at FROM holds
+WHERE account_id = $1
+AND ($2::varchar IS NULL OR status = $2)
+ORDER BY id DESC
+LIMIT $4
+OFFSET $3
+`
+
+type ListAccountHoldsParams struct {
+	AccountID int64          `json:"account_id"`
+	Status    sql.NullString `json:"status"`
+	Offset    int32          `json:"offset"`
+	Limit     int32          `json:"limit"`
+}
+
+func (q *Queries) ListAccountHolds(ctx context.Context, arg ListAccountHoldsParams) ([]Hold, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountHolds,
+		arg.AccountID,
+		arg.Status,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Hold{}
+	for rows.Next() {
+		var i Hold
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Status,
+			&i.CapturedAmount,
+			&i.TransferID,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func placeTestHold(t *testing.T, store Store, from, to Account, amount int64, expiresAt time.Time) HoldTxResult {
+	req := randomAuditRequest(from.Owner)
+	result, err := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID:   from.ID,
+		ToAccountID: to.ID,
+		Amount:      amount,
+		ExpiresAt:   expiresAt,
+		Audit:       req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AuditActionPlaceHold, getAuditEvent(t, req).Action)
+	return result
+}
+
+func TestPlaceHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	result := placeTestHold(t, store, account1, account2, account1.Balance, time.Now().Add(time.Hour))
+	require.Equal(t, util.HoldStatusActive, result.Hold.Status)
+	require.Equal(t, account1.Balance, result.Hold.Amount)
+	require.Equal(t, account1.Balance, result.Account.Balance)
+	require.Equal(t, account1.Balance, result.Account.HeldAmount)
+	require.Zero(t, result.Account.AvailableBalance)
+
+	// held money can be neither held again nor spent
+	_, err := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      1,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account1.ID, SweepToAccountID: account2.ID})
+	require.ErrorIs(t, err, ErrAccountHasHolds)
+}
+
+func TestCaptureHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	placed := placeTestHold(t, store, account1, account2, 10, time.Now().Add(time.Hour))
+
+	_, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{HoldID: placed.Hold.ID, Amount: 11})
+	require.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+	req := randomAuditRequest(account1.Owner)
+	result, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+		Amount: 4,
+		Audit:  req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AuditActionCaptureHold, getAuditEvent(t, req).Action)
+
+	require.Equal(t, util.HoldStatusCaptured, result.Hold.Status)
+	require.Equal(t, int64(4), result.Hold.CapturedAmount)
+	require.True(t, result.Hold.ClosedAt.Valid)
+
+	require.NotNil(t, result.Transfer)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, int64(4), result.Transfer.Transfer.Amount)
+	require.Equal(t, account2.Balance+4, result.Transfer.ToAccount.Balance)
+
+	// the rest of a partial capture is released
+	require.Equal(t, account1.Balance-4, result.Account.Balance)
+	require.Zero(t, result.Account.HeldAmount)
+	require.Equal(t, result.Account.Balance, result.Account.AvailableBalance)
+
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{HoldID: placed.Hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestReleaseHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	placed := placeTestHold(t, store, account1, account2, account1.Balance, time.Now().Add(time.Hour))
+
+	req := randomAuditRequest(account1.Owner)
+	result, err := store.ReleaseHoldTx(context.Background(), ReleaseHoldTxParams{HoldID: placed.Hold.ID, Audit: req})
+	require.NoError(t, err)
+	require.Equal(t, AuditActionReleaseHold, getAuditEvent(t, req).Action)
+
+	require.Equal(t, util.HoldStatusReleased, result.Hold.Status)
+	require.Zero(t, result.Hold.CapturedAmount)
+	require.False(t, result.Hold.TransferID.Valid)
+	require.Equal(t, account1.Balance, result.Account.Balance)
+	require.Equal(t, account1.Balance, result.Account.AvailableBalance)
+
+	_, err = store.ReleaseHoldTx(context.Background(), ReleaseHoldTxParams{HoldID: placed.Hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestExpireHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	expiresAt := time.Now().Add(time.Minute)
+	placed := placeTestHold(t, store, account1, account2, account1.Balance, expiresAt)
+
+	// expire holds due by then until this one is reached, other tests may have left expired holds behind
+	var result HoldTxResult
+	var err error
+	for result.Hold.ID != placed.Hold.ID {
+		result, err = store.ExpireHoldTx(context.Background(), expiresAt)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, util.HoldStatusExpired, result.Hold.Status)
+	require.Zero(t, result.Account.HeldAmount)
+	require.Equal(t, account1.Balance, result.Account.AvailableBalance)
+
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{HoldID: placed.Hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+
+	hold, err := testQueries.GetHold(context.Background(), placed.Hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, util.HoldStatusExpired, hold.Status)
+
+	_, err = store.ExpireHoldTx(context.Background(), time.Unix(0, 0))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,103 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: idempotency_keys.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const createIdempotencyKey = `-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username, idempotency_key, request_hash
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT DO NOTHING
+RETURNING username, idempotency_key, request_hash, result, created_at
+`
+
+type CreateIdempotencyKeyParams struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	RequestHash    string `json:"request_hash"`
+}
+
+func (q *Queries) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, createIdempotencyKey, arg.Username, arg.IdempotencyKey, arg.RequestHash)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.Result,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2
+`
+
+type DeleteIdempotencyKeyParams struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.ExecContext(ctx, deleteIdempotencyKey, arg.Username, arg.IdempotencyKey)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT username, idempotency_key, request_hash, result, created_at FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2 LIMIT 1
+`
+
+type GetIdempotencyKeyParams struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, arg.Username, arg.IdempotencyKey)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.Result,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateIdempotencyKeyResult = `-- name: UpdateIdempotencyKeyResult :one
+UPDATE idempotency_keys SET result = $3
+WHERE username = $1 AND idempotency_key = $2
+RETURNING username, idempotency_key, request_hash, result, created_at
+`
+
+type UpdateIdempotencyKeyResultParams struct {
+	Username       string          `json:"username"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Result         json.RawMessage `json:"result"`
+}
+
+func (q *Queries) UpdateIdempotencyKeyResult(ctx context.Context, arg UpdateIdempotencyKeyResultParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, updateIdempotencyKeyResult, arg.Username, arg.IdempotencyKey, arg.Result)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.Result,
+		&i.CreatedAt,
+	)
+	return i, err
+}
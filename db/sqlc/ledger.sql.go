@@ -0,0 +1,143 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: ledger.sql
+
+package db
+
+import (
+	"context"
+)
+
+const listBalanceDiscrepancies = `-- name: ListBalanceDiscrepancies :many
+SELECT a.id AS account_id, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id
+`
+
+type ListBalanceDiscrepanciesRow struct {
+	AccountID      int64  `json:"account_id"`
+	Currency       string `json:"currency"`
+	Balance        int64  `json:"balance"`
+	EntriesBalance int64  `json:"entries_balance"`
+}
+
+func (q *Queries) ListBalanceDiscrepancies(ctx context.Context) ([]ListBalanceDiscrepanciesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBalanceDiscrepancies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListBalanceDiscrepanciesRow{}
+	for rows.Next() {
+		var i ListBalanceDiscrepanciesRow
+		if err := rows.Scan(
+			&i.AccountID,
+			&i.Currency,
+			&i.Balance,
+			&i.EntriesBalance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransferDiscrepancies = `-- name: ListTransferDiscrepancies :many
+SELECT
+  t.id AS transfer_id,
+  COUNT(e.id) FILTER (WHERE e.account_id = t.from_account_id AND e.amount = -t.amount) AS debit_entries,
+  COUNT(e.id) FILTER (WHERE e.account_id = t.to_account_id AND e.amount = t.to_amount) AS credit_entries,
+  COUNT(e.id) AS linked_entries
+FROM transfers t
+JOIN accounts fa ON fa.id = t.from_account_id
+JOIN accounts ta ON ta.id = t.to_account_id
+LEFT JOIN entries e ON e.transfer_id = t.id
+GROUP BY t.id, fa.currency, ta.currency
+HAVING COUNT(e.id) FILTER (WHERE e.account_id = t.from_account_id AND e.amount = -t.amount) <> 1
+  OR COUNT(e.id) FILTER (WHERE e.account_id = t.to_account_id AND e.amount = t.to_amount) <> 1
+  OR COUNT(e.id) <> CASE WHEN fa.currency = ta.currency THEN 2 ELSE 4 END
+ORDER BY t.id
+`
+
+type ListTransferDiscrepanciesRow struct {
+	TransferID    int64 `json:"transfer_id"`
+	DebitEntries  int64 `json:"debit_entries"`
+	CreditEntries int64 `json:"credit_entries"`
+	LinkedEntries int64 `json:"linked_entries"`
+}
+
+// a cross-currency transfer has two more entries on the settlement accounts of its currencies
+func (q *Queries) ListTransferDiscrepancies(ctx context.Context) ([]ListTransferDiscrepanciesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTransferDiscrepancies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransferDiscrepanciesRow{}
+	for rows.Next() {
+		var i ListTransferDiscrepanciesRow
+		if err := rows.Scan(
+			&i.TransferID,
+			&i.DebitEntries,
+			&i.CreditEntries,
+			&i.LinkedEntries,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const sumEntriesByCurrency = `-- name: SumEntriesByCurrency :many
+SELECT a.currency, SUM(e.amount)::bigint AS total
+FROM entries e
+JOIN accounts a ON a.id = e.account_id
+GROUP BY a.currency
+ORDER BY a.currency
+`
+
+type SumEntriesByCurrencyRow struct {
+	Currency string `json:"currency"`
+	Total    int64  `json:"total"`
+}
+
+func (q *Queries) SumEntriesByCurrency(ctx context.Context) ([]SumEntriesByCurrencyRow, error) {
+	rows, err := q.db.QueryContext(ctx, sumEntriesByCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SumEntriesByCurrencyRow{}
+	for rows.Next() {
+		var i SumEntriesByCurrencyRow
+		if err := rows.Scan(&i.Currency, &i.Total); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
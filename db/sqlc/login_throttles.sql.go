@@ -0,0 +1,106 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: login_throttles.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const delayLogin = `-- name: DelayLogin :exec
+UPDATE login_throttles SET
+  next_attempt_at = $3
+WHERE kind = $1 AND subject = $2
+`
+
+type DelayLoginParams struct {
+	Kind          string    `json:"kind"`
+	Subject       string    `json:"subject"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+func (q *Queries) DelayLogin(ctx context.Context, arg DelayLoginParams) error {
+	_, err := q.db.ExecContext(ctx, delayLogin, arg.Kind, arg.Subject, arg.NextAttemptAt)
+	return err
+}
+
+const deleteLoginThrottle = `-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE kind = $1 AND subject = $2
+`
+
+type DeleteLoginThrottleParams struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) DeleteLoginThrottle(ctx context.Context, arg DeleteLoginThrottleParams) error {
+	_, err := q.db.ExecContext(ctx, deleteLoginThrottle, arg.Kind, arg.Subject)
+	return err
+}
+
+const getLoginThrottle = `-- name: GetLoginThrottle :one
+SELECT kind, subject, failures, last_failed_at, next_attempt_at FROM login_throttles
+WHERE kind = $1 AND subject = $2 LIMIT 1
+`
+
+type GetLoginThrottleParams struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) GetLoginThrottle(ctx context.Context, arg GetLoginThrottleParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, getLoginThrottle, arg.Kind, arg.Subject)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Kind,
+		&i.Subject,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (
+  kind, subject, failures, last_failed_at
+) VALUES (
+  $1, $2, 1, $3
+)
+ON CONFLICT (kind, subject) DO UPDATE SET
+  failures = CASE
+    WHEN login_throttles.last_failed_at < $4::timestamptz THEN 1
+    ELSE login_throttles.failures + 1
+  END,
+  last_failed_at = EXCLUDED.last_failed_at
+RETURNING kind, subject, failures, last_failed_at, next_attempt_at
+`
+
+type RecordLoginFailureParams struct {
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	FailedAt    time.Time `json:"failed_at"`
+	ResetBefore time.Time `json:"reset_before"`
+}
+
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure,
+		arg.Kind,
+		arg.Subject,
+		arg.FailedAt,
+		arg.ResetBefore,
+	)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Kind,
+		&i.Subject,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLoginFailure(t *testing.T) {
+	key := GetLoginThrottleParams{Kind: "username", Subject: util.RandomOwner()}
+	now := time.Now()
+
+	_, err := testQueries.GetLoginThrottle(context.Background(), key)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	for i := 1; i <= 3; i++ {
+		throttle, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+			Kind:        key.Kind,
+			Subject:     key.Subject,
+			FailedAt:    now,
+			ResetBefore: now.Add(-time.Minute),
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), throttle.Failures)
+	}
+
+	nextAttemptAt := now.Add(time.Hour)
+	err = testQueries.DelayLogin(context.Background(), DelayLoginParams{
+		Kind:          key.Kind,
+		Subject:       key.Subject,
+		NextAttemptAt: nextAttemptAt,
+	})
+	require.NoError(t, err)
+
+	throttle, err := testQueries.GetLoginThrottle(context.Background(), key)
+	require.NoError(t, err)
+	require.WithinDuration(t, nextAttemptAt, throttle.NextAttemptAt, time.Second)
+
+	// a failure after a quiet window starts over
+	later := now.Add(2 * time.Minute)
+	throttle, err = testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Kind:        key.Kind,
+		Subject:     key.Subject,
+		FailedAt:    later,
+		ResetBefore: later.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), throttle.Failures)
+
+	err = testQueries.DeleteLoginThrottle(context.Background(), DeleteLoginThrottleParams(key))
+	require.NoError(t, err)
+
+	_, err = testQueries.GetLoginThrottle(context.Background(), key)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
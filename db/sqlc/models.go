@@ -5,6 +5,8 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +18,57 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	// how far below zero the balance may go
+	OverdraftLimit int64 `json:"overdraft_limit"`
+	// active, frozen or closed
+	Status   string       `json:"status"`
+	ClosedAt sql.NullTime `json:"closed_at"`
+	// why the account was last frozen or unfrozen
+	StatusReason string `json:"status_reason"`
+	// when the status last changed
+	StatusChangedAt time.Time `json:"status_changed_at"`
+	// sum of the active holds on the account
+	HeldAmount int64 `json:"held_amount"`
+	// balance minus held_amount, what debits may spend
+	AvailableBalance int64 `json:"available_balance"`
+}
+
+type ApiKey struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	// public part of the key to look it up by
+	Prefix string `json:"prefix"`
+	// sha256 of the whole key, the key itself is not stored
+	KeyHash string   `json:"key_hash"`
+	Scopes  []string `json:"scopes"`
+	// largest transfer the key may make, no limit when null
+	MaxTransferAmount sql.NullInt64 `json:"max_transfer_amount"`
+	LastUsedAt        sql.NullTime  `json:"last_used_at"`
+	// the key never expires when null
+	ExpiresAt sql.NullTime `json:"expires_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// username that caused the change, or the background job
+	Actor      string `json:"actor"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	RequestID  string `json:"request_id"`
+	ClientIp   string `json:"client_ip"`
+	UserAgent  string `json:"user_agent"`
+	// json rather than jsonb keeps the text exactly as hashed
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	// hash of the previous event, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	// sha256 over prev_hash and the fields of the event
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Entry struct {
@@ -24,6 +77,115 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// transfer that produced the entry, if any
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	// deposit or withdrawal that produced the entry, if any
+	FundingID sql.NullInt64 `json:"funding_id"`
+}
+
+type Funding struct {
+	ID        int64 `json:"id"`
+	AccountID int64 `json:"account_id"`
+	// deposit or withdrawal
+	Kind   string `json:"kind"`
+	Amount int64  `json:"amount"`
+	// pending, settled or failed
+	Status string `json:"status"`
+	// id of the funding at the external provider
+	ProviderReference string       `json:"provider_reference"`
+	FailureReason     string       `json:"failure_reason"`
+	CreatedAt         time.Time    `json:"created_at"`
+	CompletedAt       sql.NullTime `json:"completed_at"`
+}
+
+type Hold struct {
+	ID          int64 `json:"id"`
+	AccountID   int64 `json:"account_id"`
+	ToAccountID int64 `json:"to_account_id"`
+	// reserved on account_id, must be positive
+	Amount int64 `json:"amount"`
+	// active, captured, released or expired
+	Status string `json:"status"`
+	// moved to to_account_id by the capture, the rest was released
+	CapturedAmount int64 `json:"captured_amount"`
+	// the transfer of the capture
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ClosedAt   sql.NullTime  `json:"closed_at"`
+}
+
+type IdempotencyKey struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	// sha256 of the request body the key was first used with
+	RequestHash string          `json:"request_hash"`
+	Result      json.RawMessage `json:"result"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type LoginThrottle struct {
+	// username or ip
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	// failed logins since the last success, counted again from one after a quiet window
+	Failures     int32     `json:"failures"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+	// logins of the subject are rejected before this time
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+type MfaChallenge struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the challenge token returned by the login, the token itself is not stored
+	TokenHash string       `json:"token_hash"`
+	Attempts  int32        `json:"attempts"`
+	UserAgent string       `json:"user_agent"`
+	ClientIp  string       `json:"client_ip"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+type PasswordReset struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the token mailed to the user, the token itself is not stored
+	TokenHash string       `json:"token_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiredAt time.Time    `json:"expired_at"`
+}
+
+type RecoveryCode struct {
+	ID        int64        `json:"id"`
+	Username  string       `json:"username"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Amount        int64  `json:"amount"`
+	// once, daily, weekly or monthly
+	Frequency string    `json:"frequency"`
+	StartAt   time.Time `json:"start_at"`
+	NextRunAt time.Time `json:"next_run_at"`
+	// occurrences executed or skipped so far
+	Runs int32 `json:"runs"`
+	// active, paused, completed, failed or cancelled
+	Status string `json:"status"`
+	// failed attempts of the current occurrence
+	Attempts       int32         `json:"attempts"`
+	LastError      string        `json:"last_error"`
+	LastRunAt      sql.NullTime  `json:"last_run_at"`
+	LastTransferID sql.NullInt64 `json:"last_transfer_id"`
+	CreatedAt      time.Time     `json:"created_at"`
 }
 
 type Session struct {
@@ -35,6 +197,26 @@ type Session struct {
 	IsBlocked    bool      `json:"is_blocked"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	CreatedAt    time.Time `json:"created_at"`
+	// id of the login session this one was rotated from
+	FamilyID uuid.UUID `json:"family_id"`
+	// set once the refresh token has been exchanged for a successor
+	UsedAt sql.NullTime `json:"used_at"`
+}
+
+type Task struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// pending, completed or dead
+	Status string `json:"status"`
+	// times the task has been picked up by a processor
+	Attempts    int32  `json:"attempts"`
+	MaxAttempts int32  `json:"max_attempts"`
+	LastError   string `json:"last_error"`
+	// when the task is due, pushed back by the lease of the processor running it
+	RunAt       time.Time    `json:"run_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt sql.NullTime `json:"completed_at"`
 }
 
 type Transfer struct {
@@ -44,6 +226,12 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// amount credited in the currency of the destination account
+	ToAmount int64 `json:"to_amount"`
+	// applied rate scaled by 1000000
+	ExchangeRate int64 `json:"exchange_rate"`
+	// spread charged on the conversion in basis points
+	SpreadBps int32 `json:"spread_bps"`
 }
 
 type User struct {
@@ -53,4 +241,22 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// depositor or banker
+	Role            string `json:"role"`
+	IsEmailVerified bool   `json:"is_email_verified"`
+	TotpSecret      string `json:"totp_secret"`
+	TotpEnabled     bool   `json:"totp_enabled"`
+	// the last accepted totp period, codes of it and earlier periods are rejected as replays
+	TotpLastUsedStep int64 `json:"totp_last_used_step"`
+}
+
+type VerifyEmail struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// the address the code was sent to, it is only verified if the user still has it
+	Email      string    `json:"email"`
+	SecretCode string    `json:"secret_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestPasswordReset returns the token of a new reset, only its hash is stored
+func createTestPasswordReset(t *testing.T, user User, expiredAt time.Time) string {
+	resetToken, err := util.NewSecretToken()
+	require.NoError(t, err)
+
+	reset, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: util.HashSecretToken(resetToken),
+		ExpiredAt: expiredAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, reset.Username)
+	require.False(t, reset.UsedAt.Valid)
+
+	return resetToken
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	session := createRandomSession(t)
+
+	user, err := testQueries.GetUser(context.Background(), session.Username)
+	require.NoError(t, err)
+
+	apiKey := createRandomAPIKey(t, user.Username)
+	resetToken := createTestPasswordReset(t, user, time.Now().Add(time.Hour))
+	otherToken := createTestPasswordReset(t, user, time.Now().Add(time.Hour))
+
+	hashedPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		TokenHash: util.HashSecretToken(resetToken),
+		Password:  hashedPassword,
+	}
+	updated, err := store.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, updated.Password)
+	require.WithinDuration(t, time.Now(), updated.PasswordChangedAt, time.Second)
+
+	session, err = testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+
+	apiKey, err = testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.True(t, apiKey.RevokedAt.Valid)
+
+	// the token is single-use and the other tokens of the user are voided
+	_, err = store.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidPasswordReset)
+
+	arg.TokenHash = util.HashSecretToken(otherToken)
+	_, err = store.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidPasswordReset)
+}
+
+func TestResetPasswordTxExpired(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	resetToken := createTestPasswordReset(t, user, time.Now().Add(-time.Minute))
+
+	_, err := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenHash: util.HashSecretToken(resetToken),
+		Password:  user.Password,
+	})
+	require.ErrorIs(t, err, ErrInvalidPasswordReset)
+}
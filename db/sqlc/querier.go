@@ -6,32 +6,115 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error)
+	AttemptMFAChallenge(ctx context.Context, arg AttemptMFAChallengeParams) (MfaChallenge, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) ([]Session, error)
+	BlockUserSessions(ctx context.Context, username string) error
+	CancelAccountScheduledTransfers(ctx context.Context, accountID int64) ([]ScheduledTransfer, error)
+	ClaimTask(ctx context.Context, arg ClaimTaskParams) (Task, error)
+	CloseAccount(ctx context.Context, id int64) (Account, error)
+	CloseHold(ctx context.Context, arg CloseHoldParams) (Hold, error)
+	CompleteFunding(ctx context.Context, arg CompleteFundingParams) (Funding, error)
+	CompleteMFAChallenge(ctx context.Context, id int64) (int64, error)
+	CompleteTask(ctx context.Context, arg CompleteTaskParams) (int64, error)
+	CountPendingFundings(ctx context.Context, accountID int64) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateFunding(ctx context.Context, arg CreateFundingParams) (Funding, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateMFAChallenge(ctx context.Context, arg CreateMFAChallengeParams) (MfaChallenge, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	DeadLetterTask(ctx context.Context, arg DeadLetterTaskParams) (int64, error)
+	DelayLogin(ctx context.Context, arg DelayLoginParams) error
 	DeleteAccount(ctx context.Context, id int64) error
 	DeleteEntry(ctx context.Context, id int64) error
+	DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error
+	DeleteLoginThrottle(ctx context.Context, arg DeleteLoginThrottleParams) error
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteScheduledTransfer(ctx context.Context, id int64) error
 	DeleteSession(ctx context.Context, id uuid.UUID) error
 	DeleteTransfer(ctx context.Context, id int64) error
 	DeleteUser(ctx context.Context, username string) error
+	EnableTOTP(ctx context.Context, arg EnableTOTPParams) (User, error)
+	ExpirePasswordResets(ctx context.Context, username string) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetDueScheduledTransferForUpdate(ctx context.Context, now time.Time) (ScheduledTransfer, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	// concurrent sweepers skip the hold another one is expiring
+	GetExpiredHoldForUpdate(ctx context.Context, now time.Time) (Hold, error)
+	GetFunding(ctx context.Context, id int64) (Funding, error)
+	GetFundingForUpdate(ctx context.Context, id int64) (Funding, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetLastAuditHash(ctx context.Context) (string, error)
+	GetLoginThrottle(ctx context.Context, arg GetLoginThrottleParams) (LoginThrottle, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetScheduledTransferForUpdate(ctx context.Context, id int64) (ScheduledTransfer, error)
 	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetSettlementAccount(ctx context.Context, currency string) (Account, error)
+	GetTask(ctx context.Context, id int64) (Task, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserForUpdate(ctx context.Context, username string) (User, error)
+	ListAPIKeys(ctx context.Context, username string) ([]ApiKey, error)
+	ListAccountEntries(ctx context.Context, arg ListAccountEntriesParams) ([]Entry, error)
+	ListAccountHolds(ctx context.Context, arg ListAccountHoldsParams) ([]Hold, error)
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListActiveSessions(ctx context.Context, username string) ([]Session, error)
+	ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditEvent, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ListBalanceDiscrepancies(ctx context.Context) ([]ListBalanceDiscrepanciesRow, error)
+	ListDeadTasks(ctx context.Context, arg ListDeadTasksParams) ([]Task, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error)
+	// a cross-currency transfer has two more entries on the settlement accounts of its currencies
+	ListTransferDiscrepancies(ctx context.Context) ([]ListTransferDiscrepanciesRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	// serializes the appends until the transaction ends, so that every event links to the one before it
+	LockAuditChain(ctx context.Context) error
+	MarkSessionUsed(ctx context.Context, id uuid.UUID) (Session, error)
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error)
+	RequeueDeadTask(ctx context.Context, id int64) (Task, error)
+	RetryTask(ctx context.Context, arg RetryTaskParams) (int64, error)
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error)
+	RevokeUserAPIKeys(ctx context.Context, username string) error
+	SetFundingReference(ctx context.Context, arg SetFundingReferenceParams) (Funding, error)
+	SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error)
+	SetUserEmailVerified(ctx context.Context, arg SetUserEmailVerifiedParams) (User, error)
+	SumEntriesByCurrency(ctx context.Context) ([]SumEntriesByCurrencyRow, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error)
 	UpdateEntry(ctx context.Context, arg UpdateEntryParams) (Entry, error)
+	UpdateIdempotencyKeyResult(ctx context.Context, arg UpdateIdempotencyKeyResultParams) (IdempotencyKey, error)
+	UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error)
+	UpdateScheduledTransferRun(ctx context.Context, arg UpdateScheduledTransferRunParams) (ScheduledTransfer, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UsePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmail, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,360 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: scheduled_transfers.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const cancelAccountScheduledTransfers = `-- name: CancelAccountScheduledTransfers :many
+UPDATE scheduled_transfers SET
+  status = 'cancelled'
+WHERE (from_account_id = $1 OR to_account_id = $1)
+  AND status IN ('active', 'paused')
+RETURNING id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at
+`
+
+func (q *Queries) CancelAccountScheduledTransfers(ctx context.Context, accountID int64) ([]ScheduledTransfer, error) {
+	rows, err := q.db.QueryContext(ctx, cancelAccountScheduledTransfers, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransfer{}
+	for rows.Next() {
+		var i ScheduledTransfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Frequency,
+			&i.StartAt,
+			&i.NextRunAt,
+			&i.Runs,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.LastRunAt,
+			&i.LastTransferID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createScheduledTransfer = `-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $6
+)
+RETURNING id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at
+`
+
+type CreateScheduledTransferParams struct {
+	Owner         string    `json:"owner"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	Frequency     string    `json:"frequency"`
+	StartAt       time.Time `json:"start_at"`
+}
+
+func (q *Queries) CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledTransfer,
+		arg.Owner,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.Frequency,
+		arg.StartAt,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteScheduledTransfer = `-- name: DeleteScheduledTransfer :exec
+DELETE FROM scheduled_transfers WHERE id = $1
+`
+
+func (q *Queries) DeleteScheduledTransfer(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteScheduledTransfer, id)
+	return err
+}
+
+const getDueScheduledTransferForUpdate = `-- name: GetDueScheduledTransferForUpdate :one
+SELECT id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at FROM scheduled_transfers
+WHERE status = 'active' AND next_run_at <= $1
+ORDER BY next_run_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetDueScheduledTransferForUpdate(ctx context.Context, now time.Time) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, getDueScheduledTransferForUpdate, now)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getScheduledTransfer = `-- name: GetScheduledTransfer :one
+SELECT id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at FROM scheduled_transfers
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledTransfer, id)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getScheduledTransferForUpdate = `-- name: GetScheduledTransferForUpdate :one
+SELECT id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at FROM scheduled_transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetScheduledTransferForUpdate(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledTransferForUpdate, id)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listScheduledTransfers = `-- name: ListScheduledTransfers :many
+SELECT id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at FROM scheduled_transfers
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListScheduledTransfersParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTransfers, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransfer{}
+	for rows.Next() {
+		var i ScheduledTransfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Frequency,
+			&i.StartAt,
+			&i.NextRunAt,
+			&i.Runs,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.LastRunAt,
+			&i.LastTransferID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateScheduledTransfer = `-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers SET
+  amount = COALESCE($1, amount),
+  status = COALESCE($2, status),
+  runs = COALESCE($3, runs),
+  next_run_at = COALESCE($4, next_run_at)
+WHERE id = $5
+RETURNING id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at
+`
+
+type UpdateScheduledTransferParams struct {
+	Amount    sql.NullInt64  `json:"amount"`
+	Status    sql.NullString `json:"status"`
+	Runs      sql.NullInt32  `json:"runs"`
+	NextRunAt sql.NullTime   `json:"next_run_at"`
+	ID        int64          `json:"id"`
+}
+
+func (q *Queries) UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledTransfer,
+		arg.Amount,
+		arg.Status,
+		arg.Runs,
+		arg.NextRunAt,
+		arg.ID,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateScheduledTransferRun = `-- name: UpdateScheduledTransferRun :one
+UPDATE scheduled_transfers SET
+  runs = $1,
+  next_run_at = $2,
+  status = $3,
+  attempts = $4,
+  last_error = $5,
+  last_run_at = $6,
+  last_transfer_id = COALESCE($7, last_transfer_id)
+WHERE id = $8
+RETURNING id, owner, from_account_id, to_account_id, amount, frequency, start_at, next_run_at, runs, status, attempts, last_error, last_run_at, last_transfer_id, created_at
+`
+
+type UpdateScheduledTransferRunParams struct {
+	Runs           int32         `json:"runs"`
+	NextRunAt      time.Time     `json:"next_run_at"`
+	Status         string        `json:"status"`
+	Attempts       int32         `json:"attempts"`
+	LastError      string        `json:"last_error"`
+	LastRunAt      sql.NullTime  `json:"last_run_at"`
+	LastTransferID sql.NullInt64 `json:"last_transfer_id"`
+	ID             int64         `json:"id"`
+}
+
+func (q *Queries) UpdateScheduledTransferRun(ctx context.Context, arg UpdateScheduledTransferRunParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledTransferRun,
+		arg.Runs,
+		arg.NextRunAt,
+		arg.Status,
+		arg.Attempts,
+		arg.LastError,
+		arg.LastRunAt,
+		arg.LastTransferID,
+		arg.ID,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Frequency,
+		&i.StartAt,
+		&i.NextRunAt,
+		&i.Runs,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.LastTransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
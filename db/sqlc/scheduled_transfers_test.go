@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createDueScheduledTransfer(t *testing.T, fromAccount, toAccount Account, amount int64, frequency string) ScheduledTransfer {
+	schedule, err := testQueries.CreateScheduledTransfer(context.Background(), CreateScheduledTransferParams{
+		Owner:         fromAccount.Owner,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount,
+		Frequency:     frequency,
+		StartAt:       time.Now().Add(-time.Minute).Truncate(time.Microsecond),
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.ScheduleStatusActive, schedule.Status)
+	require.Equal(t, schedule.StartAt, schedule.NextRunAt)
+	return schedule
+}
+
+// createTestAccountInCurrency creates an account that scheduled transfers from accounts in currency can be paid into
+func createTestAccountInCurrency(t *testing.T, currency string) Account {
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    createRandomUser(t).Username,
+		Balance:  util.RandomAmount(),
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// runDueScheduledTransfer runs due schedules until the given one has been picked up,
+// other tests may have left due schedules behind
+func runDueScheduledTransfer(t *testing.T, store Store, id int64, now time.Time) RunScheduledTransferTxResult {
+	for {
+		result, err := store.RunScheduledTransferTx(context.Background(), now)
+		require.NoError(t, err)
+		if result.Schedule.ID == id {
+			return result
+		}
+	}
+}
+
+func TestScheduledTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	account2 := createTestAccountInCurrency(t, account1.Currency)
+
+	req := randomAuditRequest(account1.Owner)
+	schedule, err := store.CreateScheduledTransferTx(context.Background(), CreateScheduledTransferTxParams{
+		CreateScheduledTransferParams: CreateScheduledTransferParams{
+			Owner:         account1.Owner,
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        1,
+			Frequency:     util.FrequencyWeekly,
+			StartAt:       time.Now().Add(time.Hour),
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AuditActionCreateSchedule, getAuditEvent(t, req).Action)
+
+	req = randomAuditRequest(account1.Owner)
+	updated, err := store.UpdateScheduledTransferTx(context.Background(), UpdateScheduledTransferTxParams{
+		UpdateScheduledTransferParams: UpdateScheduledTransferParams{
+			ID:     schedule.ID,
+			Status: sql.NullString{String: util.ScheduleStatusPaused, Valid: true},
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.ScheduleStatusPaused, updated.Status)
+
+	event := getAuditEvent(t, req)
+	require.Equal(t, AuditActionUpdateSchedule, event.Action)
+	require.Equal(t, fmt.Sprint(schedule.ID), event.TargetID)
+	require.Contains(t, string(event.Before), util.ScheduleStatusActive)
+
+	req = randomAuditRequest(account1.Owner)
+	err = store.DeleteScheduledTransferTx(context.Background(), DeleteScheduledTransferTxParams{ID: schedule.ID, Audit: req})
+	require.NoError(t, err)
+	require.Equal(t, AuditActionDeleteSchedule, getAuditEvent(t, req).Action)
+
+	_, err = testQueries.GetScheduledTransfer(context.Background(), schedule.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = store.DeleteScheduledTransferTx(context.Background(), DeleteScheduledTransferTxParams{ID: schedule.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestRunScheduledTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	account2 := createTestAccountInCurrency(t, account1.Currency)
+
+	schedule := createDueScheduledTransfer(t, account1, account2, 1, util.FrequencyDaily)
+	now := time.Now()
+
+	result := runDueScheduledTransfer(t, store, schedule.ID, now)
+	require.NoError(t, result.Failure)
+	require.NotNil(t, result.Transfer)
+	require.Equal(t, account1.Balance-1, result.Transfer.FromAccount.Balance)
+
+	require.Equal(t, int32(1), result.Schedule.Runs)
+	require.Equal(t, schedule.StartAt.AddDate(0, 0, 1), result.Schedule.NextRunAt)
+	require.Equal(t, util.ScheduleStatusActive, result.Schedule.Status)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Schedule.LastTransferID.Int64)
+}
+
+func TestRunScheduledTransferTxRetry(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	account2 := createTestAccountInCurrency(t, account1.Currency)
+
+	schedule := createDueScheduledTransfer(t, account1, account2, account1.Balance+1, util.FrequencyOnce)
+	now := time.Now()
+
+	for attempt := int32(1); attempt < maxScheduledTransferAttempts; attempt++ {
+		result := runDueScheduledTransfer(t, store, schedule.ID, now)
+		require.ErrorIs(t, result.Failure, ErrInsufficientFunds)
+		require.Nil(t, result.Transfer)
+		require.Equal(t, attempt, result.Schedule.Attempts)
+		require.Equal(t, ErrInsufficientFunds.Error(), result.Schedule.LastError)
+		require.True(t, result.Schedule.NextRunAt.After(now))
+
+		// run the retry as soon as its backoff has passed
+		now = result.Schedule.NextRunAt
+	}
+
+	// the last attempt gives up on a one-off schedule
+	result := runDueScheduledTransfer(t, store, schedule.ID, now)
+	require.ErrorIs(t, result.Failure, ErrInsufficientFunds)
+	require.Equal(t, util.ScheduleStatusFailed, result.Schedule.Status)
+	require.Zero(t, result.Schedule.Attempts)
+
+	unchanged, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, unchanged.Balance)
+}
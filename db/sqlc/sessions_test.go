@@ -23,6 +23,7 @@ func createRandomSession(t *testing.T) Session {
 		ClientIp:     "",
 		IsBlocked:    false,
 		ExpiresAt:    time.Now().Add(time.Hour * 1),
+		FamilyID:     uuid,
 	}
 
 	session, err := testQueries.CreateSession(context.Background(), arg)
@@ -30,6 +31,8 @@ func createRandomSession(t *testing.T) Session {
 	require.NotEmpty(t, session)
 	require.Equal(t, arg.Username, session.Username)
 	require.Equal(t, randomToken, session.RefreshToken)
+	require.Equal(t, arg.FamilyID, session.FamilyID)
+	require.False(t, session.UsedAt.Valid)
 	return session
 }
 
@@ -52,6 +55,81 @@ func TestGetSession(t *testing.T) {
 	cleanUpSession(t, session.ID)
 }
 
+func TestRotateSessionTx(t *testing.T) {
+	store := NewStore(testDB)
+	session := createRandomSession(t)
+
+	arg := RotateSessionTxParams{
+		UsedSessionID: session.ID,
+		NextSession: CreateSessionParams{
+			ID:           uuid.New(),
+			Username:     session.Username,
+			RefreshToken: util.RandomString(32),
+			ExpiresAt:    time.Now().Add(time.Hour * 1),
+			FamilyID:     session.FamilyID,
+		},
+	}
+
+	next, err := store.RotateSessionTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.NextSession.ID, next.ID)
+	require.Equal(t, session.FamilyID, next.FamilyID)
+	require.False(t, next.UsedAt.Valid)
+
+	used, err := testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, used.UsedAt.Valid)
+
+	// the used session can't be rotated a second time
+	arg.NextSession.ID = uuid.New()
+	_, err = store.RotateSessionTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// a block is attributed to the user of the sessions
+	req := randomAuditRequest("")
+	blocked, err := store.BlockSessionFamilyTx(context.Background(), BlockSessionFamilyTxParams{
+		FamilyID: session.FamilyID,
+		Audit:    req,
+	})
+	require.NoError(t, err)
+	require.Len(t, blocked, 2)
+
+	req.Actor = session.Username
+	event := getAuditEvent(t, req)
+	require.Equal(t, AuditActionBlockSession, event.Action)
+	require.Equal(t, session.FamilyID.String(), event.TargetID)
+
+	for _, id := range []uuid.UUID{session.ID, next.ID} {
+		blocked, err := testQueries.GetSession(context.Background(), id)
+		require.NoError(t, err)
+		require.True(t, blocked.IsBlocked)
+	}
+
+	//cleanup
+	cleanUpSession(t, next.ID)
+	cleanUpSession(t, session.ID)
+}
+
+func TestListActiveSessions(t *testing.T) {
+	session := createRandomSession(t)
+
+	sessions, err := testQueries.ListActiveSessions(context.Background(), session.Username)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, session.ID, sessions[0].ID)
+
+	// a blocked session is no longer active
+	_, err = testQueries.BlockSessionFamily(context.Background(), session.FamilyID)
+	require.NoError(t, err)
+
+	sessions, err = testQueries.ListActiveSessions(context.Background(), session.Username)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	//cleanup
+	cleanUpSession(t, session.ID)
+}
+
 func cleanUpSession(t *testing.T, id uuid.UUID) {
 	err := testQueries.DeleteSession(context.Background(), id)
 	require.NoError(t, err)
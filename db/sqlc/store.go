@@ -3,12 +3,47 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/fx"
+	"github.com/muditshukla3/simplebank/util"
 )
 
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (IdempotentTransferTxResult, error)
+	RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (Session, error)
+	CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (CloseAccountTxResult, error)
+	UpdateAccountStatusTx(ctx context.Context, arg UpdateAccountStatusTxParams) (Account, error)
+	ExecSnapshotTx(ctx context.Context, fn func(Querier) error) error
+	CreateFundingTx(ctx context.Context, arg CreateFundingTxParams) (FundingTxResult, error)
+	CompleteFundingTx(ctx context.Context, arg CompleteFundingTxParams) (FundingTxResult, error)
+	PlaceHoldTx(ctx context.Context, arg PlaceHoldTxParams) (HoldTxResult, error)
+	CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (HoldTxResult, error)
+	ReleaseHoldTx(ctx context.Context, arg ReleaseHoldTxParams) (HoldTxResult, error)
+	ExpireHoldTx(ctx context.Context, now time.Time) (HoldTxResult, error)
+	RunScheduledTransferTx(ctx context.Context, now time.Time) (RunScheduledTransferTxResult, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (User, error)
+	CreateAccountTx(ctx context.Context, arg CreateAccountTxParams) (Account, error)
+	CreateSessionTx(ctx context.Context, arg CreateSessionTxParams) (Session, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (User, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error)
+	EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error)
+	EnrollTOTPTx(ctx context.Context, arg EnrollTOTPTxParams) (User, error)
+	UseRecoveryCodeTx(ctx context.Context, arg UseRecoveryCodeTxParams) (bool, error)
+	BlockSessionFamilyTx(ctx context.Context, arg BlockSessionFamilyTxParams) ([]Session, error)
+	CreateAPIKeyTx(ctx context.Context, arg CreateAPIKeyTxParams) (ApiKey, error)
+	RevokeAPIKeyTx(ctx context.Context, arg RevokeAPIKeyTxParams) (ApiKey, error)
+	CreateScheduledTransferTx(ctx context.Context, arg CreateScheduledTransferTxParams) (ScheduledTransfer, error)
+	UpdateScheduledTransferTx(ctx context.Context, arg UpdateScheduledTransferTxParams) (ScheduledTransfer, error)
+	DeleteScheduledTransferTx(ctx context.Context, arg DeleteScheduledTransferTxParams) error
 }
 
 //store provides all functions to execute db queries and transactions
@@ -44,10 +79,58 @@ func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) erro
 	return tx.Commit()
 }
 
+// ExecSnapshotTx runs fn in a read only transaction that sees a single snapshot of the database,
+// for reports that have to read several tables consistently while transfers keep running
+func (store *SQLStore) ExecSnapshotTx(ctx context.Context, fn func(Querier) error) error {
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(New(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rbErr %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var (
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrAccountFrozen         = errors.New("account is frozen")
+	ErrInvalidTransferAmount = errors.New("transfer amount must be positive")
+	ErrSameAccountTransfer   = errors.New("cannot transfer to the same account")
+)
+
+// AccountFrozenError tells which account of a transfer is frozen, it matches ErrAccountFrozen
+type AccountFrozenError struct {
+	AccountID int64
+}
+
+func (e *AccountFrozenError) Error() string {
+	return fmt.Sprintf("account [%d] is frozen", e.AccountID)
+}
+
+func (e *AccountFrozenError) Unwrap() error {
+	return ErrAccountFrozen
+}
+
+// TransferTxParams describes a transfer. Amount is debited in the currency of the source account.
+// For a cross-currency transfer ToAmount is credited in the currency of the destination account,
+// and ExchangeRate and SpreadBps record the fx quote that produced it. Same-currency transfers leave them zero.
+// A cross-currency transfer is posted through the settlement accounts of both currencies, so the entries of
+// each currency still sum to zero
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	ToAmount      int64 `json:"to_amount,omitempty"`
+	ExchangeRate  int64 `json:"exchange_rate,omitempty"`
+	SpreadBps     int32 `json:"spread_bps,omitempty"`
+	// Audit attributes the transfer in the audit log
+	Audit AuditRequest `json:"-"`
 }
 
 type TransferTxResult struct {
@@ -56,88 +139,1571 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// SettlementEntries credit Amount to the settlement account of the source currency and debit ToAmount
+	// from the one of the destination currency, only cross-currency transfers have them
+	SettlementEntries []Entry `json:"settlement_entries,omitempty"`
 }
 
 //transfer performs money transfer from one account to another account
 // It creates a transfer record, add account entires, and update accounts balance withing single database transaction
+// It fails with ErrInsufficientFunds if the source account would go beyond its overdraft limit,
+// with an AccountFrozenError if either account is frozen, with ErrAccountNotActive if either is closed
+// and with ErrSameAccountTransfer if both are the same account
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 
 	var result TransferTxResult
 	err := store.execTx(ctx, func(q *Queries) error {
 		var err error
+		result, err = transfer(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateTransfer,
+			TargetType: AuditTargetTransfer,
+			TargetID:   fmt.Sprint(result.Transfer.ID),
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// transfer moves money between two accounts using the given queries,
+// which must be bound to an open transaction
+func transfer(ctx context.Context, q *Queries, arg TransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+	var err error
+
+	if arg.ToAmount == 0 {
+		arg.ToAmount = arg.Amount
+		arg.ExchangeRate = fx.RateScale
+		arg.SpreadBps = 0
+	}
+
+	// a negative amount would move money out of the destination account, which is never checked for funds
+	if arg.Amount <= 0 || arg.ToAmount <= 0 {
+		return result, ErrInvalidTransferAmount
+	}
+
+	if arg.FromAccountID == arg.ToAccountID {
+		return result, ErrSameAccountTransfer
+	}
+
+	// the currency of an account never changes, so it can be read before the balances are locked
+	fromAccount, err := q.GetAccount(ctx, arg.FromAccountID)
+	if err != nil {
+		return result, err
+	}
+	toAccount, err := q.GetAccount(ctx, arg.ToAccountID)
+	if err != nil {
+		return result, err
+	}
+	crossCurrency := fromAccount.Currency != toAccount.Currency
+	if !crossCurrency && arg.ToAmount != arg.Amount {
+		return result, ErrInvalidTransferAmount
+	}
+
+	result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		ToAmount:      arg.ToAmount,
+		ExchangeRate:  arg.ExchangeRate,
+		SpreadBps:     arg.SpreadBps,
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	transferID := sql.NullInt64{Int64: result.Transfer.ID, Valid: true}
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.FromAccountID,
+		Amount:     -arg.Amount,
+		TransferID: transferID,
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.ToAccountID,
+		Amount:     arg.ToAmount,
+		TransferID: transferID,
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	amounts := make(map[int64]int64)
+	amounts[arg.FromAccountID] -= arg.Amount
+	amounts[arg.ToAccountID] += arg.ToAmount
+
+	if crossCurrency {
+		// the bank buys the source currency and sells the destination currency
+		legs := []struct {
+			currency string
+			amount   int64
+		}{
+			{currency: fromAccount.Currency, amount: arg.Amount},
+			{currency: toAccount.Currency, amount: -arg.ToAmount},
+		}
+		for _, leg := range legs {
+			settlement, err := q.GetSettlementAccount(ctx, leg.currency)
+			if err != nil {
+				return result, err
+			}
+
+			entry, err := q.CreateEntry(ctx, CreateEntryParams{
+				AccountID:  settlement.ID,
+				Amount:     leg.amount,
+				TransferID: transferID,
+			})
+			if err != nil {
+				return result, err
+			}
+
+			result.SettlementEntries = append(result.SettlementEntries, entry)
+			amounts[settlement.ID] += leg.amount
+		}
+	}
+
+	// update accounts balance
+	accounts, err := addBalances(ctx, q, amounts)
+	if err != nil {
+		return result, err
+	}
+	result.FromAccount = accounts[arg.FromAccountID]
+	result.ToAccount = accounts[arg.ToAccountID]
+
+	// the balance update holds the row locks, so a concurrent freeze has either committed or waits for this transfer
+	for _, account := range []Account{result.FromAccount, result.ToAccount} {
+		switch account.Status {
+		case util.AccountStatusFrozen:
+			return result, &AccountFrozenError{AccountID: account.ID}
+		case util.AccountStatusClosed:
+			return result, ErrAccountNotActive
+		}
+	}
+
+	// the balance update holds the row lock, so the returned balance already accounts for concurrent transfers.
+	// Money reserved by holds can't be spent
+	if result.FromAccount.AvailableBalance < -result.FromAccount.OverdraftLimit {
+		return result, ErrInsufficientFunds
+	}
+
+	return result, nil
+}
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+type IdempotentTransferTxParams struct {
+	TransferTxParams
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	RequestHash    string `json:"request_hash"`
+}
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: arg.FromAccountID,
-			ToAccountID:   arg.ToAccountID,
-			Amount:        arg.Amount,
+type IdempotentTransferTxResult struct {
+	TransferTxResult
+	Replayed bool `json:"-"`
+}
+
+// IdempotentTransferTx performs a money transfer at most once per idempotency key.
+// A replay of a key with the same request hash returns the stored result without touching balances or the audit log,
+// while a replay with a different request hash fails with ErrIdempotencyKeyReused
+func (store *SQLStore) IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (IdempotentTransferTxResult, error) {
+	var result IdempotentTransferTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		// a concurrent request with the same key blocks here until the first one commits or rolls back
+		_, err := q.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+			Username:       arg.Username,
+			IdempotencyKey: arg.IdempotencyKey,
+			RequestHash:    arg.RequestHash,
 		})
 
+		if err == sql.ErrNoRows {
+			key, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+				Username:       arg.Username,
+				IdempotencyKey: arg.IdempotencyKey,
+			})
+			if err != nil {
+				return err
+			}
+
+			result.TransferTxResult, err = ReplayIdempotentTransfer(key, arg.RequestHash)
+			result.Replayed = err == nil
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result.TransferTxResult, err = transfer(ctx, q, arg.TransferTxParams)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(result.TransferTxResult)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.UpdateIdempotencyKeyResult(ctx, UpdateIdempotencyKeyResultParams{
+			Username:       arg.Username,
+			IdempotencyKey: arg.IdempotencyKey,
+			Result:         data,
+		})
 		if err != nil {
 			return err
 		}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateTransfer,
+			TargetType: AuditTargetTransfer,
+			TargetID:   fmt.Sprint(result.Transfer.ID),
+			After:      result.TransferTxResult,
 		})
+	})
+
+	return result, err
+}
+
+// ReplayIdempotentTransfer decodes the result stored with an idempotency key for a retry of the same request.
+// It fails with ErrIdempotencyKeyReused if the key was first used with a different request hash
+func ReplayIdempotentTransfer(key IdempotencyKey, requestHash string) (TransferTxResult, error) {
+	var result TransferTxResult
+	if key.RequestHash != requestHash {
+		return result, ErrIdempotencyKeyReused
+	}
+
+	err := json.Unmarshal(key.Result, &result)
+	return result, err
+}
+
+// CreateUserTxParams creates a user and runs AfterCreate with the queries of the same transaction,
+// so that whatever it writes, such as background tasks, is only committed together with the user
+type CreateUserTxParams struct {
+	CreateUserParams
+	AfterCreate func(q Querier, user User) error
+	Audit       AuditRequest
+}
 
+// CreateUserTx creates a user within a single database transaction, which is rolled back if AfterCreate fails
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (User, error) {
+	var user User
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.CreateUser(ctx, arg.CreateUserParams)
 		if err != nil {
 			return err
 		}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
+		if arg.AfterCreate != nil {
+			if err := arg.AfterCreate(q, user); err != nil {
+				return err
+			}
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateUser,
+			TargetType: AuditTargetUser,
+			TargetID:   user.Username,
+			After:      newAuditUser(user),
+		})
+	})
+
+	return user, err
+}
+
+// CreateAccountTxParams creates an account and attributes it in the audit log
+type CreateAccountTxParams struct {
+	CreateAccountParams
+	Audit AuditRequest
+}
+
+// CreateAccountTx creates an account and records it in the audit log within a single database transaction
+func (store *SQLStore) CreateAccountTx(ctx context.Context, arg CreateAccountTxParams) (Account, error) {
+	var account Account
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		account, err = q.CreateAccount(ctx, arg.CreateAccountParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateAccount,
+			TargetType: AuditTargetAccount,
+			TargetID:   fmt.Sprint(account.ID),
+			After:      account,
 		})
+	})
+
+	return account, err
+}
+
+// CreateSessionTxParams creates the session of a login and attributes it in the audit log
+type CreateSessionTxParams struct {
+	CreateSessionParams
+	Audit AuditRequest
+}
 
+// CreateSessionTx creates a session and records the login in the audit log within a single database transaction
+func (store *SQLStore) CreateSessionTx(ctx context.Context, arg CreateSessionTxParams) (Session, error) {
+	var session Session
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		session, err = q.CreateSession(ctx, arg.CreateSessionParams)
 		if err != nil {
 			return err
 		}
 
-		// update accounts balance
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
-			if err != nil {
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateSession,
+			TargetType: AuditTargetSession,
+			TargetID:   session.ID.String(),
+			After:      newAuditSession(session),
+		})
+	})
+
+	return session, err
+}
+
+// UpdateUserTxParams updates the fields of a user that are set. A new Password must already be hashed,
+// AfterUpdate runs with the queries of the same transaction, like AfterCreate of CreateUserTxParams
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	AfterUpdate func(q Querier, user User) error
+	Audit       AuditRequest
+}
+
+// UpdateUserTx updates a user within a single database transaction. Changing the password bumps
+// password_changed_at, blocks every session of the user, which revokes the tokens issued for them,
+// and revokes the user's api keys, so a key created from a stolen session doesn't outlive the change
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (User, error) {
+	var user User
+	err := store.execTx(ctx, func(q *Queries) error {
+		params := arg.UpdateUserParams
+		if params.Password.Valid {
+			params.PasswordChangedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		before, err := q.GetUserForUpdate(ctx, params.Username)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		if params.Password.Valid {
+			if err := q.BlockUserSessions(ctx, user.Username); err != nil {
 				return err
 			}
-		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
-			if err != nil {
+			if err := q.RevokeUserAPIKeys(ctx, user.Username); err != nil {
+				return err
+			}
+		}
+
+		if arg.AfterUpdate != nil {
+			if err := arg.AfterUpdate(q, user); err != nil {
 				return err
 			}
 		}
 
-		return nil
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionUpdateUser,
+			TargetType: AuditTargetUser,
+			TargetID:   user.Username,
+			Before:     newAuditUser(before),
+			After:      newAuditUser(user),
+		})
 	})
 
-	return result, err
+	return user, err
 }
 
-func addMoney(
-	ctx context.Context,
-	q *Queries,
-	accountID1 int64,
-	amount1 int64,
-	accountID2 int64,
-	amount2 int64,
-) (account1 Account, account2 Account, err error) {
-	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID1,
-		Amount: amount1,
+var ErrInvalidPasswordReset = errors.New("password reset token is invalid, used or expired")
+
+// ResetPasswordTxParams sets Password, which must already be hashed, for the user TokenHash was issued to.
+// The change is attributed to that user unless Audit names another actor
+type ResetPasswordTxParams struct {
+	TokenHash string
+	Password  string
+	Audit     AuditRequest
+}
+
+// ResetPasswordTx uses up a password reset token and changes the password within a single database transaction.
+// Like a password change it blocks every session and revokes the api keys of the user,
+// and it voids the user's other reset tokens.
+// It fails with ErrInvalidPasswordReset if the token is unknown, used or expired
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error) {
+	var user User
+	err := store.execTx(ctx, func(q *Queries) error {
+		reset, err := q.UsePasswordReset(ctx, arg.TokenHash)
+		if err == sql.ErrNoRows {
+			return ErrInvalidPasswordReset
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUser(ctx, UpdateUserParams{
+			Username:          reset.Username,
+			Password:          sql.NullString{String: arg.Password, Valid: true},
+			PasswordChangedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.BlockUserSessions(ctx, user.Username); err != nil {
+			return err
+		}
+		if err := q.RevokeUserAPIKeys(ctx, user.Username); err != nil {
+			return err
+		}
+		if err := q.ExpirePasswordResets(ctx, user.Username); err != nil {
+			return err
+		}
+
+		if len(arg.Audit.Actor) == 0 {
+			arg.Audit.Actor = user.Username
+		}
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionResetPassword,
+			TargetType: AuditTargetUser,
+			TargetID:   user.Username,
+			After:      newAuditUser(user),
+		})
 	})
 
-	if err != nil {
-		return
-	}
+	return user, err
+}
 
-	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID2,
-		Amount: amount2,
+var ErrTOTPNotPending = errors.New("two-factor authentication is already enabled or has not been enrolled")
+
+// EnableTOTPTxParams turns on two-factor authentication after the user proved with the code of Step
+// that the enrolled secret works. RecoveryCodeHashes replace any earlier recovery codes of the user
+type EnableTOTPTxParams struct {
+	Username           string
+	Step               int64
+	RecoveryCodeHashes []string
+	Audit              AuditRequest
+}
+
+// EnableTOTPTx enables two-factor authentication and stores the recovery codes within a single database transaction.
+// It fails with ErrTOTPNotPending if it is already enabled, e.g. by a concurrent confirmation, or no secret was enrolled
+func (store *SQLStore) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error) {
+	var user User
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.EnableTOTP(ctx, EnableTOTPParams{
+			Username:         arg.Username,
+			TotpLastUsedStep: arg.Step,
+		})
+		if err == sql.ErrNoRows {
+			return ErrTOTPNotPending
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		for _, codeHash := range arg.RecoveryCodeHashes {
+			_, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username: arg.Username,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionEnableTOTP,
+			TargetType: AuditTargetUser,
+			TargetID:   user.Username,
+			After:      newAuditUser(user),
+		})
 	})
 
-	if err != nil {
-		return
-	}
+	return user, err
+}
 
-	return
+// EnrollTOTPTxParams stores a new TOTP secret of a user who hasn't enabled two-factor authentication yet
+type EnrollTOTPTxParams struct {
+	SetTOTPSecretParams
+	Audit AuditRequest
+}
+
+// EnrollTOTPTx replaces the TOTP secret and records the enrollment within a single database transaction.
+// It fails with sql.ErrNoRows if two-factor authentication is already enabled
+func (store *SQLStore) EnrollTOTPTx(ctx context.Context, arg EnrollTOTPTxParams) (User, error) {
+	var user User
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.SetTOTPSecret(ctx, arg.SetTOTPSecretParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionEnrollTOTP,
+			TargetType: AuditTargetUser,
+			TargetID:   user.Username,
+			After:      newAuditUser(user),
+		})
+	})
+
+	return user, err
+}
+
+// UseRecoveryCodeTxParams uses up the recovery code of CodeHash. The use is attributed to the user of the code
+// unless Audit names another actor
+type UseRecoveryCodeTxParams struct {
+	UseRecoveryCodeParams
+	Audit AuditRequest
+}
+
+// UseRecoveryCodeTx marks an unused recovery code used and records it within a single database transaction.
+// It reports false, and records nothing, if the code is unknown or was used before
+func (store *SQLStore) UseRecoveryCodeTx(ctx context.Context, arg UseRecoveryCodeTxParams) (bool, error) {
+	var used bool
+	err := store.execTx(ctx, func(q *Queries) error {
+		rows, err := q.UseRecoveryCode(ctx, arg.UseRecoveryCodeParams)
+		if err != nil || rows != 1 {
+			return err
+		}
+		used = true
+
+		if len(arg.Audit.Actor) == 0 {
+			arg.Audit.Actor = arg.Username
+		}
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionUseRecoveryCode,
+			TargetType: AuditTargetUser,
+			TargetID:   arg.Username,
+		})
+	})
+
+	return used, err
+}
+
+// CreateAPIKeyTxParams creates an api key and attributes it in the audit log
+type CreateAPIKeyTxParams struct {
+	CreateAPIKeyParams
+	Audit AuditRequest
+}
+
+// CreateAPIKeyTx creates an api key and records it in the audit log within a single database transaction
+func (store *SQLStore) CreateAPIKeyTx(ctx context.Context, arg CreateAPIKeyTxParams) (ApiKey, error) {
+	var apiKey ApiKey
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		apiKey, err = q.CreateAPIKey(ctx, arg.CreateAPIKeyParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateAPIKey,
+			TargetType: AuditTargetAPIKey,
+			TargetID:   fmt.Sprint(apiKey.ID),
+			After:      newAuditAPIKey(apiKey),
+		})
+	})
+
+	return apiKey, err
+}
+
+// RevokeAPIKeyTxParams revokes the key ID of Username
+type RevokeAPIKeyTxParams struct {
+	RevokeAPIKeyParams
+	Audit AuditRequest
+}
+
+// RevokeAPIKeyTx revokes an api key and records it in the audit log within a single database transaction.
+// It fails with sql.ErrNoRows if the user has no such key or it is already revoked
+func (store *SQLStore) RevokeAPIKeyTx(ctx context.Context, arg RevokeAPIKeyTxParams) (ApiKey, error) {
+	var apiKey ApiKey
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		apiKey, err = q.RevokeAPIKey(ctx, arg.RevokeAPIKeyParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionRevokeAPIKey,
+			TargetType: AuditTargetAPIKey,
+			TargetID:   fmt.Sprint(apiKey.ID),
+			After:      newAuditAPIKey(apiKey),
+		})
+	})
+
+	return apiKey, err
+}
+
+var ErrInvalidVerifyEmail = errors.New("email verification code is invalid, used or expired")
+
+// VerifyEmailTxParams uses the code of EmailID. The verification is attributed to the user it was sent to
+// unless Audit names another actor
+type VerifyEmailTxParams struct {
+	EmailID    int64
+	SecretCode string
+	Audit      AuditRequest
+}
+
+type VerifyEmailTxResult struct {
+	User        User
+	VerifyEmail VerifyEmail
+}
+
+// VerifyEmailTx uses up a verification code and marks the email of its user verified within a single database transaction.
+// It fails with ErrInvalidVerifyEmail if the code is unknown, used or expired, or if the user changed the email since
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.VerifyEmail, err = q.UseVerifyEmail(ctx, UseVerifyEmailParams{
+			ID:         arg.EmailID,
+			SecretCode: arg.SecretCode,
+		})
+		if err == sql.ErrNoRows {
+			return ErrInvalidVerifyEmail
+		}
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.SetUserEmailVerified(ctx, SetUserEmailVerifiedParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		if err == sql.ErrNoRows {
+			return ErrInvalidVerifyEmail
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(arg.Audit.Actor) == 0 {
+			arg.Audit.Actor = result.User.Username
+		}
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionVerifyEmail,
+			TargetType: AuditTargetUser,
+			TargetID:   result.User.Username,
+			After:      newAuditUser(result.User),
+		})
+	})
+
+	return result, err
+}
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+// RotateSessionTxParams replaces the session UsedSessionID with NextSession,
+// which should carry the same FamilyID
+type RotateSessionTxParams struct {
+	UsedSessionID uuid.UUID
+	NextSession   CreateSessionParams
+	Audit         AuditRequest
+}
+
+// RotateSessionTx marks a session used and creates its successor within a single database transaction.
+// It fails with ErrRefreshTokenReused if the session was already used, e.g. by a concurrent renewal
+func (store *SQLStore) RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (Session, error) {
+	var next Session
+	err := store.execTx(ctx, func(q *Queries) error {
+		used, err := q.MarkSessionUsed(ctx, arg.UsedSessionID)
+		if err == sql.ErrNoRows {
+			return ErrRefreshTokenReused
+		}
+		if err != nil {
+			return err
+		}
+
+		next, err = q.CreateSession(ctx, arg.NextSession)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionRotateSession,
+			TargetType: AuditTargetSession,
+			TargetID:   next.ID.String(),
+			Before:     newAuditSession(used),
+			After:      newAuditSession(next),
+		})
+	})
+
+	return next, err
+}
+
+// BlockSessionFamilyTxParams blocks every session of FamilyID. The block is attributed to the user of the sessions
+// unless Audit names another actor
+type BlockSessionFamilyTxParams struct {
+	FamilyID uuid.UUID
+	Audit    AuditRequest
+}
+
+// BlockSessionFamilyTx blocks the sessions rotated from the same login and records it within a single database transaction
+func (store *SQLStore) BlockSessionFamilyTx(ctx context.Context, arg BlockSessionFamilyTxParams) ([]Session, error) {
+	var sessions []Session
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		sessions, err = q.BlockSessionFamily(ctx, arg.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		blocked := make([]auditSession, len(sessions))
+		for i, session := range sessions {
+			blocked[i] = newAuditSession(session)
+			if len(arg.Audit.Actor) == 0 {
+				arg.Audit.Actor = session.Username
+			}
+		}
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionBlockSession,
+			TargetType: AuditTargetSession,
+			TargetID:   arg.FamilyID.String(),
+			After:      blocked,
+		})
+	})
+
+	return sessions, err
+}
+
+var (
+	ErrAccountNotActive          = errors.New("account is not active")
+	ErrAccountNotEmpty           = errors.New("account balance must be zero or swept to another account")
+	ErrAccountHasHolds           = errors.New("account has active holds")
+	ErrAccountHasPendingFundings = errors.New("account has pending fundings")
+)
+
+// CloseAccountTxParams closes AccountID. A positive balance is moved to SweepToAccountID first,
+// leave it zero to only close an empty account
+type CloseAccountTxParams struct {
+	AccountID        int64        `json:"account_id"`
+	SweepToAccountID int64        `json:"sweep_to_account_id"`
+	Audit            AuditRequest `json:"-"`
+}
+
+type CloseAccountTxResult struct {
+	Account Account           `json:"account"`
+	Sweep   *TransferTxResult `json:"sweep,omitempty"`
+	// CancelledSchedules are the active or paused schedules from or to the account, which can't run anymore
+	CancelledSchedules []ScheduledTransfer `json:"cancelled_schedules"`
+}
+
+// CloseAccountTx sweeps the remaining balance and marks the account closed within a single database transaction.
+// It fails with ErrAccountNotActive if the account is frozen or already closed, with ErrAccountHasHolds
+// while holds reserve part of its balance, with ErrAccountHasPendingFundings while a deposit or withdrawal is
+// pending and with ErrAccountNotEmpty if a balance is left that can't be swept. Schedules from or to the account are cancelled
+func (store *SQLStore) CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (CloseAccountTxResult, error) {
+	var result CloseAccountTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		// lock the accounts in id order like addMoney, so the sweep can't deadlock with a concurrent transfer
+		ids := []int64{arg.AccountID}
+		if arg.SweepToAccountID != 0 {
+			ids = append(ids, arg.SweepToAccountID)
+			if arg.SweepToAccountID < arg.AccountID {
+				ids[0], ids[1] = ids[1], ids[0]
+			}
+		}
+
+		var account Account
+		for _, id := range ids {
+			locked, err := q.GetAccountForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			if id == arg.AccountID {
+				account = locked
+			}
+		}
+
+		if account.Status != util.AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		if account.HeldAmount != 0 {
+			return ErrAccountHasHolds
+		}
+
+		// a pending deposit would still be credited once settled, CreateFundingTx can't add one under the lock
+		pending, err := q.CountPendingFundings(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+		if pending != 0 {
+			return ErrAccountHasPendingFundings
+		}
+
+		if account.Balance != 0 {
+			if account.Balance < 0 || arg.SweepToAccountID == 0 {
+				return ErrAccountNotEmpty
+			}
+
+			sweep, err := transfer(ctx, q, TransferTxParams{
+				FromAccountID: arg.AccountID,
+				ToAccountID:   arg.SweepToAccountID,
+				Amount:        account.Balance,
+			})
+			if err != nil {
+				return err
+			}
+			result.Sweep = &sweep
+		}
+
+		result.CancelledSchedules, err = q.CancelAccountScheduledTransfers(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		result.Account, err = q.CloseAccount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCloseAccount,
+			TargetType: AuditTargetAccount,
+			TargetID:   fmt.Sprint(arg.AccountID),
+			Before:     account,
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+var ErrAccountNotFrozen = errors.New("account is not frozen")
+
+// UpdateAccountStatusTxParams freezes an active account or unfreezes a frozen one, Status is the new status.
+// Reason is kept on the account and in the audit log
+type UpdateAccountStatusTxParams struct {
+	AccountID int64        `json:"account_id"`
+	Status    string       `json:"status"`
+	Reason    string       `json:"reason"`
+	Audit     AuditRequest `json:"-"`
+}
+
+// UpdateAccountStatusTx changes the status of an account and records it in the audit log within a single database transaction.
+// Freezing fails with ErrAccountNotActive unless the account is active, unfreezing with ErrAccountNotFrozen unless it is frozen.
+// Closed accounts stay closed, CloseAccountTx is the only way to close one
+func (store *SQLStore) UpdateAccountStatusTx(ctx context.Context, arg UpdateAccountStatusTxParams) (Account, error) {
+	var account Account
+	err := store.execTx(ctx, func(q *Queries) error {
+		// the lock makes transfers in flight finish before the status changes
+		before, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		var action string
+		switch arg.Status {
+		case util.AccountStatusFrozen:
+			if before.Status != util.AccountStatusActive {
+				return ErrAccountNotActive
+			}
+			action = AuditActionFreezeAccount
+		case util.AccountStatusActive:
+			if before.Status != util.AccountStatusFrozen {
+				return ErrAccountNotFrozen
+			}
+			action = AuditActionUnfreezeAccount
+		default:
+			return fmt.Errorf("cannot change account status to %s", arg.Status)
+		}
+
+		account, err = q.UpdateAccountStatus(ctx, UpdateAccountStatusParams{
+			ID:           arg.AccountID,
+			Status:       arg.Status,
+			StatusReason: arg.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     action,
+			TargetType: AuditTargetAccount,
+			TargetID:   fmt.Sprint(account.ID),
+			Before:     before,
+			After:      account,
+		})
+	})
+
+	return account, err
+}
+
+var ErrFundingNotPending = errors.New("funding is no longer pending")
+
+// CreateFundingTxParams starts a deposit into or a withdrawal from AccountID, Kind is one of the util.FundingKind values
+type CreateFundingTxParams struct {
+	AccountID int64        `json:"account_id"`
+	Kind      string       `json:"kind"`
+	Amount    int64        `json:"amount"`
+	Audit     AuditRequest `json:"-"`
+}
+
+type FundingTxResult struct {
+	Funding Funding `json:"funding"`
+	Account Account `json:"account"`
+}
+
+// CreateFundingTx records a pending funding within a single database transaction.
+// A withdrawal moves the amount to the settlement account right away, so it can't be spent again while the provider
+// pays it out, and fails with ErrInsufficientFunds if the account would go beyond its overdraft limit.
+// A deposit only moves money once it is settled. Both fail with ErrAccountNotActive unless the account is active
+func (store *SQLStore) CreateFundingTx(ctx context.Context, arg CreateFundingTxParams) (FundingTxResult, error) {
+	var result FundingTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.Funding, err = q.CreateFunding(ctx, CreateFundingParams{
+			AccountID: arg.AccountID,
+			Kind:      arg.Kind,
+			Amount:    arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.Kind != util.FundingKindWithdrawal {
+			// the lock waits for a concurrent CloseAccountTx, which can't see this funding yet
+			result.Account, err = q.GetAccountForUpdate(ctx, arg.AccountID)
+			if err != nil {
+				return err
+			}
+		} else {
+			result.Account, err = bookFunding(ctx, q, result.Funding, -result.Funding.Amount)
+			if err != nil {
+				return err
+			}
+
+			if result.Account.AvailableBalance < -result.Account.OverdraftLimit {
+				return ErrInsufficientFunds
+			}
+		}
+
+		if result.Account.Status != util.AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateFunding,
+			TargetType: AuditTargetFunding,
+			TargetID:   fmt.Sprint(result.Funding.ID),
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// CompleteFundingTxParams records the outcome reported by the provider, Status is settled or failed
+type CompleteFundingTxParams struct {
+	ID            int64        `json:"id"`
+	Status        string       `json:"status"`
+	FailureReason string       `json:"failure_reason"`
+	Audit         AuditRequest `json:"-"`
+}
+
+// CompleteFundingTx settles or fails a pending funding within a single database transaction.
+// A settled deposit credits the account and a failed withdrawal refunds it, the other outcomes move no money.
+// It fails with ErrFundingNotPending if the funding was already completed
+func (store *SQLStore) CompleteFundingTx(ctx context.Context, arg CompleteFundingTxParams) (FundingTxResult, error) {
+	var result FundingTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		funding, err := q.GetFundingForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		if funding.Status != util.FundingStatusPending {
+			return ErrFundingNotPending
+		}
+
+		result.Funding, err = q.CompleteFunding(ctx, CompleteFundingParams{
+			ID:            arg.ID,
+			Status:        arg.Status,
+			FailureReason: arg.FailureReason,
+		})
+		if err != nil {
+			return err
+		}
+
+		settledDeposit := funding.Kind == util.FundingKindDeposit && arg.Status == util.FundingStatusSettled
+		failedWithdrawal := funding.Kind == util.FundingKindWithdrawal && arg.Status == util.FundingStatusFailed
+		if !settledDeposit && !failedWithdrawal {
+			result.Account, err = q.GetAccount(ctx, funding.AccountID)
+		} else {
+			// the money has already left the provider, so it is credited even if the account was frozen meanwhile
+			result.Account, err = bookFunding(ctx, q, funding, funding.Amount)
+		}
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCompleteFunding,
+			TargetType: AuditTargetFunding,
+			TargetID:   fmt.Sprint(funding.ID),
+			Before:     funding,
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// bookFunding adds amount to the funded account and the opposite amount to the settlement account of its currency,
+// so the entries of every currency keep summing to zero. It returns the funded account
+func bookFunding(ctx context.Context, q *Queries, funding Funding, amount int64) (Account, error) {
+	account, err := q.GetAccount(ctx, funding.AccountID)
+	if err != nil {
+		return account, err
+	}
+
+	settlement, err := q.GetSettlementAccount(ctx, account.Currency)
+	if err != nil {
+		return account, err
+	}
+
+	fundingID := sql.NullInt64{Int64: funding.ID, Valid: true}
+	_, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: account.ID,
+		Amount:    amount,
+		FundingID: fundingID,
+	})
+	if err != nil {
+		return account, err
+	}
+
+	_, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: settlement.ID,
+		Amount:    -amount,
+		FundingID: fundingID,
+	})
+	if err != nil {
+		return account, err
+	}
+
+	if account.ID < settlement.ID {
+		account, _, err = addMoney(ctx, q, account.ID, amount, settlement.ID, -amount)
+	} else {
+		_, account, err = addMoney(ctx, q, settlement.ID, -amount, account.ID, amount)
+	}
+	return account, err
+}
+
+var (
+	ErrHoldNotActive      = errors.New("hold is no longer active")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the hold")
+)
+
+// PlaceHoldTxParams reserves Amount on AccountID until ExpiresAt, for a later capture into ToAccountID
+type PlaceHoldTxParams struct {
+	AccountID   int64        `json:"account_id"`
+	ToAccountID int64        `json:"to_account_id"`
+	Amount      int64        `json:"amount"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	Audit       AuditRequest `json:"-"`
+}
+
+type HoldTxResult struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+	// Transfer is the transfer of a capture
+	Transfer *TransferTxResult `json:"transfer,omitempty"`
+}
+
+// PlaceHoldTx reserves money on an account within a single database transaction. The held amount lowers the
+// available balance but not the balance, so it can't be spent by transfers or withdrawals until the hold is closed.
+// It fails with an AccountFrozenError if the account is frozen, with ErrAccountNotActive if it is closed
+// and with ErrInsufficientFunds if the available balance would go beyond the overdraft limit
+func (store *SQLStore) PlaceHoldTx(ctx context.Context, arg PlaceHoldTxParams) (HoldTxResult, error) {
+	var result HoldTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		// the update holds the row lock, so the available balance accounts for concurrent transfers and holds
+		result.Account, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			ID:     arg.AccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch result.Account.Status {
+		case util.AccountStatusFrozen:
+			return &AccountFrozenError{AccountID: result.Account.ID}
+		case util.AccountStatusClosed:
+			return ErrAccountNotActive
+		}
+
+		if result.Account.AvailableBalance < -result.Account.OverdraftLimit {
+			return ErrInsufficientFunds
+		}
+
+		result.Hold, err = q.CreateHold(ctx, CreateHoldParams{
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionPlaceHold,
+			TargetType: AuditTargetHold,
+			TargetID:   fmt.Sprint(result.Hold.ID),
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// CaptureHoldTxParams captures Amount of the hold HoldID, leave it zero to capture the whole hold
+type CaptureHoldTxParams struct {
+	HoldID int64        `json:"hold_id"`
+	Amount int64        `json:"amount"`
+	Audit  AuditRequest `json:"-"`
+}
+
+// CaptureHoldTx moves the captured amount to the destination account of the hold with a transfer and releases
+// the rest, within a single database transaction. It fails with ErrHoldNotActive if the hold is closed or expired,
+// with ErrCaptureExceedsHold if more than the hold is captured, and like transfer if the accounts can't take it
+func (store *SQLStore) CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (HoldTxResult, error) {
+	var result HoldTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		hold, err := q.GetHoldForUpdate(ctx, arg.HoldID)
+		if err != nil {
+			return err
+		}
+
+		// an expired hold is left to the sweeper even if it hasn't run yet
+		if hold.Status != util.HoldStatusActive || !time.Now().Before(hold.ExpiresAt) {
+			return ErrHoldNotActive
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return ErrCaptureExceedsHold
+		}
+
+		// lock the accounts in id order like addMoney, so the capture can't deadlock with a concurrent transfer
+		ids := []int64{hold.AccountID, hold.ToAccountID}
+		if hold.ToAccountID < hold.AccountID {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		for _, id := range ids {
+			if _, err := q.GetAccountForUpdate(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		// release the whole hold first, so the transfer can spend the captured part of it
+		_, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			ID:     hold.AccountID,
+			Amount: -hold.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		capture, err := transfer(ctx, q, TransferTxParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        amount,
+		})
+		if err != nil {
+			return err
+		}
+		result.Transfer = &capture
+		result.Account = capture.FromAccount
+
+		result.Hold, err = q.CloseHold(ctx, CloseHoldParams{
+			ID:             hold.ID,
+			Status:         util.HoldStatusCaptured,
+			CapturedAmount: amount,
+			TransferID:     sql.NullInt64{Int64: capture.Transfer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCaptureHold,
+			TargetType: AuditTargetHold,
+			TargetID:   fmt.Sprint(hold.ID),
+			Before:     hold,
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+type ReleaseHoldTxParams struct {
+	HoldID int64        `json:"hold_id"`
+	Audit  AuditRequest `json:"-"`
+}
+
+// ReleaseHoldTx gives the reserved money of an active hold back to the available balance within a single
+// database transaction. It fails with ErrHoldNotActive if the hold is already closed
+func (store *SQLStore) ReleaseHoldTx(ctx context.Context, arg ReleaseHoldTxParams) (HoldTxResult, error) {
+	var result HoldTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		hold, err := q.GetHoldForUpdate(ctx, arg.HoldID)
+		if err != nil {
+			return err
+		}
+
+		if hold.Status != util.HoldStatusActive {
+			return ErrHoldNotActive
+		}
+
+		result, err = releaseHold(ctx, q, hold, util.HoldStatusReleased)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionReleaseHold,
+			TargetType: AuditTargetHold,
+			TargetID:   fmt.Sprint(hold.ID),
+			Before:     hold,
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// ExpireHoldTx releases the active hold that expired first by now and marks it expired.
+// The hold stays locked until the transaction ends and is skipped by concurrent sweepers meanwhile.
+// It returns sql.ErrNoRows if no hold has expired
+func (store *SQLStore) ExpireHoldTx(ctx context.Context, now time.Time) (HoldTxResult, error) {
+	var result HoldTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		hold, err := q.GetExpiredHoldForUpdate(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		result, err = releaseHold(ctx, q, hold, util.HoldStatusExpired)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, AuditRequest{Actor: AuditActorHoldSweeper}, auditEvent{
+			Action:     AuditActionExpireHold,
+			TargetType: AuditTargetHold,
+			TargetID:   fmt.Sprint(hold.ID),
+			Before:     hold,
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// releaseHold closes a locked active hold with status and takes its amount off the held amount of its account
+func releaseHold(ctx context.Context, q *Queries, hold Hold, status string) (HoldTxResult, error) {
+	var result HoldTxResult
+	var err error
+
+	result.Account, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+		ID:     hold.AccountID,
+		Amount: -hold.Amount,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Hold, err = q.CloseHold(ctx, CloseHoldParams{
+		ID:     hold.ID,
+		Status: status,
+	})
+	return result, err
+}
+
+const (
+	// maxScheduledTransferAttempts failed attempts skip an occurrence of a schedule
+	maxScheduledTransferAttempts = 5
+	// scheduledTransferBackoff is the delay before the first retry, it doubles with every further attempt
+	scheduledTransferBackoff = time.Minute
+)
+
+type RunScheduledTransferTxResult struct {
+	Schedule ScheduledTransfer `json:"schedule"`
+	Transfer *TransferTxResult `json:"transfer,omitempty"`
+	// Failure is the reason the transfer failed, the schedule has been updated to retry or skip it
+	Failure error `json:"-"`
+}
+
+// RunScheduledTransferTx executes the schedule that has been due the longest and moves it to its next occurrence.
+// The schedule stays locked until the transaction ends and is skipped by concurrent workers meanwhile.
+// A failed transfer is retried with exponential backoff, after maxScheduledTransferAttempts the occurrence is skipped
+// and a one-off schedule fails. It returns sql.ErrNoRows if no schedule is due
+func (store *SQLStore) RunScheduledTransferTx(ctx context.Context, now time.Time) (RunScheduledTransferTxResult, error) {
+	var result RunScheduledTransferTxResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		schedule, err := q.GetDueScheduledTransferForUpdate(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		// a failed transfer aborts the postgres transaction, the savepoint keeps it usable to record the failure
+		if _, err := q.db.ExecContext(ctx, "SAVEPOINT scheduled_transfer"); err != nil {
+			return err
+		}
+
+		transferResult, runErr := runScheduledTransfer(ctx, q, schedule)
+		if runErr != nil {
+			if _, err := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT scheduled_transfer"); err != nil {
+				return err
+			}
+		}
+
+		arg := UpdateScheduledTransferRunParams{
+			ID:        schedule.ID,
+			Runs:      schedule.Runs,
+			Status:    schedule.Status,
+			LastRunAt: sql.NullTime{Time: now, Valid: true},
+		}
+
+		switch {
+		case runErr == nil:
+			result.Transfer = &transferResult
+			arg.LastTransferID = sql.NullInt64{Int64: transferResult.Transfer.ID, Valid: true}
+			arg.Runs++
+		case schedule.Attempts+1 < maxScheduledTransferAttempts:
+			result.Failure = runErr
+			arg.Attempts = schedule.Attempts + 1
+			arg.LastError = runErr.Error()
+			arg.NextRunAt = now.Add(scheduledTransferBackoff << schedule.Attempts)
+		default:
+			result.Failure = runErr
+			arg.LastError = runErr.Error()
+			arg.Runs++
+			if schedule.Frequency == util.FrequencyOnce {
+				arg.Status = util.ScheduleStatusFailed
+			}
+		}
+
+		if arg.NextRunAt.IsZero() {
+			next, ok := util.NextScheduledRun(schedule.Frequency, schedule.StartAt, arg.Runs)
+			if ok {
+				arg.NextRunAt = next
+			} else {
+				arg.NextRunAt = schedule.NextRunAt
+				if arg.Status == util.ScheduleStatusActive {
+					arg.Status = util.ScheduleStatusCompleted
+				}
+			}
+		}
+
+		result.Schedule, err = q.UpdateScheduledTransferRun(ctx, arg)
+		if err != nil || result.Transfer == nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, AuditRequest{Actor: AuditActorScheduler}, auditEvent{
+			Action:     AuditActionCreateTransfer,
+			TargetType: AuditTargetTransfer,
+			TargetID:   fmt.Sprint(result.Transfer.Transfer.ID),
+			After:      result,
+		})
+	})
+
+	return result, err
+}
+
+// runScheduledTransfer checks that both accounts can still take part in the transfer before executing it
+func runScheduledTransfer(ctx context.Context, q *Queries, schedule ScheduledTransfer) (TransferTxResult, error) {
+	fromAccount, err := q.GetAccount(ctx, schedule.FromAccountID)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	toAccount, err := q.GetAccount(ctx, schedule.ToAccountID)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	if fromAccount.Status != util.AccountStatusActive || toAccount.Status != util.AccountStatusActive {
+		return TransferTxResult{}, ErrAccountNotActive
+	}
+
+	if fromAccount.Currency != toAccount.Currency {
+		return TransferTxResult{}, fmt.Errorf("currency mismatch: %s vs %s", fromAccount.Currency, toAccount.Currency)
+	}
+
+	return transfer(ctx, q, TransferTxParams{
+		FromAccountID: schedule.FromAccountID,
+		ToAccountID:   schedule.ToAccountID,
+		Amount:        schedule.Amount,
+	})
+}
+
+func addMoney(
+	ctx context.Context,
+	q *Queries,
+	accountID1 int64,
+	amount1 int64,
+	accountID2 int64,
+	amount2 int64,
+) (account1 Account, account2 Account, err error) {
+	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     accountID1,
+		Amount: amount1,
+	})
+
+	if err != nil {
+		return
+	}
+
+	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     accountID2,
+		Amount: amount2,
+	})
+
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// addBalances adds the amounts to the balances of their accounts. The rows are locked in the order of their ids,
+// like addMoney does, so transactions that touch the same accounts can't deadlock
+func addBalances(ctx context.Context, q *Queries, amounts map[int64]int64) (map[int64]Account, error) {
+	ids := make([]int64, 0, len(amounts))
+	for id := range amounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	accounts := make(map[int64]Account, len(ids))
+	for _, id := range ids {
+		account, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     id,
+			Amount: amounts[id],
+		})
+		if err != nil {
+			return nil, err
+		}
+		accounts[id] = account
+	}
+	return accounts, nil
+}
+
+// CreateScheduledTransferTxParams creates a schedule and attributes it in the audit log
+type CreateScheduledTransferTxParams struct {
+	CreateScheduledTransferParams
+	Audit AuditRequest
+}
+
+// CreateScheduledTransferTx creates a schedule and records it in the audit log within a single database transaction
+func (store *SQLStore) CreateScheduledTransferTx(ctx context.Context, arg CreateScheduledTransferTxParams) (ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		schedule, err = q.CreateScheduledTransfer(ctx, arg.CreateScheduledTransferParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionCreateSchedule,
+			TargetType: AuditTargetSchedule,
+			TargetID:   fmt.Sprint(schedule.ID),
+			After:      schedule,
+		})
+	})
+
+	return schedule, err
+}
+
+// UpdateScheduledTransferTxParams changes the fields of a schedule that are set
+type UpdateScheduledTransferTxParams struct {
+	UpdateScheduledTransferParams
+	Audit AuditRequest
+}
+
+// UpdateScheduledTransferTx updates a schedule and records the change in the audit log within a single database transaction
+func (store *SQLStore) UpdateScheduledTransferTx(ctx context.Context, arg UpdateScheduledTransferTxParams) (ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	err := store.execTx(ctx, func(q *Queries) error {
+		before, err := q.GetScheduledTransferForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		schedule, err = q.UpdateScheduledTransfer(ctx, arg.UpdateScheduledTransferParams)
+		if err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionUpdateSchedule,
+			TargetType: AuditTargetSchedule,
+			TargetID:   fmt.Sprint(schedule.ID),
+			Before:     before,
+			After:      schedule,
+		})
+	})
+
+	return schedule, err
+}
+
+// DeleteScheduledTransferTxParams deletes the schedule ID
+type DeleteScheduledTransferTxParams struct {
+	ID    int64
+	Audit AuditRequest
+}
+
+// DeleteScheduledTransferTx deletes a schedule and records it in the audit log within a single database transaction.
+// It fails with sql.ErrNoRows if the schedule doesn't exist
+func (store *SQLStore) DeleteScheduledTransferTx(ctx context.Context, arg DeleteScheduledTransferTxParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		before, err := q.GetScheduledTransferForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteScheduledTransfer(ctx, arg.ID); err != nil {
+			return err
+		}
+
+		return appendAuditEvent(ctx, q, arg.Audit, auditEvent{
+			Action:     AuditActionDeleteSchedule,
+			TargetType: AuditTargetSchedule,
+			TargetID:   fmt.Sprint(arg.ID),
+			Before:     before,
+		})
+	})
 }
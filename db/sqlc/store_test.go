@@ -2,8 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,6 +22,7 @@ func TestTranferTx(t *testing.T) {
 	// run n concurrent transactions
 	n := 5
 	amount := int64(10)
+	account1 = fundTestAccount(t, account1, int64(n)*amount)
 	for i := 0; i < n; i++ {
 		go func() {
 			result, err := store.TransferTx(context.Background(), TransferTxParams{
@@ -57,6 +61,7 @@ func TestTranferTx(t *testing.T) {
 		require.NotEmpty(t, fromEntry)
 		require.Equal(t, account1.ID, fromEntry.AccountID)
 		require.Equal(t, -amount, fromEntry.Amount)
+		require.Equal(t, transfer.ID, fromEntry.TransferID.Int64)
 		require.NotZero(t, fromEntry.ID)
 		require.NotZero(t, fromEntry.CreatedAt)
 
@@ -68,6 +73,7 @@ func TestTranferTx(t *testing.T) {
 		require.NotEmpty(t, toEntry)
 		require.Equal(t, account2.ID, toEntry.AccountID)
 		require.Equal(t, amount, toEntry.Amount)
+		require.Equal(t, transfer.ID, toEntry.TransferID.Int64)
 		require.NotZero(t, toEntry.ID)
 		require.NotZero(t, toEntry.CreatedAt)
 
@@ -106,3 +112,357 @@ func TestTranferTx(t *testing.T) {
 	require.Equal(t, account2.Balance+int64(n)*amount, updatedAccount2.Balance)
 
 }
+
+func TestIdempotentTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, user, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	arg := IdempotentTransferTxParams{
+		TransferTxParams: TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		},
+		Username:       user.Username,
+		IdempotencyKey: util.RandomString(16),
+		RequestHash:    util.RandomString(64),
+	}
+
+	result1, err := store.IdempotentTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result1.Replayed)
+	require.NotZero(t, result1.Transfer.ID)
+
+	// a retry with the same key must not move money again
+	result2, err := store.IdempotentTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result2.Replayed)
+	require.Equal(t, result1.Transfer.ID, result2.Transfer.ID)
+	require.Equal(t, result1.FromAccount.Balance, result2.FromAccount.Balance)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-arg.Amount, updatedAccount1.Balance)
+
+	// the same key with a different request is rejected
+	arg.RequestHash = util.RandomString(64)
+	_, err = store.IdempotentTransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	err = testQueries.DeleteIdempotencyKey(context.Background(), DeleteIdempotencyKeyParams{
+		Username:       arg.Username,
+		IdempotencyKey: arg.IdempotencyKey,
+	})
+	require.NoError(t, err)
+}
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the failed transfer must leave both balances untouched
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
+
+func TestTransferTxInvalidAmount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+
+	// a negative transfer would pull money out of the destination account
+	for _, amount := range []int64{0, -10} {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		require.ErrorIs(t, err, ErrInvalidTransferAmount)
+	}
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
+
+// fundTestAccount tops up an account so that tests can transfer amount out of it
+func fundTestAccount(t *testing.T, account Account, amount int64) Account {
+	account, err := testQueries.AddAccountBalance(context.Background(), AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: amount,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := fundTestAccount(t, createTestAccountInCurrency(t, util.USD), 100)
+	account2 := createTestAccountInCurrency(t, util.EUR)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  930_000,
+		SpreadBps:     100,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(100), result.Transfer.Amount)
+	require.Equal(t, int64(92), result.Transfer.ToAmount)
+	require.Equal(t, int64(930_000), result.Transfer.ExchangeRate)
+	require.Equal(t, int32(100), result.Transfer.SpreadBps)
+
+	require.Equal(t, int64(-100), result.FromEntry.Amount)
+	require.Equal(t, int64(92), result.ToEntry.Amount)
+	require.Equal(t, account1.Balance-100, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+92, result.ToAccount.Balance)
+
+	// each currency balances through its settlement account
+	usdSettlement, err := testQueries.GetSettlementAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+	eurSettlement, err := testQueries.GetSettlementAccount(context.Background(), util.EUR)
+	require.NoError(t, err)
+
+	require.Len(t, result.SettlementEntries, 2)
+	require.Equal(t, usdSettlement.ID, result.SettlementEntries[0].AccountID)
+	require.Equal(t, int64(100), result.SettlementEntries[0].Amount)
+	require.Equal(t, eurSettlement.ID, result.SettlementEntries[1].AccountID)
+	require.Equal(t, int64(-92), result.SettlementEntries[1].Amount)
+	for _, entry := range result.SettlementEntries {
+		require.Equal(t, result.Transfer.ID, entry.TransferID.Int64)
+	}
+
+	// a same-currency transfer credits what it debits
+	account3 := createTestAccountInCurrency(t, util.USD)
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account3.ID,
+		Amount:        10,
+		ToAmount:      9,
+	})
+	require.ErrorIs(t, err, ErrInvalidTransferAmount)
+}
+
+func TestTransferTxSameAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	account = fundTestAccount(t, account, 100)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   account.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrSameAccountTransfer)
+
+	unchanged, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, unchanged.Balance)
+}
+
+func TestCloseAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	// a balance is left and nothing to sweep it to
+	_, err := store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account1.ID})
+	require.ErrorIs(t, err, ErrAccountNotEmpty)
+
+	result, err := store.CloseAccountTx(context.Background(), CloseAccountTxParams{
+		AccountID:        account1.ID,
+		SweepToAccountID: account2.ID,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, util.AccountStatusClosed, result.Account.Status)
+	require.True(t, result.Account.ClosedAt.Valid)
+	require.Zero(t, result.Account.Balance)
+
+	require.NotNil(t, result.Sweep)
+	require.Equal(t, account1.Balance, result.Sweep.Transfer.Amount)
+	require.Equal(t, account2.Balance+account1.Balance, result.Sweep.ToAccount.Balance)
+
+	// closing is final
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account1.ID})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+}
+
+func TestCloseAccountTxPendingFunding(t *testing.T) {
+	store := NewStore(testDB)
+
+	account, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	// empty, so it can be closed without a sweep
+	account = fundTestAccount(t, account, -account.Balance)
+
+	created, err := store.CreateFundingTx(context.Background(), CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      util.FundingKindDeposit,
+		Amount:    util.RandomAmount() + 1,
+	})
+	require.NoError(t, err)
+
+	// the deposit would be credited to the closed account once settled
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account.ID})
+	require.ErrorIs(t, err, ErrAccountHasPendingFundings)
+
+	_, err = store.CompleteFundingTx(context.Background(), CompleteFundingTxParams{
+		ID:            created.Funding.ID,
+		Status:        util.FundingStatusFailed,
+		FailureReason: "rejected",
+	})
+	require.NoError(t, err)
+
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account.ID})
+	require.NoError(t, err)
+
+	// and no new funding can start
+	_, err = store.CreateFundingTx(context.Background(), CreateFundingTxParams{
+		AccountID: account.ID,
+		Kind:      util.FundingKindDeposit,
+		Amount:    1,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+}
+
+func TestCloseAccountTxCancelsSchedules(t *testing.T) {
+	store := NewStore(testDB)
+
+	account, _, _, err := createRandomTestAccount(t)
+	require.NoError(t, err)
+	// empty, so it can be closed without a sweep
+	account = fundTestAccount(t, account, -account.Balance)
+	other := createTestAccountInCurrency(t, account.Currency)
+
+	createSchedule := func(from, to Account) ScheduledTransfer {
+		schedule, err := testQueries.CreateScheduledTransfer(context.Background(), CreateScheduledTransferParams{
+			Owner:         from.Owner,
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        1,
+			Frequency:     util.FrequencyDaily,
+			StartAt:       time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return schedule
+	}
+	outgoing := createSchedule(account, other)
+	incoming := createSchedule(other, account)
+
+	result, err := store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account.ID})
+	require.NoError(t, err)
+	require.Len(t, result.CancelledSchedules, 2)
+
+	for _, id := range []int64{outgoing.ID, incoming.ID} {
+		schedule, err := testQueries.GetScheduledTransfer(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, util.ScheduleStatusCancelled, schedule.Status)
+	}
+}
+
+func TestUpdateAccountStatusTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, _, _, err1 := createRandomTestAccount(t)
+	account2, _, _, err2 := createRandomTestAccount(t)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	account1 = fundTestAccount(t, account1, 10)
+
+	req := randomAuditRequest("banker")
+	frozen, err := store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID,
+		Status:    util.AccountStatusFrozen,
+		Reason:    "suspicious activity",
+		Audit:     req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.AccountStatusFrozen, frozen.Status)
+	require.Equal(t, "suspicious activity", frozen.StatusReason)
+	require.Equal(t, AuditActionFreezeAccount, getAuditEvent(t, req).Action)
+
+	// a frozen account can neither send nor receive money
+	var frozenErr *AccountFrozenError
+	_, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+	require.ErrorAs(t, err, &frozenErr)
+	require.Equal(t, account1.ID, frozenErr.AccountID)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account2.ID, ToAccountID: account1.ID, Amount: 1})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	_, err = store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID,
+		Status:    util.AccountStatusFrozen,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	req = randomAuditRequest("banker")
+	active, err := store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID,
+		Status:    util.AccountStatusActive,
+		Reason:    "cleared",
+		Audit:     req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.AccountStatusActive, active.Status)
+	require.Equal(t, account1.Balance, active.Balance)
+	require.Equal(t, AuditActionUnfreezeAccount, getAuditEvent(t, req).Action)
+
+	_, err = store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID,
+		Status:    util.AccountStatusActive,
+	})
+	require.ErrorIs(t, err, ErrAccountNotFrozen)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1})
+	require.NoError(t, err)
+
+	// only CloseAccountTx closes accounts
+	_, err = store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID,
+		Status:    util.AccountStatusClosed,
+	})
+	require.Error(t, err)
+
+	_, err = store.UpdateAccountStatusTx(context.Background(), UpdateAccountStatusTxParams{
+		AccountID: account1.ID + 1_000_000,
+		Status:    util.AccountStatusFrozen,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
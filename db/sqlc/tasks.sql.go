@@ -0,0 +1,244 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: tasks.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const claimTask = `-- name: ClaimTask :one
+UPDATE tasks SET
+  attempts = attempts + 1,
+  run_at = $1
+WHERE id = (
+  SELECT t.id FROM tasks t
+  WHERE t.status = 'pending' AND t.run_at <= $2
+  ORDER BY t.run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, completed_at
+`
+
+type ClaimTaskParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Now        time.Time `json:"now"`
+}
+
+func (q *Queries) ClaimTask(ctx context.Context, arg ClaimTaskParams) (Task, error) {
+	row := q.db.QueryRowContext(ctx, claimTask, arg.LeaseUntil, arg.Now)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const completeTask = `-- name: CompleteTask :execrows
+UPDATE tasks SET status = 'completed', last_error = '', completed_at = now()
+WHERE id = $1 AND attempts = $2 AND status = 'pending'
+`
+
+type CompleteTaskParams struct {
+	ID       int64 `json:"id"`
+	Attempts int32 `json:"attempts"`
+}
+
+func (q *Queries) CompleteTask(ctx context.Context, arg CompleteTaskParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, completeTask, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createTask = `-- name: CreateTask :one
+INSERT INTO tasks (
+  type, payload, max_attempts, run_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, completed_at
+`
+
+type CreateTaskParams struct {
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+}
+
+func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error) {
+	row := q.db.QueryRowContext(ctx, createTask,
+		arg.Type,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const deadLetterTask = `-- name: DeadLetterTask :execrows
+UPDATE tasks SET status = 'dead', last_error = $1, completed_at = now()
+WHERE id = $2 AND attempts = $3 AND status = 'pending'
+`
+
+type DeadLetterTaskParams struct {
+	LastError string `json:"last_error"`
+	ID        int64  `json:"id"`
+	Attempts  int32  `json:"attempts"`
+}
+
+func (q *Queries) DeadLetterTask(ctx context.Context, arg DeadLetterTaskParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deadLetterTask, arg.LastError, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getTask = `-- name: GetTask :one
+SELECT id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, completed_at FROM tasks
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTask(ctx context.Context, id int64) (Task, error) {
+	row := q.db.QueryRowContext(ctx, getTask, id)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const listDeadTasks = `-- name: ListDeadTasks :many
+SELECT id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, completed_at FROM tasks
+WHERE status = 'dead'
+ORDER BY id
+LIMIT $1
+OFFSET $2
+`
+
+type ListDeadTasksParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListDeadTasks(ctx context.Context, arg ListDeadTasksParams) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, listDeadTasks, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Task{}
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.Type,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.LastError,
+			&i.RunAt,
+			&i.CreatedAt,
+			&i.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueDeadTask = `-- name: RequeueDeadTask :one
+UPDATE tasks SET status = 'pending', attempts = 0, run_at = now(), completed_at = NULL
+WHERE id = $1 AND status = 'dead'
+RETURNING id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, completed_at
+`
+
+func (q *Queries) RequeueDeadTask(ctx context.Context, id int64) (Task, error) {
+	row := q.db.QueryRowContext(ctx, requeueDeadTask, id)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const retryTask = `-- name: RetryTask :execrows
+UPDATE tasks SET run_at = $1, last_error = $2
+WHERE id = $3 AND attempts = $4 AND status = 'pending'
+`
+
+type RetryTaskParams struct {
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error"`
+	ID        int64     `json:"id"`
+	Attempts  int32     `json:"attempts"`
+}
+
+func (q *Queries) RetryTask(ctx context.Context, arg RetryTaskParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retryTask,
+		arg.RunAt,
+		arg.LastError,
+		arg.ID,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTask(t *testing.T, runAt time.Time) Task {
+	arg := CreateTaskParams{
+		Type:        "task:test",
+		Payload:     []byte(`{"key":"value"}`),
+		MaxAttempts: 3,
+		RunAt:       runAt,
+	}
+
+	task, err := testQueries.CreateTask(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Type, task.Type)
+	require.JSONEq(t, string(arg.Payload), string(task.Payload))
+	require.Equal(t, "pending", task.Status)
+	require.Zero(t, task.Attempts)
+	return task
+}
+
+// claimTestTask claims due tasks until the given one has been picked up,
+// other tests may have left due tasks behind
+func claimTestTask(t *testing.T, id int64, now time.Time, lease time.Duration) Task {
+	for {
+		task, err := testQueries.ClaimTask(context.Background(), ClaimTaskParams{
+			Now:        now,
+			LeaseUntil: now.Add(lease),
+		})
+		require.NoError(t, err)
+		if task.ID == id {
+			return task
+		}
+	}
+}
+
+func TestClaimTask(t *testing.T) {
+	now := time.Now()
+	task := createRandomTask(t, now.Add(-time.Minute))
+
+	claimed := claimTestTask(t, task.ID, now, time.Minute)
+	require.Equal(t, int32(1), claimed.Attempts)
+	require.WithinDuration(t, now.Add(time.Minute), claimed.RunAt, time.Millisecond)
+
+	// the claim is lost once its lease runs out and the task is claimed again
+	reclaimed := claimTestTask(t, task.ID, now.Add(2*time.Minute), time.Minute)
+	require.Equal(t, int32(2), reclaimed.Attempts)
+
+	// the first processor can't record its outcome anymore
+	rows, err := testQueries.CompleteTask(context.Background(), CompleteTaskParams{ID: task.ID, Attempts: claimed.Attempts})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	rows, err = testQueries.RetryTask(context.Background(), RetryTaskParams{
+		ID:        task.ID,
+		Attempts:  reclaimed.Attempts,
+		RunAt:     now.Add(time.Hour),
+		LastError: "temporary failure",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	rows, err = testQueries.DeadLetterTask(context.Background(), DeadLetterTaskParams{
+		ID:        task.ID,
+		Attempts:  reclaimed.Attempts,
+		LastError: "permanent failure",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	dead, err := testQueries.GetTask(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "dead", dead.Status)
+	require.Equal(t, "permanent failure", dead.LastError)
+	require.True(t, dead.CompletedAt.Valid)
+
+	requeued, err := testQueries.RequeueDeadTask(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "pending", requeued.Status)
+	require.Zero(t, requeued.Attempts)
+}
@@ -0,0 +1,243 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: totp.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const attemptMFAChallenge = `-- name: AttemptMFAChallenge :one
+UPDATE mfa_challenges SET
+  attempts = attempts + 1
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+  AND attempts < $2::int
+RETURNING id, username, token_hash, attempts, user_agent, client_ip, used_at, created_at, expires_at
+`
+
+type AttemptMFAChallengeParams struct {
+	TokenHash   string `json:"token_hash"`
+	MaxAttempts int32  `json:"max_attempts"`
+}
+
+func (q *Queries) AttemptMFAChallenge(ctx context.Context, arg AttemptMFAChallengeParams) (MfaChallenge, error) {
+	row := q.db.QueryRowContext(ctx, attemptMFAChallenge, arg.TokenHash, arg.MaxAttempts)
+	var i MfaChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.TokenHash,
+		&i.Attempts,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const completeMFAChallenge = `-- name: CompleteMFAChallenge :execrows
+UPDATE mfa_challenges SET
+  used_at = now()
+WHERE id = $1
+  AND used_at IS NULL
+`
+
+func (q *Queries) CompleteMFAChallenge(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, completeMFAChallenge, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createMFAChallenge = `-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (
+  username, token_hash, user_agent, client_ip, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING id, username, token_hash, attempts, user_agent, client_ip, used_at, created_at, expires_at
+`
+
+type CreateMFAChallengeParams struct {
+	Username  string    `json:"username"`
+	TokenHash string    `json:"token_hash"`
+	UserAgent string    `json:"user_agent"`
+	ClientIp  string    `json:"client_ip"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateMFAChallenge(ctx context.Context, arg CreateMFAChallengeParams) (MfaChallenge, error) {
+	row := q.db.QueryRowContext(ctx, createMFAChallenge,
+		arg.Username,
+		arg.TokenHash,
+		arg.UserAgent,
+		arg.ClientIp,
+		arg.ExpiresAt,
+	)
+	var i MfaChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.TokenHash,
+		&i.Attempts,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username, code_hash
+) VALUES (
+  $1, $2
+)
+RETURNING id, username, code_hash, used_at, created_at
+`
+
+type CreateRecoveryCodeParams struct {
+	Username string `json:"username"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error) {
+	row := q.db.QueryRowContext(ctx, createRecoveryCode, arg.Username, arg.CodeHash)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.CodeHash,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, username)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :one
+UPDATE users SET
+  totp_enabled = true,
+  totp_last_used_step = $2
+WHERE username = $1
+  AND totp_enabled = false
+  AND totp_secret <> ''
+RETURNING username, password, full_name, email, password_changed_at, created_at, role, is_email_verified, totp_secret, totp_enabled, totp_last_used_step
+`
+
+type EnableTOTPParams struct {
+	Username         string `json:"username"`
+	TotpLastUsedStep int64  `json:"totp_last_used_step"`
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableTOTP, arg.Username, arg.TotpLastUsedStep)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Password,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsEmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastUsedStep,
+	)
+	return i, err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :one
+UPDATE users SET
+  totp_secret = $2
+WHERE username = $1
+  AND totp_enabled = false
+RETURNING username, password, full_name, email, password_changed_at, created_at, role, is_email_verified, totp_secret, totp_enabled, totp_last_used_step
+`
+
+type SetTOTPSecretParams struct {
+	Username   string `json:"username"`
+	TotpSecret string `json:"totp_secret"`
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setTOTPSecret, arg.Username, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Password,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsEmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastUsedStep,
+	)
+	return i, err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET
+  used_at = now()
+WHERE username = $1
+  AND code_hash = $2
+  AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	Username string `json:"username"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRecoveryCode, arg.Username, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users SET
+  totp_last_used_step = $2
+WHERE username = $1
+  AND totp_enabled = true
+  AND totp_last_used_step < $2
+`
+
+type UseTOTPStepParams struct {
+	Username         string `json:"username"`
+	TotpLastUsedStep int64  `json:"totp_last_used_step"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.Username, arg.TotpLastUsedStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
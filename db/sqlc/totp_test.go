@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnableTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	// nothing to enable before a secret is enrolled
+	_, err := store.EnableTOTPTx(context.Background(), EnableTOTPTxParams{Username: user.Username, Step: 1})
+	require.ErrorIs(t, err, ErrTOTPNotPending)
+
+	req := randomAuditRequest(user.Username)
+	user, err = store.EnrollTOTPTx(context.Background(), EnrollTOTPTxParams{
+		SetTOTPSecretParams: SetTOTPSecretParams{
+			Username:   user.Username,
+			TotpSecret: util.RandomString(32),
+		},
+		Audit: req,
+	})
+	require.NoError(t, err)
+	require.False(t, user.TotpEnabled)
+	require.Equal(t, AuditActionEnrollTOTP, getAuditEvent(t, req).Action)
+
+	codeHash := util.HashSecretToken(util.RandomString(10))
+	enabled, err := store.EnableTOTPTx(context.Background(), EnableTOTPTxParams{
+		Username:           user.Username,
+		Step:               10,
+		RecoveryCodeHashes: []string{codeHash, util.HashSecretToken(util.RandomString(10))},
+	})
+	require.NoError(t, err)
+	require.True(t, enabled.TotpEnabled)
+	require.Equal(t, int64(10), enabled.TotpLastUsedStep)
+
+	_, err = store.EnableTOTPTx(context.Background(), EnableTOTPTxParams{Username: user.Username, Step: 11})
+	require.ErrorIs(t, err, ErrTOTPNotPending)
+
+	// the secret can't be swapped while enabled
+	_, err = store.EnrollTOTPTx(context.Background(), EnrollTOTPTxParams{
+		SetTOTPSecretParams: SetTOTPSecretParams{
+			Username:   user.Username,
+			TotpSecret: util.RandomString(32),
+		},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a step is accepted once
+	rows, err := testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, TotpLastUsedStep: 10})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	rows, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, TotpLastUsedStep: 11})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// so is a recovery code, whose use is attributed to its user
+	req = randomAuditRequest("")
+	arg := UseRecoveryCodeTxParams{
+		UseRecoveryCodeParams: UseRecoveryCodeParams{Username: user.Username, CodeHash: codeHash},
+		Audit:                 req,
+	}
+	used, err := store.UseRecoveryCodeTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, used)
+
+	req.Actor = user.Username
+	require.Equal(t, AuditActionUseRecoveryCode, getAuditEvent(t, req).Action)
+
+	used, err = store.UseRecoveryCodeTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, used)
+}
+
+func TestAttemptMFAChallenge(t *testing.T) {
+	user := createRandomUser(t)
+	tokenHash := util.HashSecretToken(util.RandomString(32))
+
+	challenge, err := testQueries.CreateMFAChallenge(context.Background(), CreateMFAChallengeParams{
+		Username:  user.Username,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Zero(t, challenge.Attempts)
+
+	arg := AttemptMFAChallengeParams{TokenHash: tokenHash, MaxAttempts: 2}
+	for i := 1; i <= 2; i++ {
+		attempted, err := testQueries.AttemptMFAChallenge(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, int32(i), attempted.Attempts)
+	}
+
+	// out of attempts
+	_, err = testQueries.AttemptMFAChallenge(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	rows, err := testQueries.CompleteMFAChallenge(context.Background(), challenge.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	rows, err = testQueries.CompleteMFAChallenge(context.Background(), challenge.ID)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	// expired challenges are rejected
+	expiredHash := util.HashSecretToken(util.RandomString(32))
+	_, err = testQueries.CreateMFAChallenge(context.Background(), CreateMFAChallengeParams{
+		Username:  user.Username,
+		TokenHash: expiredHash,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.AttemptMFAChallenge(context.Background(), AttemptMFAChallengeParams{TokenHash: expiredHash, MaxAttempts: 5})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
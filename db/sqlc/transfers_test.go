@@ -2,18 +2,23 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
+	"github.com/muditshukla3/simplebank/fx"
 	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
 func createRandomTransfer(t *testing.T, account1, account2 Account) Transfer {
+	amount := util.RandomAmount()
 	arg := CreateTransferParams{
 		FromAccountID: account1.ID,
 		ToAccountID:   account2.ID,
-		Amount:        util.RandomAmount(),
+		Amount:        amount,
+		ToAmount:      amount,
+		ExchangeRate:  fx.RateScale,
 	}
 
 	transfer, err := testQueries.CreateTransfer(context.Background(), arg)
@@ -23,6 +28,8 @@ func createRandomTransfer(t *testing.T, account1, account2 Account) Transfer {
 	require.Equal(t, arg.FromAccountID, transfer.FromAccountID)
 	require.Equal(t, arg.ToAccountID, transfer.ToAccountID)
 	require.Equal(t, arg.Amount, transfer.Amount)
+	require.Equal(t, arg.ToAmount, transfer.ToAmount)
+	require.Equal(t, arg.ExchangeRate, transfer.ExchangeRate)
 
 	require.NotZero(t, transfer.ID)
 	require.NotZero(t, transfer.CreatedAt)
@@ -111,6 +118,54 @@ func TestListTransfer(t *testing.T) {
 	cleanUpUser(t, user2.Username)
 }
 
+func TestListAccountTransfers(t *testing.T) {
+	account1, _, user1, err1 := createRandomTestAccount(t)
+	account2, _, user2, err2 := createRandomTestAccount(t)
+
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+
+	outgoing := createRandomTransfer(t, account1, account2)
+	incoming := createRandomTransfer(t, account2, account1)
+
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+	require.Equal(t, incoming.ID, transfers[0].ID)
+	require.Equal(t, outgoing.ID, transfers[1].ID)
+
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		Direction: sql.NullString{String: "outgoing", Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		Direction: sql.NullString{String: "incoming", Valid: true},
+		PageSize:  5,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, incoming.ID, transfers[0].ID)
+
+	// cleanup transfer
+	cleanupTransfer(t, outgoing.ID)
+	cleanupTransfer(t, incoming.ID)
+	// cleanup account
+	cleanUpAccount(t, int(account1.ID))
+	cleanUpAccount(t, int(account2.ID))
+	// cleanup user
+	cleanUpUser(t, user1.Username)
+	cleanUpUser(t, user2.Username)
+}
+
 func cleanupTransfer(t *testing.T, id int64) {
 	err := testQueries.DeleteTransfer(context.Background(), id)
 	require.NoError(t, err)
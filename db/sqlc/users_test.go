@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
@@ -26,6 +29,7 @@ func createRandomUser(t *testing.T) User {
 	require.Equal(t, arg.Password, user.Password)
 	require.Equal(t, arg.FullName, user.FullName)
 	require.Equal(t, arg.Email, user.Email)
+	require.Equal(t, util.DepositorRole, user.Role)
 
 	require.NotZero(t, user.CreatedAt)
 
@@ -52,6 +56,124 @@ func TestGetUser(t *testing.T) {
 	cleanUpUser(t, user1.Username)
 }
 
+func TestCreateUserTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	arg := CreateUserParams{
+		Username: util.RandomOwner(),
+		Password: util.RandomString(6),
+		FullName: util.RandomOwner(),
+		Email:    util.RandomEmail(),
+	}
+
+	// a failing AfterCreate rolls the user back
+	_, err := store.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: arg,
+		AfterCreate: func(q Querier, user User) error {
+			return errors.New("cannot enqueue task")
+		},
+	})
+	require.Error(t, err)
+
+	_, err = testQueries.GetUser(context.Background(), arg.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	var task Task
+	user, err := store.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: arg,
+		AfterCreate: func(q Querier, user User) error {
+			task, err = q.CreateTask(context.Background(), CreateTaskParams{
+				Type:        "task:test",
+				Payload:     []byte(`{}`),
+				MaxAttempts: 1,
+				RunAt:       time.Now().Add(time.Hour),
+			})
+			return err
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, user.Username)
+
+	_, err = testQueries.GetTask(context.Background(), task.ID)
+	require.NoError(t, err)
+}
+
+func TestUpdateUserTx(t *testing.T) {
+	store := NewStore(testDB)
+	session := createRandomSession(t)
+
+	user, err := testQueries.GetUser(context.Background(), session.Username)
+	require.NoError(t, err)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	// only the set fields change
+	newName := util.RandomOwner()
+	updated, err := store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: user.Username,
+			FullName: sql.NullString{String: newName, Valid: true},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newName, updated.FullName)
+	require.Equal(t, user.Email, updated.Email)
+	require.Equal(t, user.Password, updated.Password)
+	require.Equal(t, user.PasswordChangedAt, updated.PasswordChangedAt)
+
+	session, err = testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.False(t, session.IsBlocked)
+
+	// a new password logs the user out everywhere
+	hashedPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+	updated, err = store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: user.Username,
+			Password: sql.NullString{String: hashedPassword, Valid: true},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, updated.Password)
+	require.WithinDuration(t, time.Now(), updated.PasswordChangedAt, time.Second)
+
+	session, err = testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+
+	// and revokes the api keys made from the old credentials
+	apiKey, err = testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.True(t, apiKey.RevokedAt.Valid)
+}
+
+func TestUpdateUserEmail(t *testing.T) {
+	user := createRandomUser(t)
+	user, err := testQueries.SetUserEmailVerified(context.Background(), SetUserEmailVerifiedParams{
+		Username: user.Username,
+		Email:    user.Email,
+	})
+	require.NoError(t, err)
+	require.True(t, user.IsEmailVerified)
+
+	// the same email stays verified
+	updated, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: user.Email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.True(t, updated.IsEmailVerified)
+
+	newEmail := util.RandomEmail()
+	updated, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: newEmail, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, updated.Email)
+	require.False(t, updated.IsEmailVerified)
+}
+
 func cleanUpUser(t *testing.T, username string) {
 	err := testQueries.DeleteUser(context.Background(), username)
 	require.NoError(t, err)
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomVerifyEmail(t *testing.T, user User, expiredAt time.Time) VerifyEmail {
+	arg := CreateVerifyEmailParams{
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: util.RandomString(32),
+		ExpiredAt:  expiredAt,
+	}
+
+	verifyEmail, err := testQueries.CreateVerifyEmail(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, verifyEmail.ID)
+	require.Equal(t, arg.Username, verifyEmail.Username)
+	require.Equal(t, arg.Email, verifyEmail.Email)
+	require.Equal(t, arg.SecretCode, verifyEmail.SecretCode)
+	require.False(t, verifyEmail.IsUsed)
+	require.WithinDuration(t, arg.ExpiredAt, verifyEmail.ExpiredAt, time.Second)
+
+	return verifyEmail
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	require.False(t, user.IsEmailVerified)
+
+	verifyEmail := createRandomVerifyEmail(t, user, time.Now().Add(time.Hour))
+
+	_, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    verifyEmail.ID,
+		SecretCode: util.RandomString(32),
+	})
+	require.ErrorIs(t, err, ErrInvalidVerifyEmail)
+
+	arg := VerifyEmailTxParams{
+		EmailID:    verifyEmail.ID,
+		SecretCode: verifyEmail.SecretCode,
+	}
+	result, err := store.VerifyEmailTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.User.IsEmailVerified)
+
+	// a code can only be used once
+	_, err = store.VerifyEmailTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidVerifyEmail)
+}
+
+func TestVerifyEmailTxExpired(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	verifyEmail := createRandomVerifyEmail(t, user, time.Now().Add(-time.Minute))
+
+	_, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    verifyEmail.ID,
+		SecretCode: verifyEmail.SecretCode,
+	})
+	require.ErrorIs(t, err, ErrInvalidVerifyEmail)
+
+	user, err = testQueries.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.False(t, user.IsEmailVerified)
+}
@@ -0,0 +1,79 @@
+package funding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/muditshukla3/simplebank/util"
+)
+
+// FakeProvider keeps fundings in memory for tests, which drive the outcome of each funding with Settle and Fail.
+// Every funding stays pending until then, and NewProvider never builds it, so it can't move money in a deployment
+type FakeProvider struct {
+	mu       sync.Mutex
+	requests map[string]Request
+	outcomes map[string]Outcome
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		requests: make(map[string]Request),
+		outcomes: make(map[string]Outcome),
+	}
+}
+
+func (provider *FakeProvider) Initiate(ctx context.Context, request Request) (string, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	reference := fmt.Sprintf("fake-%s-%d", request.Kind, request.FundingID)
+	if _, ok := provider.requests[reference]; ok {
+		return "", fmt.Errorf("funding %d was already initiated", request.FundingID)
+	}
+
+	provider.requests[reference] = request
+	provider.outcomes[reference] = Outcome{Status: util.FundingStatusPending}
+	return reference, nil
+}
+
+func (provider *FakeProvider) Outcome(ctx context.Context, reference string) (Outcome, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	outcome, ok := provider.outcomes[reference]
+	if !ok {
+		return outcome, ErrUnknownReference
+	}
+	return outcome, nil
+}
+
+// Request returns what was initiated under reference
+func (provider *FakeProvider) Request(reference string) (Request, bool) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	request, ok := provider.requests[reference]
+	return request, ok
+}
+
+// Settle makes the funding behind reference succeed
+func (provider *FakeProvider) Settle(reference string) error {
+	return provider.complete(reference, Outcome{Status: util.FundingStatusSettled})
+}
+
+// Fail makes the funding behind reference fail with reason
+func (provider *FakeProvider) Fail(reference string, reason string) error {
+	return provider.complete(reference, Outcome{Status: util.FundingStatusFailed, FailureReason: reason})
+}
+
+func (provider *FakeProvider) complete(reference string, outcome Outcome) error {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if _, ok := provider.outcomes[reference]; !ok {
+		return ErrUnknownReference
+	}
+	provider.outcomes[reference] = outcome
+	return nil
+}
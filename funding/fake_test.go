@@ -0,0 +1,54 @@
+package funding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProvider(t *testing.T) {
+	provider := NewFakeProvider()
+	request := Request{
+		FundingID: util.RandomInt(1, 1000),
+		Kind:      util.FundingKindDeposit,
+		AccountID: util.RandomInt(1, 1000),
+		Amount:    util.RandomAmount(),
+		Currency:  util.USD,
+	}
+
+	reference, err := provider.Initiate(context.Background(), request)
+	require.NoError(t, err)
+	require.NotEmpty(t, reference)
+
+	initiated, ok := provider.Request(reference)
+	require.True(t, ok)
+	require.Equal(t, request, initiated)
+
+	outcome, err := provider.Outcome(context.Background(), reference)
+	require.NoError(t, err)
+	require.Equal(t, util.FundingStatusPending, outcome.Status)
+
+	// the same funding can't be initiated twice
+	_, err = provider.Initiate(context.Background(), request)
+	require.Error(t, err)
+
+	err = provider.Fail(reference, "card declined")
+	require.NoError(t, err)
+
+	outcome, err = provider.Outcome(context.Background(), reference)
+	require.NoError(t, err)
+	require.Equal(t, Outcome{Status: util.FundingStatusFailed, FailureReason: "card declined"}, outcome)
+
+	err = provider.Settle(reference)
+	require.NoError(t, err)
+
+	outcome, err = provider.Outcome(context.Background(), reference)
+	require.NoError(t, err)
+	require.Equal(t, util.FundingStatusSettled, outcome.Status)
+
+	_, err = provider.Outcome(context.Background(), "missing")
+	require.ErrorIs(t, err, ErrUnknownReference)
+	require.ErrorIs(t, provider.Settle("missing"), ErrUnknownReference)
+}
@@ -0,0 +1,38 @@
+// Package funding moves money between the bank and external funding sources such as card networks or bank rails.
+package funding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownReference = errors.New("unknown funding reference")
+
+// Request asks a provider to pull a deposit from, or pay a withdrawal out to, the external source of an account
+type Request struct {
+	FundingID int64  `json:"funding_id"`
+	Kind      string `json:"kind"`
+	AccountID int64  `json:"account_id"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+// Outcome is the state of a funding at the provider, Status is one of the util.FundingStatus values
+type Outcome struct {
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// Provider is the FundingProvider the bank initiates deposits and withdrawals with.
+// Initiate returns the provider's reference of the funding, which Outcome is later polled with
+type Provider interface {
+	Initiate(ctx context.Context, request Request) (string, error)
+	Outcome(ctx context.Context, reference string) (Outcome, error)
+}
+
+// NewProvider creates the provider configured by name. No provider is built in yet, integrations with card networks
+// or bank rails are added here. The FakeProvider is for tests only and can't be configured
+func NewProvider(name string) (Provider, error) {
+	return nil, fmt.Errorf("unsupported funding provider %q", name)
+}
@@ -0,0 +1,13 @@
+package funding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProviderRejectsFake(t *testing.T) {
+	provider, err := NewProvider("fake")
+	require.Error(t, err)
+	require.Nil(t, provider)
+}
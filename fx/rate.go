@@ -0,0 +1,52 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// RateScale is the fixed point scale of exchange rates, so a rate of 1.5 is stored as 1500000
+const RateScale = 1_000_000
+
+// maxSpreadBps caps the spread to 100% expressed in basis points
+const maxSpreadBps = 10_000
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// Quote is the rate applied to convert an amount from one currency to another
+type Quote struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Rate      int64  `json:"rate"`
+	SpreadBps int32  `json:"spread_bps"`
+}
+
+// RateProvider quotes exchange rates between two currencies
+type RateProvider interface {
+	Quote(ctx context.Context, from, to string) (Quote, error)
+}
+
+// Convert applies the rate and then the spread to amount, rounding down in favour of the bank
+func (quote Quote) Convert(amount int64) int64 {
+	converted := new(big.Int).Mul(big.NewInt(amount), big.NewInt(quote.Rate))
+	converted.Mul(converted, big.NewInt(int64(maxSpreadBps-quote.SpreadBps)))
+	converted.Quo(converted, big.NewInt(RateScale*maxSpreadBps))
+	return converted.Int64()
+}
+
+// ParseRate parses a decimal rate such as "1.0725" into its fixed point representation
+func ParseRate(s string) (int64, error) {
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate %q", s)
+	}
+
+	scaled := new(big.Rat).Mul(rate, new(big.Rat).SetInt64(RateScale))
+	if !scaled.IsInt() {
+		return 0, fmt.Errorf("exchange rate %q has more than 6 decimal places", s)
+	}
+
+	return scaled.Num().Int64(), nil
+}
@@ -0,0 +1,81 @@
+package fx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("1.0725")
+	require.NoError(t, err)
+	require.Equal(t, int64(1_072_500), rate)
+
+	_, err = ParseRate("0.1234567")
+	require.Error(t, err)
+
+	_, err = ParseRate("-1")
+	require.Error(t, err)
+
+	_, err = ParseRate("abc")
+	require.Error(t, err)
+}
+
+func TestQuoteConvert(t *testing.T) {
+	quote := Quote{From: util.USD, To: util.EUR, Rate: 900_000}
+	require.Equal(t, int64(90), quote.Convert(100))
+
+	// a 1% spread is taken from the converted amount
+	quote.SpreadBps = 100
+	require.Equal(t, int64(8910), quote.Convert(10_000))
+
+	// fractions of the smallest unit are rounded down
+	require.Equal(t, int64(0), quote.Convert(1))
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	provider, err := NewStaticRateProvider(map[string]int64{"USD/EUR": 800_000}, 50)
+	require.NoError(t, err)
+
+	quote, err := provider.Quote(context.Background(), util.USD, util.EUR)
+	require.NoError(t, err)
+	require.Equal(t, int64(800_000), quote.Rate)
+	require.Equal(t, int32(50), quote.SpreadBps)
+
+	// the inverse pair is derived from the configured one
+	quote, err = provider.Quote(context.Background(), util.EUR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_250_000), quote.Rate)
+
+	quote, err = provider.Quote(context.Background(), util.CAD, util.CAD)
+	require.NoError(t, err)
+	require.Equal(t, int64(RateScale), quote.Rate)
+	require.Zero(t, quote.SpreadBps)
+
+	_, err = provider.Quote(context.Background(), util.USD, util.CAD)
+	require.ErrorIs(t, err, ErrRateNotFound)
+
+	_, err = NewStaticRateProvider(nil, maxSpreadBps)
+	require.Error(t, err)
+}
+
+func TestLoadStaticRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"spread_bps": 25, "rates": {"USD/CAD": "1.35"}}`), 0600)
+	require.NoError(t, err)
+
+	provider, err := LoadStaticRateProvider(path)
+	require.NoError(t, err)
+
+	quote, err := provider.Quote(context.Background(), util.USD, util.CAD)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_350_000), quote.Rate)
+	require.Equal(t, int32(25), quote.SpreadBps)
+
+	_, err = LoadStaticRateProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StaticRateProvider serves fixed rates, either configured in code or loaded from a file.
+// The rates never change, so it is meant for tests and local use
+type StaticRateProvider struct {
+	rates     map[string]int64
+	spreadBps int32
+}
+
+// NewStaticRateProvider creates a provider from rates keyed by "FROM/TO" pairs.
+// The inverse of a configured pair is derived when it is not configured itself.
+func NewStaticRateProvider(rates map[string]int64, spreadBps int32) (*StaticRateProvider, error) {
+	if spreadBps < 0 || spreadBps >= maxSpreadBps {
+		return nil, fmt.Errorf("invalid spread: must be between 0 and %d basis points", maxSpreadBps-1)
+	}
+
+	provider := &StaticRateProvider{
+		rates:     make(map[string]int64, len(rates)),
+		spreadBps: spreadBps,
+	}
+	for pair, rate := range rates {
+		if len(strings.Split(pair, "/")) != 2 {
+			return nil, fmt.Errorf("invalid currency pair %q", pair)
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s", pair)
+		}
+		provider.rates[pair] = rate
+	}
+
+	return provider, nil
+}
+
+type rateFile struct {
+	SpreadBps int32             `json:"spread_bps"`
+	Rates     map[string]string `json:"rates"`
+}
+
+// LoadStaticRateProvider reads a json file of the form
+// {"spread_bps": 50, "rates": {"USD/EUR": "0.93"}}
+func LoadStaticRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rates file: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse rates file: %w", err)
+	}
+
+	rates := make(map[string]int64, len(file.Rates))
+	for pair, value := range file.Rates {
+		rate, err := ParseRate(value)
+		if err != nil {
+			return nil, err
+		}
+		rates[pair] = rate
+	}
+
+	return NewStaticRateProvider(rates, file.SpreadBps)
+}
+
+func (provider *StaticRateProvider) Quote(ctx context.Context, from, to string) (Quote, error) {
+	if from == to {
+		return Quote{From: from, To: to, Rate: RateScale}, nil
+	}
+
+	quote := Quote{From: from, To: to, SpreadBps: provider.spreadBps}
+	if rate, ok := provider.rates[from+"/"+to]; ok {
+		quote.Rate = rate
+		return quote, nil
+	}
+
+	if rate, ok := provider.rates[to+"/"+from]; ok {
+		quote.Rate = RateScale * RateScale / rate
+		return quote, nil
+	}
+
+	return Quote{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
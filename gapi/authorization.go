@@ -0,0 +1,103 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/authz"
+	"github.com/muditshukla3/simplebank/token"
+	"google.golang.org/grpc"
+)
+
+const (
+	authorizationHeader = "authorization"
+	authorizationBearer = "bearer"
+)
+
+// publicMethods can be called without an access token, like the routes outside authMiddleware in the http api
+var publicMethods = map[string]bool{
+	"/pb.SimpleBank/CreateUser": true,
+	"/pb.SimpleBank/LoginUser":  true,
+}
+
+// methodScopes are the scopes the rpcs require, like the route groups of the http api.
+// RenewAccessToken keeps the scopes of the session, so it requires none
+var methodScopes = map[string]authz.Scope{
+	"/pb.SimpleBank/CreateAccount":  authz.ScopeAccountsWrite,
+	"/pb.SimpleBank/GetAccount":     authz.ScopeAccountsRead,
+	"/pb.SimpleBank/ListAccounts":   authz.ScopeAccountsRead,
+	"/pb.SimpleBank/CloseAccount":   authz.ScopeAccountsWrite,
+	"/pb.SimpleBank/CreateTransfer": authz.ScopeTransfersWrite,
+}
+
+type authorizationPayloadKey struct{}
+
+// AuthInterceptor is the gRPC equivalent of the http authMiddleware.
+// It verifies the bearer token in the request metadata and stores its payload in the context.
+func (server *Server) AuthInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	payload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	if scope, ok := methodScopes[info.FullMethod]; ok && !authz.TokenHasScope(payload, scope) {
+		return nil, permissionDeniedError("token lacks the %s scope", scope)
+	}
+
+	return handler(context.WithValue(ctx, authorizationPayloadKey{}, payload), req)
+}
+
+func (server *Server) authorizeUser(ctx context.Context) (*token.Payload, error) {
+	authHeader := firstMetadataValue(ctx, authorizationHeader)
+	if len(authHeader) == 0 {
+		return nil, fmt.Errorf("authorization header not provided")
+	}
+
+	fields := strings.Fields(authHeader)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid authorization header")
+	}
+
+	authType := strings.ToLower(fields[0])
+	if authType != authorizationBearer {
+		return nil, fmt.Errorf("unsupported authorization type %s", authType)
+	}
+
+	payload, err := server.tokenMaker.VerifyToken(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if !payload.HasAudience(server.config.TokenAudience) {
+		return nil, fmt.Errorf("token is not meant for %s", server.config.TokenAudience)
+	}
+
+	// like authMiddleware, access tokens stop working once their session is blocked
+	if payload.SessionID != uuid.Nil {
+		session, err := server.store.GetSession(ctx, payload.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find session: %w", err)
+		}
+		if session.IsBlocked {
+			return nil, fmt.Errorf("blocked session")
+		}
+	}
+
+	return payload, nil
+}
+
+// authPayload returns the payload stored by AuthInterceptor
+func authPayload(ctx context.Context) *token.Payload {
+	return ctx.Value(authorizationPayloadKey{}).(*token.Payload)
+}
@@ -0,0 +1,160 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		buildCtx   func(t *testing.T, tokenMaker token.Maker) context.Context
+		expectCode codes.Code
+	}{
+		{
+			name:   "OK",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, "user", util.DepositorRole, time.Minute)
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name:   "ScopedToken",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithClaims(t, tokenMaker, token.Claims{
+					Username: "user",
+					Role:     util.DepositorRole,
+					Scopes:   []string{"accounts:read"},
+					Audience: "simplebank",
+					Duration: time.Minute,
+				})
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name:   "MissingScope",
+			method: "/pb.SimpleBank/CreateTransfer",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithClaims(t, tokenMaker, token.Claims{
+					Username: "user",
+					Role:     util.DepositorRole,
+					Scopes:   []string{"accounts:read"},
+					Audience: "simplebank",
+					Duration: time.Minute,
+				})
+			},
+			expectCode: codes.PermissionDenied,
+		},
+		{
+			name:   "RefreshToken",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithClaims(t, tokenMaker, token.Claims{
+					Username: "user",
+					Role:     util.DepositorRole,
+					Audience: token.RefreshAudience("simplebank"),
+					Duration: time.Minute,
+				})
+			},
+			expectCode: codes.Unauthenticated,
+		},
+		{
+			name:   "PublicMethod",
+			method: "/pb.SimpleBank/LoginUser",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return context.Background()
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name:   "NoAuthorization",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return context.Background()
+			},
+			expectCode: codes.Unauthenticated,
+		},
+		{
+			name:   "UnsupportedAuthorization",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				md := metadata.Pairs(authorizationHeader, "digest abc")
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			expectCode: codes.Unauthenticated,
+		},
+		{
+			name:   "ExpiredToken",
+			method: "/pb.SimpleBank/GetAccount",
+			buildCtx: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, "user", util.DepositorRole, -time.Minute)
+			},
+			expectCode: codes.Unauthenticated,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if !publicMethods[tc.method] {
+					require.Equal(t, "user", authPayload(ctx).Username)
+				}
+				return "ok", nil
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: tc.method}
+			res, err := server.AuthInterceptor(tc.buildCtx(t, server.tokenMaker), nil, info, handler)
+			require.Equal(t, tc.expectCode, status.Code(err))
+			if tc.expectCode == codes.OK {
+				require.Equal(t, "ok", res)
+			}
+		})
+	}
+}
+
+func TestAuthInterceptorBlockedSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	session := db.Session{ID: uuid.New(), Username: "user", FamilyID: uuid.New(), IsBlocked: true}
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+
+	server := newTestServer(t, store)
+	accessToken, _, err := server.tokenMaker.CreateToken(token.Claims{Username: session.Username, Role: util.DepositorRole, SessionID: session.ID, Duration: time.Minute})
+	require.NoError(t, err)
+
+	md := metadata.Pairs(authorizationHeader, fmt.Sprintf("%s %s", authorizationBearer, accessToken))
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("handler must not run for a blocked session")
+		return nil, nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.SimpleBank/GetAccount"}
+	_, err = server.AuthInterceptor(ctx, nil, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
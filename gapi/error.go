@@ -0,0 +1,55 @@
+package gapi
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func fieldViolation(field string, err error) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: err.Error(),
+	}
+}
+
+// invalidArgumentError reports every invalid field of a request at once
+func invalidArgumentError(violations []*errdetails.BadRequest_FieldViolation) error {
+	badRequest := &errdetails.BadRequest{FieldViolations: violations}
+	statusInvalid := status.New(codes.InvalidArgument, "invalid parameters")
+
+	statusDetails, err := statusInvalid.WithDetails(badRequest)
+	if err != nil {
+		return statusInvalid.Err()
+	}
+
+	return statusDetails.Err()
+}
+
+func unauthenticatedError(err error) error {
+	return status.Errorf(codes.Unauthenticated, "unauthorized: %s", err)
+}
+
+// loginLockedError rejects a throttled login and tells the client when to retry
+func loginLockedError(retryAfter time.Duration) error {
+	statusLocked := status.New(codes.Unauthenticated, "too many failed logins, try again later")
+
+	statusDetails, err := statusLocked.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return statusLocked.Err()
+	}
+
+	return statusDetails.Err()
+}
+
+func internalError(format string, err error) error {
+	return status.Errorf(codes.Internal, "%s: %s", format, err)
+}
+
+func permissionDeniedError(format string, a ...interface{}) error {
+	return status.Error(codes.PermissionDenied, fmt.Sprintf(format, a...))
+}
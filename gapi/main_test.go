@@ -0,0 +1,80 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func newTestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:   util.RandomString(32),
+		TokenAudience:       "simplebank",
+		AccessTokenDuration: time.Minute,
+	}
+
+	server, err := NewServer(config, store, worker.NewPostgresTaskDistributor())
+	require.NoError(t, err)
+
+	return server
+}
+
+// newContextWithBearerToken builds an incoming context carrying an access token for username
+func newContextWithBearerToken(t *testing.T, tokenMaker token.Maker, username string, role string, duration time.Duration) context.Context {
+	return newContextWithClaims(t, tokenMaker, token.Claims{Username: username, Role: role, Duration: duration})
+}
+
+// newContextWithClaims builds an incoming context carrying an access token that makes claims
+func newContextWithClaims(t *testing.T, tokenMaker token.Maker, claims token.Claims) context.Context {
+	accessToken, _, err := tokenMaker.CreateToken(claims)
+	require.NoError(t, err)
+
+	md := metadata.MD{
+		authorizationHeader: []string{
+			fmt.Sprintf("%s %s", authorizationBearer, accessToken),
+		},
+	}
+
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+type eqAuditedMatcher struct {
+	arg   interface{}
+	actor string
+}
+
+// Matches compares the params without their Audit field, which has to name the actor and carry a request id
+func (e eqAuditedMatcher) Matches(x interface{}) bool {
+	if reflect.TypeOf(x) != reflect.TypeOf(e.arg) {
+		return false
+	}
+
+	arg := reflect.New(reflect.TypeOf(x)).Elem()
+	arg.Set(reflect.ValueOf(x))
+	field := arg.FieldByName("Audit")
+	req := field.Interface().(db.AuditRequest)
+	if req.Actor != e.actor || len(req.RequestID) == 0 {
+		return false
+	}
+
+	field.Set(reflect.Zero(field.Type()))
+	return reflect.DeepEqual(e.arg, arg.Interface())
+}
+
+func (e eqAuditedMatcher) String() string {
+	return fmt.Sprintf("matches arg %v audited as %v", e.arg, e.actor)
+}
+
+func eqAudited(arg interface{}, actor string) gomock.Matcher {
+	return eqAuditedMatcher{arg, actor}
+}
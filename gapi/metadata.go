@@ -0,0 +1,176 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/token"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	userAgentHeader      = "user-agent"
+	xForwardedForHeader  = "x-forwarded-for"
+	idempotencyKeyHeader = "idempotency-key"
+	totpCodeHeader       = "x-totp-code"
+	requestIDHeader      = "x-request-id"
+	// maxRequestIDLength bounds request ids chosen by clients, longer ones are replaced
+	maxRequestIDLength = 128
+)
+
+// Metadata holds the client information we keep on a session and in the audit log
+type Metadata struct {
+	UserAgent string
+	ClientIP  string
+	RequestID string
+}
+
+func (server *Server) extractMetadata(ctx context.Context) *Metadata {
+	mtdt := &Metadata{}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if userAgents := md.Get(userAgentHeader); len(userAgents) > 0 {
+			mtdt.UserAgent = userAgents[0]
+		}
+
+		if requestIDs := md.Get(requestIDHeader); len(requestIDs) > 0 && validRequestID(requestIDs[0]) {
+			mtdt.RequestID = requestIDs[0]
+		}
+	}
+
+	if len(mtdt.RequestID) == 0 {
+		mtdt.RequestID = uuid.NewString()
+	}
+
+	mtdt.ClientIP = server.clientIP(ctx)
+
+	return mtdt
+}
+
+// clientIP is the address of the peer. Behind a trusted proxy it is the last address in x-forwarded-for that isn't
+// a trusted proxy itself, the header of other peers is ignored so clients can't pick the ip the login throttling counts
+func (server *Server) clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	address := p.Addr.String()
+	if !server.trustedProxy(address) {
+		return address
+	}
+
+	var hops []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get(xForwardedForHeader) {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !server.trustedProxy(hop) {
+			return hop
+		}
+	}
+	return address
+}
+
+// trustedProxy reports whether address, with or without a port, is one of the configured proxies
+func (server *Server) trustedProxy(address string) bool {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range server.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses the TRUSTED_PROXIES setting, single ips are taken as networks of one address
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if len(proxy) == 0 {
+			continue
+		}
+
+		if strings.Contains(proxy, "/") {
+			_, network, err := net.ParseCIDR(proxy)
+			if err != nil {
+				return nil, err
+			}
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return networks, nil
+}
+
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// auditRequest attributes the changes of a call to the authenticated user, public methods set the actor themselves
+func (server *Server) auditRequest(ctx context.Context) db.AuditRequest {
+	mtdt := server.extractMetadata(ctx)
+	req := db.AuditRequest{
+		RequestID: mtdt.RequestID,
+		ClientIP:  mtdt.ClientIP,
+		UserAgent: mtdt.UserAgent,
+	}
+	if payload, ok := ctx.Value(authorizationPayloadKey{}).(*token.Payload); ok {
+		req.Actor = payload.Username
+	}
+	return req
+}
+
+// firstMetadataValue returns the first value of the given incoming metadata key, if any
+func firstMetadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,110 @@
+package gapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestAuditRequest(t *testing.T) {
+	server := newTestServer(t, nil)
+	username := util.RandomOwner()
+
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		check func(t *testing.T, requestID string)
+	}{
+		{
+			name: "Kept",
+			ctx:  metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "req-123")),
+			check: func(t *testing.T, requestID string) {
+				require.Equal(t, "req-123", requestID)
+			},
+		},
+		{
+			name: "Missing",
+			ctx:  context.Background(),
+			check: func(t *testing.T, requestID string) {
+				require.NotEmpty(t, requestID)
+			},
+		},
+		{
+			name: "Invalid",
+			ctx:  metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "has spaces")),
+			check: func(t *testing.T, requestID string) {
+				require.NotEmpty(t, requestID)
+				require.NotEqual(t, "has spaces", requestID)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(tc.ctx, authorizationPayloadKey{}, &token.Payload{Username: username})
+			req := server.auditRequest(ctx)
+			require.Equal(t, username, req.Actor)
+			tc.check(t, req.RequestID)
+
+			require.Empty(t, server.auditRequest(tc.ctx).Actor)
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	server := newTestServer(t, nil)
+	var err error
+	server.trustedProxies, err = parseTrustedProxies([]string{"10.0.0.1", "172.16.0.0/12"})
+	require.NoError(t, err)
+
+	// newContext makes a call from the peer at address forwarding the given x-forwarded-for header, if any
+	newContext := func(address string, forwardedFor string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(address), Port: 5555}})
+		if len(forwardedFor) > 0 {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(xForwardedForHeader, forwardedFor))
+		}
+		return ctx
+	}
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		clientIP string
+	}{
+		{name: "Peer", ctx: newContext("203.0.113.9", ""), clientIP: "203.0.113.9:5555"},
+		{name: "UntrustedPeerForwarding", ctx: newContext("203.0.113.9", "198.51.100.7"), clientIP: "203.0.113.9:5555"},
+		{name: "TrustedProxy", ctx: newContext("10.0.0.1", "198.51.100.7"), clientIP: "198.51.100.7"},
+		{name: "TrustedProxyChain", ctx: newContext("10.0.0.1", "192.0.2.1, 198.51.100.7, 172.16.5.5"), clientIP: "198.51.100.7"},
+		{name: "TrustedProxyInvalidHeader", ctx: newContext("10.0.0.1", "not-an-ip"), clientIP: "10.0.0.1:5555"},
+		{name: "NoPeer", ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(xForwardedForHeader, "198.51.100.7")), clientIP: ""},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.clientIP, server.extractMetadata(tc.ctx).ClientIP)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := parseTrustedProxies(nil)
+	require.NoError(t, err)
+	require.Empty(t, networks)
+
+	networks, err = parseTrustedProxies([]string{"10.0.0.1", "::1", "192.168.0.0/16"})
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+
+	_, err = parseTrustedProxies([]string{"proxy.local"})
+	require.Error(t, err)
+}
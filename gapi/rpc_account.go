@@ -0,0 +1,154 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	if err := validateCurrency(req.GetCurrency()); err != nil {
+		violations := []*errdetails.BadRequest_FieldViolation{fieldViolation("currency", err)}
+		return nil, invalidArgumentError(violations)
+	}
+
+	arg := db.CreateAccountTxParams{
+		CreateAccountParams: db.CreateAccountParams{
+			Owner:    authPayload(ctx).Username,
+			Currency: req.GetCurrency(),
+			Balance:  0,
+		},
+		Audit: server.auditRequest(ctx),
+	}
+
+	account, err := server.store.CreateAccountTx(ctx, arg)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation", "unique_violation":
+				return nil, permissionDeniedError("cannot create account: %s", err)
+			}
+		}
+		return nil, internalError("failed to create account", err)
+	}
+
+	return &pb.CreateAccountResponse{Account: convertAccount(account)}, nil
+}
+
+func (server *Server) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	if err := validateID(req.GetId()); err != nil {
+		violations := []*errdetails.BadRequest_FieldViolation{fieldViolation("id", err)}
+		return nil, invalidArgumentError(violations)
+	}
+
+	account, err := server.getAuthorizedAccount(ctx, req.GetId(), authz.ViewAnyAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetAccountResponse{Account: convertAccount(account)}, nil
+}
+
+func (server *Server) ListAccounts(ctx context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if err := validateRange(req.GetPageId(), 1, 1<<30); err != nil {
+		violations = append(violations, fieldViolation("page_id", err))
+	}
+	if err := validateRange(req.GetPageSize(), 5, 10); err != nil {
+		violations = append(violations, fieldViolation("page_size", err))
+	}
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	arg := db.ListAccountsParams{
+		Owner:         authPayload(ctx).Username,
+		IncludeClosed: req.GetIncludeClosed(),
+		Limit:         req.GetPageSize(),
+		Offset:        (req.GetPageId() - 1) * req.GetPageSize(),
+	}
+
+	accounts, err := server.store.ListAccounts(ctx, arg)
+	if err != nil {
+		return nil, internalError("failed to list accounts", err)
+	}
+
+	response := &pb.ListAccountsResponse{Accounts: make([]*pb.Account, len(accounts))}
+	for i, account := range accounts {
+		response.Accounts[i] = convertAccount(account)
+	}
+	return response, nil
+}
+
+func (server *Server) CloseAccount(ctx context.Context, req *pb.CloseAccountRequest) (*pb.CloseAccountResponse, error) {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if err := validateID(req.GetId()); err != nil {
+		violations = append(violations, fieldViolation("id", err))
+	}
+	if req.GetSweepToAccountId() != 0 {
+		if err := validateID(req.GetSweepToAccountId()); err != nil {
+			violations = append(violations, fieldViolation("sweep_to_account_id", err))
+		} else if req.GetSweepToAccountId() == req.GetId() {
+			violations = append(violations, fieldViolation("sweep_to_account_id", errors.New("cannot sweep an account to itself")))
+		}
+	}
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	account, err := server.getAuthorizedAccount(ctx, req.GetId(), authz.OwnerOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSweepToAccountId() != 0 {
+		if _, err := server.validAccount(ctx, req.GetSweepToAccountId(), account.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := server.store.CloseAccountTx(ctx, db.CloseAccountTxParams{
+		AccountID:        account.ID,
+		SweepToAccountID: req.GetSweepToAccountId(),
+		Audit:            server.auditRequest(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrAccountNotActive) || errors.Is(err, db.ErrAccountNotEmpty) || errors.Is(err, db.ErrAccountHasHolds) ||
+			errors.Is(err, db.ErrAccountHasPendingFundings) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, transferError(err)
+	}
+
+	response := &pb.CloseAccountResponse{Account: convertAccount(result.Account)}
+	if result.Sweep != nil {
+		response.Sweep = convertTransfer(result.Sweep.Transfer)
+	}
+	return response, nil
+}
+
+// getAuthorizedAccount loads an account and makes sure the authenticated user owns it
+// or has a role with permission
+func (server *Server) getAuthorizedAccount(ctx context.Context, id int64, permission authz.Permission) (db.Account, error) {
+	account, err := server.store.GetAccount(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return account, status.Errorf(codes.NotFound, "account not found")
+		}
+		return account, internalError("failed to get account", err)
+	}
+
+	if !authz.CanAccess(authPayload(ctx), account.Owner, permission) {
+		return account, permissionDeniedError("account doesn't belong to the authenticated user")
+	}
+
+	return account, nil
+}
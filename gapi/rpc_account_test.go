@@ -0,0 +1,204 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetAccountAPI(t *testing.T) {
+	account := db.Account{
+		ID:       util.RandomInt(1, 1000),
+		Owner:    util.RandomOwner(),
+		Balance:  util.RandomAmount(),
+		Currency: util.RandomCurrency(),
+		Status:   util.AccountStatusActive,
+	}
+
+	testCases := []struct {
+		name          string
+		req           *pb.GetAccountRequest
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, res interface{}, err error)
+	}{
+		{
+			name:     "OK",
+			req:      &pb.GetAccountRequest{Id: account.ID},
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, res interface{}, err error) {
+				require.NoError(t, err)
+				got := res.(*pb.GetAccountResponse).GetAccount()
+				require.Equal(t, account.ID, got.GetId())
+				require.Equal(t, account.Owner, got.GetOwner())
+				require.Equal(t, account.Balance, got.GetBalance())
+			},
+		},
+		{
+			name:     "NotFound",
+			req:      &pb.GetAccountRequest{Id: account.ID},
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, res interface{}, err error) {
+				require.Equal(t, codes.NotFound, status.Code(err))
+			},
+		},
+		{
+			name:     "InvalidID",
+			req:      &pb.GetAccountRequest{Id: 0},
+			username: account.Owner,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res interface{}, err error) {
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			req:      &pb.GetAccountRequest{Id: account.ID},
+			username: "unauth",
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, res interface{}, err error) {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			},
+		},
+		{
+			name:     "BankerViewsAnyAccount",
+			req:      &pb.GetAccountRequest{Id: account.ID},
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, res interface{}, err error) {
+				require.NoError(t, err)
+				require.Equal(t, account.ID, res.(*pb.GetAccountResponse).GetAccount().GetId())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			ctx := newContextWithBearerToken(t, server.tokenMaker, tc.username, tc.role, time.Minute)
+
+			// go through the interceptor so the payload ends up in the context like in production
+			info := &grpc.UnaryServerInfo{FullMethod: "/pb.SimpleBank/GetAccount"}
+			res, err := server.AuthInterceptor(ctx, tc.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.GetAccount(ctx, req.(*pb.GetAccountRequest))
+			})
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
+
+func TestCloseAccountAPI(t *testing.T) {
+	account := db.Account{ID: 1, Owner: util.RandomOwner(), Balance: 10, Currency: util.USD, Status: util.AccountStatusActive}
+	sweepTo := db.Account{ID: 2, Owner: account.Owner, Currency: util.USD, Status: util.AccountStatusActive}
+
+	testCases := []struct {
+		name       string
+		req        *pb.CloseAccountRequest
+		buildStubs func(store *mockdb.MockStore)
+		expectCode codes.Code
+	}{
+		{
+			name: "OK",
+			req:  &pb.CloseAccountRequest{Id: account.ID, SweepToAccountId: sweepTo.ID},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(sweepTo.ID)).Times(1).Return(sweepTo, nil)
+				store.EXPECT().
+					CloseAccountTx(gomock.Any(), eqAudited(db.CloseAccountTxParams{AccountID: account.ID, SweepToAccountID: sweepTo.ID}, account.Owner)).
+					Times(1).
+					Return(db.CloseAccountTxResult{Account: account, Sweep: &db.TransferTxResult{}}, nil)
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name: "NotEmpty",
+			req:  &pb.CloseAccountRequest{Id: account.ID},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					CloseAccountTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CloseAccountTxResult{}, db.ErrAccountNotEmpty)
+			},
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name: "SweepToItself",
+			req:  &pb.CloseAccountRequest{Id: account.ID, SweepToAccountId: account.ID},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			ctx := newContextWithBearerToken(t, server.tokenMaker, account.Owner, util.DepositorRole, time.Minute)
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/pb.SimpleBank/CloseAccount"}
+			_, err := server.AuthInterceptor(ctx, tc.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.CloseAccount(ctx, req.(*pb.CloseAccountRequest))
+			})
+			require.Equal(t, tc.expectCode, status.Code(err))
+		})
+	}
+}
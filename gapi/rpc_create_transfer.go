@@ -0,0 +1,285 @@
+package gapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/fx"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/totp"
+	"github.com/muditshukla3/simplebank/util"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const maxIdempotencyKeyLength = 255
+
+func (server *Server) CreateTransfer(ctx context.Context, req *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	if violations := validateCreateTransferRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	idempotencyKey := firstMetadataValue(ctx, idempotencyKeyHeader)
+	var requestHash string
+	if len(idempotencyKey) > 0 {
+		var err error
+		requestHash, err = hashTransferRequest(req, idempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// a retry is answered before the checks below, which would use up its totp code a second time
+		result, replayed, err := server.replayTransfer(ctx, idempotencyKey, requestHash)
+		if err != nil {
+			return nil, err
+		}
+		if replayed {
+			return convertTransferResult(result), nil
+		}
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.GetFromAccountId(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	if !authz.CanAccess(authPayload(ctx), fromAccount.Owner, authz.OwnerOnly) {
+		return nil, permissionDeniedError("from account doesn't belong to the authenticated user")
+	}
+
+	toCurrency := req.GetToCurrency()
+	if len(toCurrency) == 0 {
+		toCurrency = req.GetCurrency()
+	}
+
+	if _, err := server.validAccount(ctx, req.GetToAccountId(), toCurrency); err != nil {
+		return nil, err
+	}
+
+	if err := server.checkTransferAmount(ctx, fromAccount.Owner, req.GetAmount()); err != nil {
+		return nil, err
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+		Audit:         server.auditRequest(ctx),
+	}
+
+	if toCurrency != req.GetCurrency() {
+		if err := server.applyExchangeRate(ctx, &arg, req.GetCurrency(), toCurrency); err != nil {
+			return nil, err
+		}
+	}
+
+	var result db.TransferTxResult
+	if len(idempotencyKey) == 0 {
+		result, err = server.store.TransferTx(ctx, arg)
+	} else {
+		var idempotent db.IdempotentTransferTxResult
+		idempotent, err = server.store.IdempotentTransferTx(ctx, db.IdempotentTransferTxParams{
+			TransferTxParams: arg,
+			Username:         authPayload(ctx).Username,
+			IdempotencyKey:   idempotencyKey,
+			RequestHash:      requestHash,
+		})
+		result = idempotent.TransferTxResult
+	}
+	if err != nil {
+		return nil, transferError(err)
+	}
+
+	return convertTransferResult(result), nil
+}
+
+func convertTransferResult(result db.TransferTxResult) *pb.CreateTransferResponse {
+	return &pb.CreateTransferResponse{
+		Transfer:    convertTransfer(result.Transfer),
+		FromAccount: convertAccount(result.FromAccount),
+		ToAccount:   convertAccount(result.ToAccount),
+		FromEntry:   convertEntry(result.FromEntry),
+		ToEntry:     convertEntry(result.ToEntry),
+	}
+}
+
+// hashTransferRequest checks the idempotency key and hashes the request it is used with
+func hashTransferRequest(req *pb.CreateTransferRequest, idempotencyKey string) (string, error) {
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		violations := []*errdetails.BadRequest_FieldViolation{
+			fieldViolation(idempotencyKeyHeader, errors.New("is too long")),
+		}
+		return "", invalidArgumentError(violations)
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", internalError("failed to hash request", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayTransfer returns the stored result if the idempotency key has already been used for a transfer
+func (server *Server) replayTransfer(ctx context.Context, idempotencyKey, requestHash string) (db.TransferTxResult, bool, error) {
+	key, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username:       authPayload(ctx).Username,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.TransferTxResult{}, false, nil
+		}
+		return db.TransferTxResult{}, false, internalError("failed to get idempotency key", err)
+	}
+
+	result, err := db.ReplayIdempotentTransfer(key, requestHash)
+	if err != nil {
+		return result, false, transferError(err)
+	}
+	return result, true, nil
+}
+
+// applyExchangeRate fills in the credited amount and the quote of a cross-currency transfer
+func (server *Server) applyExchangeRate(ctx context.Context, arg *db.TransferTxParams, from, to string) error {
+	if server.rateProvider == nil {
+		return status.Errorf(codes.InvalidArgument, "cross-currency transfers are not enabled: %s vs %s", from, to)
+	}
+
+	quote, err := server.rateProvider.Quote(ctx, from, to)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return internalError("failed to quote exchange rate", err)
+	}
+
+	arg.ToAmount = quote.Convert(arg.Amount)
+	if arg.ToAmount <= 0 {
+		return status.Error(codes.FailedPrecondition, "amount is too small to be converted")
+	}
+	arg.ExchangeRate = quote.Rate
+	arg.SpreadBps = quote.SpreadBps
+	return nil
+}
+
+// validAccount loads an account and checks that it holds the transfer currency
+func (server *Server) validAccount(ctx context.Context, accountID int64, currency string) (db.Account, error) {
+	account, err := server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return account, status.Errorf(codes.NotFound, "account [%d] not found", accountID)
+		}
+		return account, internalError("failed to get account", err)
+	}
+
+	if account.Currency != currency {
+		return account, status.Errorf(codes.InvalidArgument, "account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
+	}
+
+	if account.Status != util.AccountStatusActive {
+		return account, status.Errorf(codes.FailedPrecondition, "account [%d] is %s", accountID, account.Status)
+	}
+
+	return account, nil
+}
+
+// checkTransferAmount applies the safeguards of large transfers: above the UnverifiedTransferLimit the email
+// of the user must be verified, above the TOTPTransferThreshold users with two-factor authentication
+// must send a fresh code in the x-totp-code metadata
+func (server *Server) checkTransferAmount(ctx context.Context, username string, amount int64) error {
+	emailLimit := server.config.UnverifiedTransferLimit
+	needsEmail := emailLimit > 0 && amount > emailLimit
+	totpThreshold := server.config.TOTPTransferThreshold
+	needsTOTP := totpThreshold > 0 && amount > totpThreshold
+	if !needsEmail && !needsTOTP {
+		return nil
+	}
+
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		return internalError("failed to get user", err)
+	}
+
+	if needsEmail && !user.IsEmailVerified {
+		return permissionDeniedError("email must be verified to transfer more than %d", emailLimit)
+	}
+
+	if needsTOTP && user.TotpEnabled {
+		valid, err := server.useTOTPCode(ctx, user, firstMetadataValue(ctx, totpCodeHeader))
+		if err != nil {
+			return internalError("failed to check totp code", err)
+		}
+		if !valid {
+			return permissionDeniedError("a fresh totp code is required to transfer more than %d", totpThreshold)
+		}
+	}
+	return nil
+}
+
+// useTOTPCode reports whether code is valid for user and wasn't used before, and marks it used
+func (server *Server) useTOTPCode(ctx context.Context, user db.User, code string) (bool, error) {
+	step, ok := totp.Validate(user.TotpSecret, code, time.Now())
+	if !ok || step <= user.TotpLastUsedStep {
+		return false, nil
+	}
+
+	rows, err := server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		Username:         user.Username,
+		TotpLastUsedStep: step,
+	})
+	return rows == 1, err
+}
+
+// transferError maps the errors returned by the transfer transactions to a gRPC status
+func transferError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, db.ErrInsufficientFunds), errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountNotActive):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, db.ErrIdempotencyKeyReused):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, db.ErrInvalidTransferAmount), errors.Is(err, db.ErrSameAccountTransfer):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return internalError("failed to transfer", err)
+}
+
+func validateCreateTransferRequest(req *pb.CreateTransferRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := validateID(req.GetFromAccountId()); err != nil {
+		violations = append(violations, fieldViolation("from_account_id", err))
+	}
+
+	if err := validateID(req.GetToAccountId()); err != nil {
+		violations = append(violations, fieldViolation("to_account_id", err))
+	} else if req.GetToAccountId() == req.GetFromAccountId() {
+		violations = append(violations, fieldViolation("to_account_id", errors.New("cannot transfer to the same account")))
+	}
+
+	if req.GetAmount() <= 0 {
+		violations = append(violations, fieldViolation("amount", errors.New("must be positive")))
+	}
+
+	if err := validateCurrency(req.GetCurrency()); err != nil {
+		violations = append(violations, fieldViolation("currency", err))
+	}
+
+	if len(req.GetToCurrency()) > 0 {
+		if err := validateCurrency(req.GetToCurrency()); err != nil {
+			violations = append(violations, fieldViolation("to_currency", err))
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,206 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCreateTransferAPI(t *testing.T) {
+	account1 := db.Account{ID: 1, Owner: util.RandomOwner(), Balance: 100, Currency: util.USD, Status: util.AccountStatusActive}
+	account2 := db.Account{ID: 2, Owner: util.RandomOwner(), Balance: 100, Currency: util.USD, Status: util.AccountStatusActive}
+
+	req := &pb.CreateTransferRequest{
+		FromAccountId: account1.ID,
+		ToAccountId:   account2.ID,
+		Amount:        10,
+		Currency:      util.USD,
+	}
+
+	// req stays within the limit, larger transfers need a verified email
+	unverifiedTransferLimit := req.Amount
+
+	testCases := []struct {
+		name           string
+		req            *pb.CreateTransferRequest
+		username       string
+		idempotencyKey string
+		buildStubs     func(store *mockdb.MockStore)
+		expectCode     codes.Code
+	}{
+		{
+			name:     "OK",
+			req:      req,
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), eqAudited(db.TransferTxParams{FromAccountID: 1, ToAccountID: 2, Amount: 10}, account1.Owner)).
+					Times(1)
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name:           "IdempotencyKeyReused",
+			req:            req,
+			username:       account1.Owner,
+			idempotencyKey: "key-1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotentTransferTxResult{}, db.ErrIdempotencyKeyReused)
+			},
+			expectCode: codes.AlreadyExists,
+		},
+		{
+			// a retry gets the stored transfer before anything is checked again
+			name:           "IdempotentReplay",
+			req:            req,
+			username:       account1.Owner,
+			idempotencyKey: "key-1",
+			buildStubs: func(store *mockdb.MockStore) {
+				requestHash, err := hashTransferRequest(req, "key-1")
+				require.NoError(t, err)
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Eq(db.GetIdempotencyKeyParams{Username: account1.Owner, IdempotencyKey: "key-1"})).
+					Times(1).
+					Return(db.IdempotencyKey{RequestHash: requestHash, Result: []byte(`{"transfer":{"id":1}}`)}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().IdempotentTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.OK,
+		},
+		{
+			name:     "InsufficientFunds",
+			req:      req,
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name: "UnverifiedEmailOverLimit",
+			req: &pb.CreateTransferRequest{
+				FromAccountId: account1.ID,
+				ToAccountId:   account2.ID,
+				Amount:        unverifiedTransferLimit + 1,
+				Currency:      util.USD,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(account1.Owner)).
+					Times(1).
+					Return(db.User{Username: account1.Owner}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.PermissionDenied,
+		},
+		{
+			name:     "UnauthorizedUser",
+			req:      req,
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.PermissionDenied,
+		},
+		{
+			name:     "FrozenToAccount",
+			req:      req,
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				frozen := account2
+				frozen.Status = util.AccountStatusFrozen
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(frozen, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name: "InvalidAmount",
+			req: &pb.CreateTransferRequest{
+				FromAccountId: account1.ID,
+				ToAccountId:   account2.ID,
+				Amount:        -1,
+				Currency:      util.USD,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.InvalidArgument,
+		},
+		{
+			name: "SameAccount",
+			req: &pb.CreateTransferRequest{
+				FromAccountId: account1.ID,
+				ToAccountId:   account1.ID,
+				Amount:        req.Amount,
+				Currency:      util.USD,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectCode: codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.UnverifiedTransferLimit = unverifiedTransferLimit
+			ctx := newContextWithBearerToken(t, server.tokenMaker, tc.username, util.DepositorRole, time.Minute)
+			if len(tc.idempotencyKey) > 0 {
+				md, _ := metadata.FromIncomingContext(ctx)
+				md.Set(idempotencyKeyHeader, tc.idempotencyKey)
+				ctx = metadata.NewIncomingContext(ctx, md)
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/pb.SimpleBank/CreateTransfer"}
+			_, err := server.AuthInterceptor(ctx, tc.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.CreateTransfer(ctx, req.(*pb.CreateTransferRequest))
+			})
+			require.Equal(t, tc.expectCode, status.Code(err))
+		})
+	}
+}
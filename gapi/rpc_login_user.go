@@ -0,0 +1,143 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// dummyPasswordHash stands in for the password of unknown usernames in LoginUser
+var dummyPasswordHash, _ = util.HashPassword("not the password of any user")
+
+func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (*pb.LoginUserResponse, error) {
+	if violations := validateLoginUserRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	now := time.Now()
+	mtdt := server.extractMetadata(ctx)
+	retryAfter, err := server.loginGuard.Check(ctx, req.GetUsername(), mtdt.ClientIP, now)
+	if err != nil {
+		return nil, internalError("failed to check login throttle", err)
+	}
+	if retryAfter > 0 {
+		return nil, loginLockedError(retryAfter)
+	}
+
+	// unknown usernames fail like wrong passwords, see loginUser of the http api
+	user, err := server.store.GetUser(ctx, req.GetUsername())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, internalError("failed to find user", err)
+	}
+	found := err == nil
+	hashedPassword := dummyPasswordHash
+	if found {
+		hashedPassword = user.Password
+	}
+
+	if err := util.CheckPassword(req.GetPassword(), hashedPassword); err != nil || !found {
+		if err := server.loginGuard.Fail(ctx, req.GetUsername(), mtdt.ClientIP, now); err != nil {
+			return nil, internalError("failed to record failed login", err)
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "incorrect username or password")
+	}
+
+	// the second login step only exists in the http api, so users with two-factor authentication log in there.
+	// Their failures are only forgotten once the second factor passed too
+	if user.TotpEnabled {
+		return nil, status.Errorf(codes.FailedPrecondition, "two-factor authentication is enabled, log in with POST /users/login")
+	}
+
+	if err := server.loginGuard.Succeed(ctx, user.Username); err != nil {
+		return nil, internalError("failed to reset login throttle", err)
+	}
+
+	scopes := req.GetScopes()
+	if len(scopes) == 0 {
+		scopes = authz.ScopeStrings(authz.LoginScopes)
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username: user.Username,
+		Role:     user.Role,
+		Scopes:   scopes,
+		Audience: token.RefreshAudience(server.config.TokenAudience),
+		Duration: server.config.RefreshTokenDuration,
+	})
+	if err != nil {
+		return nil, internalError("failed to create refresh token", err)
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: refreshPayload.ID,
+		Scopes:    scopes,
+		Audience:  server.config.TokenAudience,
+		Duration:  server.config.AccessTokenDuration,
+	})
+	if err != nil {
+		return nil, internalError("failed to create access token", err)
+	}
+
+	// logging in is public, the user logging in is the actor
+	sessionAudit := server.auditRequest(ctx)
+	sessionAudit.Actor = user.Username
+
+	session, err := server.store.CreateSessionTx(ctx, db.CreateSessionTxParams{
+		CreateSessionParams: db.CreateSessionParams{
+			ID:           refreshPayload.ID,
+			Username:     user.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    mtdt.UserAgent,
+			ClientIp:     mtdt.ClientIP,
+			IsBlocked:    false,
+			ExpiresAt:    refreshPayload.ExpiredAt,
+			FamilyID:     refreshPayload.ID,
+		},
+		Audit: sessionAudit,
+	})
+	if err != nil {
+		return nil, internalError("failed to create session", err)
+	}
+
+	response := &pb.LoginUserResponse{
+		User:                  convertUser(user),
+		SessionId:             session.ID.String(),
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessPayload.ExpiredAt),
+		RefreshTokenExpiresAt: timestamppb.New(refreshPayload.ExpiredAt),
+		Scopes:                accessPayload.Scopes,
+	}
+	return response, nil
+}
+
+func validateLoginUserRequest(req *pb.LoginUserRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := validateRequired(req.GetUsername()); err != nil {
+		violations = append(violations, fieldViolation("username", err))
+	}
+
+	if err := validateRequired(req.GetPassword()); err != nil {
+		violations = append(violations, fieldViolation("password", err))
+	}
+
+	for _, scope := range req.GetScopes() {
+		if !authz.IsScope(authz.Scope(scope)) {
+			violations = append(violations, fieldViolation("scopes", fmt.Errorf("unknown scope %q", scope)))
+		}
+	}
+
+	return violations
+}
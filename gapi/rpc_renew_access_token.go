@@ -0,0 +1,145 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/muditshukla3/simplebank/authz"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/token"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (server *Server) RenewAccessToken(ctx context.Context, req *pb.RenewAccessTokenRequest) (*pb.RenewAccessTokenResponse, error) {
+	if err := validateRequired(req.GetRefreshToken()); err != nil {
+		violations := []*errdetails.BadRequest_FieldViolation{fieldViolation("refresh_token", err)}
+		return nil, invalidArgumentError(violations)
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.GetRefreshToken())
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	if !refreshPayload.HasAudience(token.RefreshAudience(server.config.TokenAudience)) {
+		return nil, unauthenticatedError(fmt.Errorf("token is not a refresh token"))
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "session not found")
+		}
+		return nil, internalError("failed to find session", err)
+	}
+
+	if session.IsBlocked {
+		return nil, unauthenticatedError(fmt.Errorf("blocked session"))
+	}
+
+	if session.Username != refreshPayload.Username {
+		return nil, unauthenticatedError(fmt.Errorf("incorrect session user"))
+	}
+
+	if session.RefreshToken != req.GetRefreshToken() {
+		return nil, unauthenticatedError(fmt.Errorf("mismatch session token"))
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, unauthenticatedError(fmt.Errorf("expired session token"))
+	}
+
+	if session.UsedAt.Valid {
+		return nil, server.rejectRefreshTokenReuse(ctx, session)
+	}
+
+	// the role is read again so that a role change applies from the next renewal
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		return nil, internalError("failed to find user", err)
+	}
+
+	// like renewAccessToken of the http api, the session keeps its scopes
+	scopes := refreshPayload.Scopes
+	if scopes == nil {
+		scopes = authz.ScopeStrings(authz.LoginScopes)
+	}
+
+	refreshToken, nextRefreshPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username: refreshPayload.Username,
+		Role:     user.Role,
+		Scopes:   scopes,
+		Audience: token.RefreshAudience(server.config.TokenAudience),
+		Duration: server.config.RefreshTokenDuration,
+	})
+	if err != nil {
+		return nil, internalError("failed to create refresh token", err)
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(token.Claims{
+		Username:  refreshPayload.Username,
+		Role:      user.Role,
+		SessionID: nextRefreshPayload.ID,
+		Scopes:    scopes,
+		Audience:  server.config.TokenAudience,
+		Duration:  server.config.AccessTokenDuration,
+	})
+	if err != nil {
+		return nil, internalError("failed to create access token", err)
+	}
+
+	mtdt := server.extractMetadata(ctx)
+	// the refresh token is the credential here, its user is the actor
+	rotateAudit := server.auditRequest(ctx)
+	rotateAudit.Actor = session.Username
+
+	_, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		UsedSessionID: session.ID,
+		NextSession: db.CreateSessionParams{
+			ID:           nextRefreshPayload.ID,
+			Username:     session.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    mtdt.UserAgent,
+			ClientIp:     mtdt.ClientIP,
+			IsBlocked:    false,
+			ExpiresAt:    nextRefreshPayload.ExpiredAt,
+			FamilyID:     session.FamilyID,
+		},
+		Audit: rotateAudit,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			return nil, server.rejectRefreshTokenReuse(ctx, session)
+		}
+		return nil, internalError("failed to rotate session", err)
+	}
+
+	response := &pb.RenewAccessTokenResponse{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessPayload.ExpiredAt),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: timestamppb.New(nextRefreshPayload.ExpiredAt),
+		Scopes:                accessPayload.Scopes,
+	}
+	return response, nil
+}
+
+// rejectRefreshTokenReuse blocks every session rotated from the same login, see the http api
+func (server *Server) rejectRefreshTokenReuse(ctx context.Context, session db.Session) error {
+	_, err := server.store.BlockSessionFamilyTx(ctx, db.BlockSessionFamilyTxParams{
+		FamilyID: session.FamilyID,
+		Audit:    server.auditRequest(ctx),
+	})
+	if err != nil {
+		return internalError("failed to block session", err)
+	}
+
+	return unauthenticatedError(db.ErrRefreshTokenReused)
+}
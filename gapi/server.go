@@ -0,0 +1,63 @@
+package gapi
+
+import (
+	"fmt"
+	"net"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/fx"
+	"github.com/muditshukla3/simplebank/lockout"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/token"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
+)
+
+// Server serves gRPC requests for our banking service
+type Server struct {
+	pb.UnimplementedSimpleBankServer
+	config          util.Config
+	store           db.Store
+	tokenMaker      token.Maker
+	rateProvider    fx.RateProvider
+	taskDistributor worker.TaskDistributor
+	loginGuard      *lockout.Guard
+	// trustedProxies may name the client ip in x-forwarded-for
+	trustedProxies []*net.IPNet
+}
+
+// NewServer creates a new gRPC server
+func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) (*Server, error) {
+	tokenMaker, err := token.NewMaker(config.TokenType, token.KeySource{
+		KeyFile:              config.TokenKeyFile,
+		SymmetricKey:         config.TokenSymmetricKey,
+		RetiredSymmetricKeys: config.TokenRetiredSymmetricKeys,
+		PrivateKeyFile:       config.TokenPrivateKeyFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+
+	server := &Server{
+		config:          config,
+		store:           store,
+		tokenMaker:      tokenMaker,
+		taskDistributor: taskDistributor,
+		loginGuard:      lockout.NewGuard(store, lockout.PolicyFromConfig(config)),
+	}
+
+	// cross-currency transfers stay disabled unless a rates file is configured
+	if len(config.FXRatesFile) > 0 {
+		server.rateProvider, err = fx.LoadStaticRateProvider(config.FXRatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create rate provider: %w", err)
+		}
+	}
+
+	server.trustedProxies, err = parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse trusted proxies: %w", err)
+	}
+
+	return server, nil
+}
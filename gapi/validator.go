@@ -0,0 +1,43 @@
+package gapi
+
+import (
+	"fmt"
+	"net/mail"
+
+	"github.com/muditshukla3/simplebank/util"
+)
+
+func validateRequired(value string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func validateEmail(value string) error {
+	if _, err := mail.ParseAddress(value); err != nil {
+		return fmt.Errorf("is not a valid email address")
+	}
+	return nil
+}
+
+func validateCurrency(value string) error {
+	if !util.IsCurrencySupported(value) {
+		return fmt.Errorf("unsupported currency %q", value)
+	}
+	return nil
+}
+
+func validateID(value int64) error {
+	if value < 1 {
+		return fmt.Errorf("must be a positive integer")
+	}
+	return nil
+}
+
+func validateRange(value int32, min, max int32) error {
+	if value < min || value > max {
+		return fmt.Errorf("must be between %d and %d", min, max)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Package ledger checks that the double-entry bookkeeping of the bank still adds up.
+package ledger
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+)
+
+// CurrencyDiscrepancy reports a currency whose entries don't sum to zero. Every transfer and funding is
+// balanced within each currency, cross-currency transfers through the settlement accounts of both currencies.
+type CurrencyDiscrepancy struct {
+	Currency     string `json:"currency"`
+	EntriesTotal int64  `json:"entries_total"`
+}
+
+// Report lists every discrepancy found, OK is true when there are none
+type Report struct {
+	CheckedAt  time.Time                         `json:"checked_at"`
+	OK         bool                              `json:"ok"`
+	Balances   []db.ListBalanceDiscrepanciesRow  `json:"balances"`
+	Transfers  []db.ListTransferDiscrepanciesRow `json:"transfers"`
+	Currencies []CurrencyDiscrepancy             `json:"currencies"`
+}
+
+// Verify recomputes each account balance from its entries, checks that each transfer has exactly
+// one debit and one credit entry besides the settlement entries of a cross-currency transfer,
+// and checks that the entries of each currency sum to zero.
+// All checks read the same snapshot so that concurrent transfers don't show up as discrepancies.
+func Verify(ctx context.Context, store db.Store) (Report, error) {
+	report := Report{CheckedAt: time.Now()}
+
+	err := store.ExecSnapshotTx(ctx, func(q db.Querier) error {
+		var err error
+		report.Balances, err = q.ListBalanceDiscrepancies(ctx)
+		if err != nil {
+			return err
+		}
+
+		report.Transfers, err = q.ListTransferDiscrepancies(ctx)
+		if err != nil {
+			return err
+		}
+
+		report.Currencies, err = currencyDiscrepancies(ctx, q)
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.OK = len(report.Balances) == 0 && len(report.Transfers) == 0 && len(report.Currencies) == 0
+	return report, nil
+}
+
+func currencyDiscrepancies(ctx context.Context, q db.Querier) ([]CurrencyDiscrepancy, error) {
+	totals, err := q.SumEntriesByCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := []CurrencyDiscrepancy{}
+	for _, total := range totals {
+		if total.Total != 0 {
+			discrepancies = append(discrepancies, CurrencyDiscrepancy{
+				Currency:     total.Currency,
+				EntriesTotal: total.Total,
+			})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].Currency < discrepancies[j].Currency
+	})
+	return discrepancies, nil
+}
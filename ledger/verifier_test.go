@@ -0,0 +1,127 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// runSnapshot makes the mocked ExecSnapshotTx run fn against the mock itself
+func runSnapshot(store *mockdb.MockStore) {
+	store.EXPECT().
+		ExecSnapshotTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, fn func(db.Querier) error) error {
+			return fn(store)
+		})
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, report Report, err error)
+	}{
+		{
+			name: "Consistent",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().ListBalanceDiscrepancies(gomock.Any()).Times(1).Return([]db.ListBalanceDiscrepanciesRow{}, nil)
+				store.EXPECT().ListTransferDiscrepancies(gomock.Any()).Times(1).Return([]db.ListTransferDiscrepanciesRow{}, nil)
+				store.EXPECT().SumEntriesByCurrency(gomock.Any()).Times(1).Return([]db.SumEntriesByCurrencyRow{
+					{Currency: util.CAD, Total: 0},
+					{Currency: util.EUR, Total: 0},
+					{Currency: util.USD, Total: 0},
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.True(t, report.OK)
+				require.Empty(t, report.Balances)
+				require.Empty(t, report.Transfers)
+				require.Empty(t, report.Currencies)
+				require.False(t, report.CheckedAt.IsZero())
+			},
+		},
+		{
+			name: "Discrepancies",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().ListBalanceDiscrepancies(gomock.Any()).Times(1).Return([]db.ListBalanceDiscrepanciesRow{
+					{AccountID: 1, Currency: util.USD, Balance: 100, EntriesBalance: 90},
+				}, nil)
+				store.EXPECT().ListTransferDiscrepancies(gomock.Any()).Times(1).Return([]db.ListTransferDiscrepanciesRow{
+					{TransferID: 7, DebitEntries: 1, CreditEntries: 0, LinkedEntries: 1},
+				}, nil)
+				store.EXPECT().SumEntriesByCurrency(gomock.Any()).Times(1).Return([]db.SumEntriesByCurrencyRow{
+					{Currency: util.EUR, Total: 5},
+					{Currency: util.USD, Total: -10},
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.False(t, report.OK)
+				require.Len(t, report.Balances, 1)
+				require.Len(t, report.Transfers, 1)
+				require.Equal(t, []CurrencyDiscrepancy{
+					{Currency: util.EUR, EntriesTotal: 5},
+					{Currency: util.USD, EntriesTotal: -10},
+				}, report.Currencies)
+			},
+		},
+		{
+			name: "UnsettledConversion",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().ListBalanceDiscrepancies(gomock.Any()).Times(1).Return([]db.ListBalanceDiscrepanciesRow{}, nil)
+				store.EXPECT().ListTransferDiscrepancies(gomock.Any()).Times(1).Return([]db.ListTransferDiscrepanciesRow{}, nil)
+				// a cross-currency transfer without settlement entries leaves both currencies unbalanced
+				store.EXPECT().SumEntriesByCurrency(gomock.Any()).Times(1).Return([]db.SumEntriesByCurrencyRow{
+					{Currency: util.EUR, Total: 90},
+					{Currency: util.USD, Total: -100},
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.NoError(t, err)
+				require.False(t, report.OK)
+				require.Equal(t, []CurrencyDiscrepancy{
+					{Currency: util.EUR, EntriesTotal: 90},
+					{Currency: util.USD, EntriesTotal: -100},
+				}, report.Currencies)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				runSnapshot(store)
+				store.EXPECT().ListBalanceDiscrepancies(gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+				store.EXPECT().ListTransferDiscrepancies(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, report Report, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.False(t, report.OK)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			report, err := Verify(context.Background(), store)
+			tc.checkResponse(t, report, err)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Package lockout throttles password logins. Failed logins are counted per username and per client ip in the
+// database, so the limits hold across server processes and restarts.
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const (
+	KindUsername = "username"
+	KindIP       = "ip"
+)
+
+// Policy decides how long logins are held back after failures
+type Policy struct {
+	// MaxFailures is the number of failed logins of a username that locks it, zero disables the username limit
+	MaxFailures int
+	// MaxIPFailures is the number of failed logins from a client ip that locks it, zero disables the ip limit
+	MaxIPFailures int
+	// Window is how long failures are remembered, a failure after a quiet window is counted as the first again
+	Window time.Duration
+	// BaseDelay is the wait after the first failure of a username, it doubles with every further failure
+	BaseDelay time.Duration
+	// LockoutDuration is how long a username or client ip is locked once it reached its maximum
+	LockoutDuration time.Duration
+}
+
+// PolicyFromConfig returns the policy set in the LOGIN_* variables of config
+func PolicyFromConfig(config util.Config) Policy {
+	return Policy{
+		MaxFailures:     config.LoginMaxFailures,
+		MaxIPFailures:   config.LoginMaxFailuresPerIP,
+		Window:          config.LoginFailureWindow,
+		BaseDelay:       config.LoginBaseDelay,
+		LockoutDuration: config.LoginLockoutDuration,
+	}
+}
+
+// Delay returns how long the next login of a subject of kind has to wait after its failures.
+// Usernames wait progressively longer before they are locked, client ips are only locked since many users can
+// share one address
+func (policy Policy) Delay(kind string, failures int32) time.Duration {
+	max := policy.MaxFailures
+	if kind == KindIP {
+		max = policy.MaxIPFailures
+	}
+
+	if max <= 0 || failures <= 0 {
+		return 0
+	}
+	if int(failures) >= max {
+		return policy.LockoutDuration
+	}
+	if kind == KindIP {
+		return 0
+	}
+
+	delay := policy.BaseDelay
+	for i := int32(1); i < failures && delay < policy.LockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > policy.LockoutDuration {
+		delay = policy.LockoutDuration
+	}
+	return delay
+}
+
+// Guard checks and records the logins of a server against the policy
+type Guard struct {
+	store  db.Querier
+	policy Policy
+}
+
+func NewGuard(store db.Querier, policy Policy) *Guard {
+	return &Guard{
+		store:  store,
+		policy: policy,
+	}
+}
+
+// Check returns how long a login of username from clientIP has to wait, zero allows it right away
+func (guard *Guard) Check(ctx context.Context, username string, clientIP string, now time.Time) (time.Duration, error) {
+	var retryAfter time.Duration
+	for _, subject := range guard.subjects(username, clientIP) {
+		throttle, err := guard.store.GetLoginThrottle(ctx, db.GetLoginThrottleParams{
+			Kind:    subject.kind,
+			Subject: subject.subject,
+		})
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if wait := throttle.NextAttemptAt.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+// Fail records a failed login of username from clientIP and holds back the next ones
+func (guard *Guard) Fail(ctx context.Context, username string, clientIP string, now time.Time) error {
+	for _, subject := range guard.subjects(username, clientIP) {
+		throttle, err := guard.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+			Kind:        subject.kind,
+			Subject:     subject.subject,
+			FailedAt:    now,
+			ResetBefore: now.Add(-guard.policy.Window),
+		})
+		if err != nil {
+			return err
+		}
+
+		delay := guard.policy.Delay(subject.kind, throttle.Failures)
+		if delay <= 0 {
+			continue
+		}
+
+		err = guard.store.DelayLogin(ctx, db.DelayLoginParams{
+			Kind:          subject.kind,
+			Subject:       subject.subject,
+			NextAttemptAt: now.Add(delay),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed forgets the failures of username after it logged in. The failures of the client ip are kept,
+// otherwise logging in to an own account would reset the limit of an attacker
+func (guard *Guard) Succeed(ctx context.Context, username string) error {
+	if guard.policy.MaxFailures <= 0 {
+		return nil
+	}
+
+	return guard.store.DeleteLoginThrottle(ctx, db.DeleteLoginThrottleParams{
+		Kind:    KindUsername,
+		Subject: username,
+	})
+}
+
+type subject struct {
+	kind    string
+	subject string
+}
+
+// subjects returns the username and client ip of a login, each only while its limit is enabled
+func (guard *Guard) subjects(username string, clientIP string) []subject {
+	var subjects []subject
+	if guard.policy.MaxFailures > 0 {
+		subjects = append(subjects, subject{kind: KindUsername, subject: username})
+	}
+	if guard.policy.MaxIPFailures > 0 && len(clientIP) > 0 {
+		subjects = append(subjects, subject{kind: KindIP, subject: hostIP(clientIP)})
+	}
+	return subjects
+}
+
+// hostIP drops the port of addresses taken from a connection
+func hostIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
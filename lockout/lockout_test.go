@@ -0,0 +1,151 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{
+	MaxFailures:     5,
+	MaxIPFailures:   20,
+	Window:          15 * time.Minute,
+	BaseDelay:       time.Second,
+	LockoutDuration: 15 * time.Minute,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   Policy
+		kind     string
+		failures int32
+		delay    time.Duration
+	}{
+		{name: "NoFailures", policy: testPolicy, kind: KindUsername, failures: 0, delay: 0},
+		{name: "FirstFailure", policy: testPolicy, kind: KindUsername, failures: 1, delay: time.Second},
+		{name: "Doubles", policy: testPolicy, kind: KindUsername, failures: 4, delay: 8 * time.Second},
+		{name: "Locked", policy: testPolicy, kind: KindUsername, failures: 5, delay: 15 * time.Minute},
+		{name: "StaysLocked", policy: testPolicy, kind: KindUsername, failures: 9, delay: 15 * time.Minute},
+		{
+			name:     "DelayCappedAtLockout",
+			policy:   Policy{MaxFailures: 50, BaseDelay: time.Second, LockoutDuration: time.Minute},
+			kind:     KindUsername,
+			failures: 30,
+			delay:    time.Minute,
+		},
+		{name: "IPNotDelayed", policy: testPolicy, kind: KindIP, failures: 19, delay: 0},
+		{name: "IPLocked", policy: testPolicy, kind: KindIP, failures: 20, delay: 15 * time.Minute},
+		{name: "Disabled", policy: Policy{}, kind: KindUsername, failures: 100, delay: 0},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.delay, tc.policy.Delay(tc.kind, tc.failures))
+		})
+	}
+}
+
+func TestGuardCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetLoginThrottle(gomock.Any(), gomock.Eq(db.GetLoginThrottleParams{Kind: KindUsername, Subject: "alice"})).
+		Times(1).
+		Return(db.LoginThrottle{NextAttemptAt: now.Add(time.Second)}, nil)
+	// the port of connection addresses is dropped
+	store.EXPECT().
+		GetLoginThrottle(gomock.Any(), gomock.Eq(db.GetLoginThrottleParams{Kind: KindIP, Subject: "10.0.0.1"})).
+		Times(1).
+		Return(db.LoginThrottle{NextAttemptAt: now.Add(time.Minute)}, nil)
+
+	retryAfter, err := NewGuard(store, testPolicy).Check(context.Background(), "alice", "10.0.0.1:5555", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, retryAfter)
+}
+
+func TestGuardCheckElapsed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetLoginThrottle(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.LoginThrottle{Failures: 5, NextAttemptAt: now.Add(-time.Second)}, nil)
+	store.EXPECT().
+		GetLoginThrottle(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.LoginThrottle{}, sql.ErrNoRows)
+
+	retryAfter, err := NewGuard(store, testPolicy).Check(context.Background(), "alice", "10.0.0.1", now)
+	require.NoError(t, err)
+	require.Zero(t, retryAfter)
+}
+
+func TestGuardDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// a disabled guard never touches the store
+	store := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(store, Policy{})
+
+	retryAfter, err := guard.Check(context.Background(), "alice", "10.0.0.1", time.Now())
+	require.NoError(t, err)
+	require.Zero(t, retryAfter)
+	require.NoError(t, guard.Fail(context.Background(), "alice", "10.0.0.1", time.Now()))
+	require.NoError(t, guard.Succeed(context.Background(), "alice"))
+}
+
+func TestGuardIPOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// without a username limit only the client ip is checked and counted
+	now := time.Now()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetLoginThrottle(gomock.Any(), gomock.Eq(db.GetLoginThrottleParams{Kind: KindIP, Subject: "10.0.0.1"})).
+		Times(1).
+		Return(db.LoginThrottle{NextAttemptAt: now.Add(time.Minute)}, nil)
+	store.EXPECT().
+		RecordLoginFailure(gomock.Any(), gomock.Eq(db.RecordLoginFailureParams{
+			Kind:        KindIP,
+			Subject:     "10.0.0.1",
+			FailedAt:    now,
+			ResetBefore: now.Add(-testPolicy.Window),
+		})).
+		Times(1).
+		Return(db.LoginThrottle{Failures: 20}, nil)
+	store.EXPECT().
+		DelayLogin(gomock.Any(), gomock.Eq(db.DelayLoginParams{
+			Kind:          KindIP,
+			Subject:       "10.0.0.1",
+			NextAttemptAt: now.Add(testPolicy.LockoutDuration),
+		})).
+		Times(1).
+		Return(nil)
+
+	policy := testPolicy
+	policy.MaxFailures = 0
+	guard := NewGuard(store, policy)
+
+	retryAfter, err := guard.Check(context.Background(), "alice", "10.0.0.1", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, retryAfter)
+	require.NoError(t, guard.Fail(context.Background(), "alice", "10.0.0.1", now))
+	require.NoError(t, guard.Succeed(context.Background(), "alice"))
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSender writes every email to an .eml file in a directory instead of delivering it
+type FileSender struct {
+	dir  string
+	from mail.Address
+}
+
+func NewFileSender(dir string, from mail.Address) *FileSender {
+	return &FileSender{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (sender *FileSender) SendEmail(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(sender.dir, 0o755); err != nil {
+		return fmt.Errorf("cannot create outbox: %w", err)
+	}
+
+	now := time.Now()
+	file, err := os.CreateTemp(sender.dir, fmt.Sprintf("%d-*.eml", now.UnixNano()))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(buildMessage(sender.from, message, now))
+	return err
+}
+
+// InMemorySender keeps the emails it is asked to send, for tests
+type InMemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewInMemorySender() *InMemorySender {
+	return &InMemorySender{}
+}
+
+func (sender *InMemorySender) SendEmail(ctx context.Context, message Message) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.messages = append(sender.messages, message)
+	return nil
+}
+
+// Messages returns the emails sent so far
+func (sender *InMemorySender) Messages() []Message {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	return append([]Message(nil), sender.messages...)
+}
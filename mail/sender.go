@@ -0,0 +1,52 @@
+// Package mail sends the emails of the bank, such as the links that verify the address of a new user.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+)
+
+// Message is an email with an HTML body
+type Message struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Content string   `json:"content"`
+}
+
+type Sender interface {
+	SendEmail(ctx context.Context, message Message) error
+}
+
+// NewSender creates the sender configured by EMAIL_SENDER: "smtp" delivers through an SMTP server,
+// "file" writes every email to EMAIL_OUTBOX_DIR instead, which is handy for local development
+func NewSender(config util.Config) (Sender, error) {
+	from := mail.Address{Name: config.EmailSenderName, Address: config.EmailSenderAddress}
+	switch config.EmailSender {
+	case "smtp":
+		return NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, from), nil
+	case "file":
+		return NewFileSender(config.EmailOutboxDir, from), nil
+	}
+	return nil, fmt.Errorf("unsupported email sender %q", config.EmailSender)
+}
+
+// buildMessage renders message as an RFC 5322 email from the given address
+func buildMessage(from mail.Address, message Message, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(message.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(message.Content)
+	return buf.Bytes()
+}
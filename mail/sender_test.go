@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomMessage() Message {
+	return Message{
+		To:      []string{util.RandomEmail()},
+		Subject: "Welcome to Simple Bank",
+		Content: "<h1>Hello " + util.RandomOwner() + "</h1>",
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := mail.Address{Name: "Simple Bank", Address: "no-reply@simplebank.local"}
+	message := randomMessage()
+	message.To = append(message.To, util.RandomEmail())
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(buildMessage(from, message, time.Now()))))
+	require.NoError(t, err)
+
+	require.Equal(t, from.String(), parsed.Header.Get("From"))
+	to, err := parsed.Header.AddressList("To")
+	require.NoError(t, err)
+	require.Len(t, to, 2)
+	require.Equal(t, message.To[1], to[1].Address)
+	require.Equal(t, message.Subject, parsed.Header.Get("Subject"))
+	require.Contains(t, parsed.Header.Get("Content-Type"), "text/html")
+
+	body, err := io.ReadAll(parsed.Body)
+	require.NoError(t, err)
+	require.Equal(t, message.Content, string(body))
+}
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileSender(dir, mail.Address{Address: "no-reply@simplebank.local"})
+
+	message := randomMessage()
+	require.NoError(t, sender.SendEmail(context.Background(), message))
+	require.NoError(t, sender.SendEmail(context.Background(), randomMessage()))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
+
+func TestInMemorySender(t *testing.T) {
+	sender := NewInMemorySender()
+	message := randomMessage()
+
+	require.NoError(t, sender.SendEmail(context.Background(), message))
+	require.Equal(t, []Message{message}, sender.Messages())
+}
+
+func TestNewSender(t *testing.T) {
+	_, err := NewSender(util.Config{EmailSender: "pigeon"})
+	require.Error(t, err)
+
+	sender, err := NewSender(util.Config{EmailSender: "file", EmailOutboxDir: t.TempDir()})
+	require.NoError(t, err)
+	require.IsType(t, &FileSender{}, sender)
+}
@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPSender delivers emails through an SMTP server, upgrading the connection with STARTTLS when the server offers it
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     mail.Address
+}
+
+func NewSMTPSender(host, port, username, password string, from mail.Address) *SMTPSender {
+	return &SMTPSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (sender *SMTPSender) SendEmail(ctx context.Context, message Message) error {
+	if len(message.To) == 0 {
+		return errors.New("email has no recipients")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(sender.host, sender.port))
+	if err != nil {
+		return fmt.Errorf("cannot connect to smtp server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, sender.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: sender.host}); err != nil {
+			return fmt.Errorf("cannot start tls: %w", err)
+		}
+	}
+
+	if len(sender.username) > 0 {
+		if err := client.Auth(smtp.PlainAuth("", sender.username, sender.password, sender.host)); err != nil {
+			return fmt.Errorf("cannot authenticate with smtp server: %w", err)
+		}
+	}
+
+	if err := client.Mail(sender.from.Address); err != nil {
+		return err
+	}
+	for _, to := range message.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(buildMessage(sender.from, message, time.Now())); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/muditshukla3/simplebank/api"
+	"github.com/muditshukla3/simplebank/audit"
 	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/gapi"
+	"github.com/muditshukla3/simplebank/ledger"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/pb"
+	"github.com/muditshukla3/simplebank/scheduler"
 	"github.com/muditshukla3/simplebank/util"
+	"github.com/muditshukla3/simplebank/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 )
 
+// shutdownTimeout bounds how long running background tasks may take to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	config, err := util.LoadConfig(".")
 	if err != nil {
@@ -23,7 +42,89 @@ func main() {
 	}
 
 	store := db.NewStore(conn)
-	server, err := api.NewServer(config, store)
+	if len(os.Args) > 1 && os.Args[1] == "verify-ledger" {
+		runLedgerVerifier(store)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		runAuditVerifier(store)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mailer, err := mail.NewSender(config)
+	if err != nil {
+		log.Fatal("cannot create email sender: ", err)
+	}
+
+	taskDistributor := worker.NewPostgresTaskDistributor()
+	taskProcessor := worker.NewPostgresTaskProcessor(config, store, mailer)
+	taskProcessor.Start()
+	log.Println("start task processor")
+
+	go runScheduler(ctx, config, store)
+	go runHoldSweeper(ctx, config, store)
+	go runGrpcServer(config, store, taskDistributor)
+	go runGinServer(config, store, taskDistributor)
+
+	<-ctx.Done()
+	log.Println("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := taskProcessor.Shutdown(shutdownCtx); err != nil {
+		log.Printf("cannot shut down task processor %v", err)
+	}
+}
+
+func runGrpcServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) {
+	server, err := gapi.NewServer(config, store, taskDistributor)
+	if err != nil {
+		log.Fatalf("cannot create grpc server %v", err)
+	}
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(server.AuthInterceptor))
+	pb.RegisterSimpleBankServer(grpcServer, server)
+	reflection.Register(grpcServer)
+
+	listener, err := net.Listen("tcp", config.GRPCServerAddress)
+	if err != nil {
+		log.Fatalf("cannot create listener %v", err)
+	}
+
+	log.Printf("start gRPC server at %s", listener.Addr().String())
+	err = grpcServer.Serve(listener)
+	if err != nil {
+		log.Fatalf("cannot start grpc server %v", err)
+	}
+}
+
+// runScheduler executes scheduled transfers until ctx is done, a zero interval disables it
+func runScheduler(ctx context.Context, config util.Config, store db.Store) {
+	if config.SchedulerInterval <= 0 {
+		log.Println("scheduled transfers are disabled")
+		return
+	}
+
+	log.Printf("start scheduler every %s", config.SchedulerInterval)
+	scheduler.NewWorker(store, config.SchedulerInterval).Start(ctx)
+}
+
+// runHoldSweeper releases expired holds until ctx is done, a zero interval disables it
+func runHoldSweeper(ctx context.Context, config util.Config, store db.Store) {
+	if config.HoldSweepInterval <= 0 {
+		log.Println("hold sweeper is disabled")
+		return
+	}
+
+	log.Printf("start hold sweeper every %s", config.HoldSweepInterval)
+	scheduler.NewHoldSweeper(store, config.HoldSweepInterval).Start(ctx)
+}
+
+func runGinServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) {
+	server, err := api.NewServer(config, store, taskDistributor)
 	if err != nil {
 		log.Fatalf("cannot create server %v", err)
 	}
@@ -32,3 +133,39 @@ func main() {
 		log.Fatalf("cannot start server %v", err)
 	}
 }
+
+// runLedgerVerifier prints the ledger report as JSON and exits with status 1 if it found discrepancies
+func runLedgerVerifier(store db.Store) {
+	report, err := ledger.Verify(context.Background(), store)
+	if err != nil {
+		log.Fatalf("cannot verify ledger %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("cannot print ledger report %v", err)
+	}
+
+	if !report.OK {
+		os.Exit(1)
+	}
+}
+
+// runAuditVerifier prints the audit log report as JSON and exits with status 1 if the hash chain is broken
+func runAuditVerifier(store db.Store) {
+	report, err := audit.Verify(context.Background(), store)
+	if err != nil {
+		log.Fatalf("cannot verify audit log %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("cannot print audit report %v", err)
+	}
+
+	if !report.OK {
+		os.Exit(1)
+	}
+}
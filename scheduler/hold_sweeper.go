@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+)
+
+// HoldSweeper releases expired holds. Like the Worker, several sweepers can run side by side
+// since each hold is locked while it is released
+type HoldSweeper struct {
+	store    db.Store
+	interval time.Duration
+}
+
+func NewHoldSweeper(store db.Store, interval time.Duration) *HoldSweeper {
+	return &HoldSweeper{
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Start releases expired holds every interval until ctx is done
+func (sweeper *HoldSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(sweeper.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := sweeper.ExpireDue(ctx, time.Now()); err != nil {
+			log.Printf("cannot expire holds %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireDue releases every active hold that expired by now and returns how many were released
+func (sweeper *HoldSweeper) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for ctx.Err() == nil {
+		_, err := sweeper.store.ExpireHoldTx(ctx, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			return expired, nil
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, ctx.Err()
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireDue(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, expired int, err error)
+	}{
+		{
+			name: "ExpiresUntilNoneDue",
+			buildStubs: func(store *mockdb.MockStore) {
+				gomock.InOrder(
+					store.EXPECT().
+						ExpireHoldTx(gomock.Any(), gomock.Eq(now)).
+						Times(2).
+						Return(db.HoldTxResult{}, nil),
+					store.EXPECT().
+						ExpireHoldTx(gomock.Any(), gomock.Eq(now)).
+						Return(db.HoldTxResult{}, sql.ErrNoRows),
+				)
+			},
+			checkResponse: func(t *testing.T, expired int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 2, expired)
+			},
+		},
+		{
+			name: "NoneDue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ExpireHoldTx(gomock.Any(), gomock.Eq(now)).
+					Times(1).
+					Return(db.HoldTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, expired int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, expired)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ExpireHoldTx(gomock.Any(), gomock.Eq(now)).
+					Times(1).
+					Return(db.HoldTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, expired int, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.Zero(t, expired)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			sweeper := NewHoldSweeper(store, time.Minute)
+			expired, err := sweeper.ExpireDue(context.Background(), now)
+			tc.checkResponse(t, expired, err)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package scheduler executes scheduled and recurring transfers and releases expired holds in the background of the server process.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+)
+
+// Worker polls for due scheduled transfers. Several workers, in one or more server processes,
+// can run side by side since each schedule is locked while it is executed
+type Worker struct {
+	store    db.Store
+	interval time.Duration
+}
+
+func NewWorker(store db.Store, interval time.Duration) *Worker {
+	return &Worker{
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Start executes due schedules every interval until ctx is done
+func (worker *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := worker.RunDue(ctx, time.Now()); err != nil {
+			log.Printf("cannot run scheduled transfers %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue executes every schedule that is due at now and returns how many runs were attempted.
+// Failed transfers are recorded on their schedule and don't stop the others
+func (worker *Worker) RunDue(ctx context.Context, now time.Time) (int, error) {
+	runs := 0
+	for ctx.Err() == nil {
+		result, err := worker.store.RunScheduledTransferTx(ctx, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			return runs, nil
+		}
+		if err != nil {
+			return runs, err
+		}
+
+		runs++
+		if result.Failure != nil {
+			log.Printf("scheduled transfer %d failed (attempt %d): %v", result.Schedule.ID, result.Schedule.Attempts, result.Failure)
+		}
+	}
+	return runs, ctx.Err()
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDue(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, runs int, err error)
+	}{
+		{
+			name: "RunsUntilNoneDue",
+			buildStubs: func(store *mockdb.MockStore) {
+				gomock.InOrder(
+					store.EXPECT().
+						RunScheduledTransferTx(gomock.Any(), gomock.Eq(now)).
+						Return(db.RunScheduledTransferTxResult{Transfer: &db.TransferTxResult{}}, nil),
+					// a failed transfer is recorded on its schedule and the worker moves on
+					store.EXPECT().
+						RunScheduledTransferTx(gomock.Any(), gomock.Eq(now)).
+						Return(db.RunScheduledTransferTxResult{Failure: db.ErrInsufficientFunds}, nil),
+					store.EXPECT().
+						RunScheduledTransferTx(gomock.Any(), gomock.Eq(now)).
+						Return(db.RunScheduledTransferTxResult{}, sql.ErrNoRows),
+				)
+			},
+			checkResponse: func(t *testing.T, runs int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 2, runs)
+			},
+		},
+		{
+			name: "NoneDue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RunScheduledTransferTx(gomock.Any(), gomock.Eq(now)).
+					Times(1).
+					Return(db.RunScheduledTransferTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, runs int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, runs)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RunScheduledTransferTx(gomock.Any(), gomock.Eq(now)).
+					Times(1).
+					Return(db.RunScheduledTransferTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, runs int, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.Zero(t, runs)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			worker := NewWorker(store, time.Minute)
+			runs, err := worker.RunDue(context.Background(), now)
+			tc.checkResponse(t, runs, err)
+		})
+	}
+}
+
+func TestStartStopsWithContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.RunScheduledTransferTxResult{}, sql.ErrNoRows)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewWorker(store, time.Millisecond).Start(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker didn't stop after its context was canceled")
+	}
+}
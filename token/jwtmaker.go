@@ -3,7 +3,6 @@ package token
 import (
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -12,51 +11,99 @@ const minSecretKeySize = 32
 
 // JWTMaker is a JSON Web Token maker
 type JWTMaker struct {
-	secretKey string
+	keyring *Keyring
 }
 
 // NewJwtMaker creates a new JWTMaker
 func NewJwtMaker(secretKey string) (Maker, error) {
-	if len(secretKey) < minSecretKeySize {
-		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	keyring, err := symmetricKeyring(KeySource{SymmetricKey: secretKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJwtKeyringMaker(keyring)
+}
+
+// NewJwtKeyringMaker creates a JWTMaker that signs with the active key of keyring and verifies with any of its keys
+func NewJwtKeyringMaker(keyring *Keyring) (Maker, error) {
+	if err := keyring.setCheck(checkJWTKey); err != nil {
+		return nil, err
 	}
 
-	return &JWTMaker{secretKey}, nil
+	return &JWTMaker{keyring}, nil
+}
+
+func checkJWTKey(key Key) error {
+	if len(key.Secret) < minSecretKeySize {
+		return fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+	return nil
 }
 
-func (maker *JWTMaker) CreateToken(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+func (maker *JWTMaker) CreateToken(claims Claims) (string, *Payload, error) {
+	payload, err := NewPayload(claims)
 
 	if err != nil {
 		return "", payload, err
 	}
+
+	key := maker.keyring.Active()
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := jwtToken.SignedString([]byte(maker.secretKey))
+	jwtToken.Header["kid"] = key.ID
+	token, err := jwtToken.SignedString(key.Secret)
 	return token, payload, err
 }
 
 func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
-
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	return verifyJWT(token, maker.keyring, true, func(jwtToken *jwt.Token, key Key) (interface{}, error) {
+		_, ok := jwtToken.Method.(*jwt.SigningMethodHMAC)
 		if !ok {
 			return nil, ErrInvalidToken
 		}
-		return []byte(maker.secretKey), nil
-	}
-	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+		return key.Secret, nil
+	})
+}
+
+// verifyJWT checks token with the key named by its kid header. Tokens without one are tried with every key
+// if allowNoKeyID is set. verificationKey returns what verifies the token with key, or an error if the
+// algorithm of the token doesn't fit the key
+func verifyJWT(
+	token string,
+	keyring *Keyring,
+	allowNoKeyID bool,
+	verificationKey func(jwtToken *jwt.Token, key Key) (interface{}, error),
+) (*Payload, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(token, &Payload{})
 	if err != nil {
-		verr, ok := err.(*jwt.ValidationError)
-		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
-			return nil, ErrExpiredToken
-		}
 		return nil, ErrInvalidToken
 	}
 
-	payload, ok := jwtToken.Claims.(*Payload)
-	if !ok {
+	kid, _ := unverified.Header["kid"].(string)
+	if len(kid) == 0 && !allowNoKeyID {
 		return nil, ErrInvalidToken
 	}
 
-	return payload, nil
+	for _, key := range keyring.verificationKeys(kid) {
+		keyFunc := func(jwtToken *jwt.Token) (interface{}, error) {
+			return verificationKey(jwtToken, key)
+		}
+
+		jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+		if err != nil {
+			verr, ok := err.(*jwt.ValidationError)
+			if ok && errors.Is(verr.Inner, ErrExpiredToken) {
+				return nil, ErrExpiredToken
+			}
+			continue
+		}
+
+		payload, ok := jwtToken.Claims.(*Payload)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		return payload, nil
+	}
+
+	return nil, ErrInvalidToken
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
@@ -14,12 +15,23 @@ func TestJwtMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
+	sessionID := uuid.New()
+	scopes := []string{"accounts:read", "transfers:write"}
+	audience := "simplebank"
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, payload, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(Claims{
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+		Scopes:    scopes,
+		Audience:  audience,
+		Duration:  duration,
+	})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -30,6 +42,10 @@ func TestJwtMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, payload.Username, username)
+	require.Equal(t, payload.Role, role)
+	require.Equal(t, payload.SessionID, sessionID)
+	require.Equal(t, payload.Scopes, scopes)
+	require.Equal(t, payload.Audience, audience)
 	require.WithinDuration(t, payload.IssuedAt, time.Now(), time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 }
@@ -38,7 +54,7 @@ func TestExpiredJWTToken(t *testing.T) {
 	maker, err := NewJwtMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	token, payload, err := maker.CreateToken(util.RandomOwner(), -time.Minute)
+	token, payload, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: -time.Minute})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -50,7 +66,7 @@ func TestExpiredJWTToken(t *testing.T) {
 }
 
 func TestInvalidJWTTokenAlgNone(t *testing.T) {
-	payload, err := NewPayload(util.RandomOwner(), time.Minute)
+	payload, err := NewPayload(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
 	require.NoError(t, err)
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
@@ -0,0 +1,97 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWTPublicMaker is a JSON Web Token maker that signs with RSA (RS256) or Ed25519 (EdDSA) private keys,
+// the kid header names the signing key
+type JWTPublicMaker struct {
+	keyring *Keyring
+}
+
+// NewJWTPublicMaker creates a new JWTPublicMaker for an *rsa.PrivateKey or an ed25519.PrivateKey
+func NewJWTPublicMaker(privateKey crypto.Signer) (Maker, error) {
+	key, err := asymmetricKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := NewKeyring(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJWTPublicKeyringMaker(keyring)
+}
+
+// NewJWTPublicKeyringMaker creates a JWTPublicMaker that signs with the active key of keyring
+// and verifies with any of its keys
+func NewJWTPublicKeyringMaker(keyring *Keyring) (Maker, error) {
+	if err := keyring.setCheck(checkJWTPublicKey); err != nil {
+		return nil, err
+	}
+
+	return &JWTPublicMaker{keyring: keyring}, nil
+}
+
+func checkJWTPublicKey(key Key) error {
+	_, err := jwtSigningMethod(key)
+	return err
+}
+
+// jwtSigningMethod is the algorithm of the tokens signed with key
+func jwtSigningMethod(key Key) (jwt.SigningMethod, error) {
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("invalid key size: rsa keys must have at least %d bits", minRSAKeyBits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key.PublicKey)
+	}
+}
+
+func (maker *JWTPublicMaker) CreateToken(claims Claims) (string, *Payload, error) {
+	payload, err := NewPayload(claims)
+	if err != nil {
+		return "", payload, err
+	}
+
+	key := maker.keyring.Active()
+	method, err := jwtSigningMethod(key)
+	if err != nil {
+		return "", payload, err
+	}
+
+	jwtToken := jwt.NewWithClaims(method, payload)
+	jwtToken.Header["kid"] = key.ID
+	token, err := jwtToken.SignedString(key.PrivateKey)
+	return token, payload, err
+}
+
+func (maker *JWTPublicMaker) VerifyToken(token string) (*Payload, error) {
+	return verifyJWT(token, maker.keyring, false, func(jwtToken *jwt.Token, key Key) (interface{}, error) {
+		// only the algorithm of the key is accepted, whatever the header claims
+		method, err := jwtSigningMethod(key)
+		if err != nil || jwtToken.Method.Alg() != method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.PublicKey, nil
+	})
+}
+
+// PublicKeys returns the keys that verify the tokens of maker, the retired ones included
+func (maker *JWTPublicMaker) PublicKeys() JWKSet {
+	return publicKeySet(maker.keyring, func(key Key) string {
+		method, _ := jwtSigningMethod(key)
+		return method.Alg()
+	})
+}
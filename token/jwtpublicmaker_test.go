@@ -0,0 +1,111 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTPublicMaker(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		privateKey crypto.Signer
+		alg        string
+	}{
+		{name: "RS256", privateKey: rsaKey, alg: "RS256"},
+		{name: "EdDSA", privateKey: ed25519Key, alg: "EdDSA"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			maker, err := NewJWTPublicMaker(tc.privateKey)
+			require.NoError(t, err)
+
+			username := util.RandomOwner()
+			sessionID := uuid.New()
+
+			token, payload, err := maker.CreateToken(Claims{Username: username, Role: util.DepositorRole, SessionID: sessionID, Duration: time.Minute})
+			require.NoError(t, err)
+			require.NotEmpty(t, payload)
+
+			// the header names the algorithm and the key
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Payload{})
+			require.NoError(t, err)
+			require.Equal(t, tc.alg, parsed.Header["alg"])
+			require.Equal(t, maker.(KeyPublisher).PublicKeys().Keys[0].KeyID, parsed.Header["kid"])
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, username, payload.Username)
+			require.Equal(t, util.DepositorRole, payload.Role)
+			require.Equal(t, sessionID, payload.SessionID)
+
+			token, _, err = maker.CreateToken(Claims{Username: username, Role: util.DepositorRole, Duration: -time.Minute})
+			require.NoError(t, err)
+			payload, err = maker.VerifyToken(token)
+			require.EqualError(t, err, ErrExpiredToken.Error())
+			require.Nil(t, payload)
+		})
+	}
+}
+
+func TestInvalidJWTPublicToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	maker, err := NewJWTPublicMaker(rsaKey)
+	require.NoError(t, err)
+	kid := maker.(KeyPublisher).PublicKeys().Keys[0].KeyID
+
+	payload, err := NewPayload(Claims{Username: util.RandomOwner(), Role: util.BankerRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	// a token signed with the public key as an HMAC secret must not pass for an RS256 one
+	publicKey := rsaKey.Public().(*rsa.PublicKey)
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	hmacToken.Header["kid"] = kid
+	confused, err := hmacToken.SignedString(publicKey.N.Bytes())
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherToken := jwt.NewWithClaims(jwt.SigningMethodRS256, payload)
+	otherToken.Header["kid"] = kid
+	forged, err := otherToken.SignedString(otherKey)
+	require.NoError(t, err)
+
+	noneToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
+	none, err := noneToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{"AlgConfusion": confused, "OtherKey": forged, "AlgNone": none} {
+		t.Run(name, func(t *testing.T) {
+			payload, err := maker.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			require.Nil(t, payload)
+		})
+	}
+}
+
+func TestJWTPublicMakerSmallRSAKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = NewJWTPublicMaker(rsaKey)
+	require.Error(t, err)
+}
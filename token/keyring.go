@@ -0,0 +1,323 @@
+package token
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// keyringCheckInterval is how often a keyring loaded from a file looks for changes of the file
+const keyringCheckInterval = 30 * time.Second
+
+var ErrUnknownKey = errors.New("token is signed with an unknown key")
+
+// Key is a key of a Keyring. Symmetric keys have a Secret, asymmetric ones a PublicKey and,
+// unless they only verify tokens, the PrivateKey that goes with it
+type Key struct {
+	ID         string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySource tells NewMaker where to find its keys
+type KeySource struct {
+	// KeyFile is a JSON keyring, it takes precedence over the other fields and is reloaded when it changes
+	KeyFile string
+	// SymmetricKey signs the tokens of the symmetric types, RetiredSymmetricKeys still verify them
+	SymmetricKey         string
+	RetiredSymmetricKeys []string
+	// PrivateKeyFile is the PEM encoded signing key of the public types
+	PrivateKeyFile string
+}
+
+// Keyring holds the active signing key and the retired keys that still verify tokens until these expire.
+// Every token names the key it was signed with
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []Key
+
+	file          string
+	modTime       time.Time
+	checkedAt     time.Time
+	checkInterval time.Duration
+	check         func(Key) error
+}
+
+type keyFile struct {
+	Keys []struct {
+		ID             string `json:"id"`
+		Secret         string `json:"secret"`
+		PrivateKeyFile string `json:"private_key_file"`
+		PublicKeyFile  string `json:"public_key_file"`
+	} `json:"keys"`
+}
+
+// NewKeyring creates a keyring that signs with the first key and verifies with all of them
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if err := validateKeys(keys, nil); err != nil {
+		return nil, err
+	}
+
+	return &Keyring{keys: keys}, nil
+}
+
+// LoadKeyFile creates a keyring from a JSON key file of the form
+//
+//	{"keys": [{"id": "2024-06", "secret": "..."}, {"id": "2024-01", "secret": "..."}]}
+//
+// where the first key signs and the others only verify. Asymmetric keys are given as private_key_file or, when they
+// only verify, public_key_file, relative to the key file, and default to their RFC 7638 thumbprint as id.
+// The keyring picks up changes of the file without a restart
+func LoadKeyFile(file string) (*Keyring, error) {
+	keyring := &Keyring{
+		file:          file,
+		checkInterval: keyringCheckInterval,
+	}
+
+	if err := keyring.Reload(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// Reload reads the key file of the keyring again, on error the keyring keeps its keys
+func (keyring *Keyring) Reload() error {
+	if len(keyring.file) == 0 {
+		return nil
+	}
+
+	info, err := os.Stat(keyring.file)
+	if err != nil {
+		return fmt.Errorf("cannot read key file: %w", err)
+	}
+
+	keys, err := readKeyFile(keyring.file)
+	if err != nil {
+		return err
+	}
+
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	if err := validateKeys(keys, keyring.check); err != nil {
+		return err
+	}
+
+	keyring.keys = keys
+	keyring.modTime = info.ModTime()
+	keyring.checkedAt = time.Now()
+	return nil
+}
+
+// Active returns the key that signs new tokens
+func (keyring *Keyring) Active() Key {
+	keyring.reloadIfChanged()
+
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	return keyring.keys[0]
+}
+
+// Key returns the key with id, active or retired
+func (keyring *Keyring) Key(id string) (Key, bool) {
+	for _, key := range keyring.Keys() {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Keys returns every key of the keyring, the active one first
+func (keyring *Keyring) Keys() []Key {
+	keyring.reloadIfChanged()
+
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	return append([]Key(nil), keyring.keys...)
+}
+
+// verificationKeys returns the keys to verify a token naming the key id with, tokens made before key ids
+// don't name any and are tried with every key
+func (keyring *Keyring) verificationKeys(id string) []Key {
+	if len(id) == 0 {
+		return keyring.Keys()
+	}
+
+	key, ok := keyring.Key(id)
+	if !ok {
+		return nil
+	}
+	return []Key{key}
+}
+
+// setCheck makes sure every key, now and after reloads, suits the maker of the keyring
+func (keyring *Keyring) setCheck(check func(Key) error) error {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	if err := validateKeys(keyring.keys, check); err != nil {
+		return err
+	}
+	keyring.check = check
+	return nil
+}
+
+// reloadIfChanged reloads a key file whose modification time changed since it was read,
+// it looks at the file at most once per check interval
+func (keyring *Keyring) reloadIfChanged() {
+	if len(keyring.file) == 0 {
+		return
+	}
+
+	keyring.mu.Lock()
+	if time.Since(keyring.checkedAt) < keyring.checkInterval {
+		keyring.mu.Unlock()
+		return
+	}
+	keyring.checkedAt = time.Now()
+	modTime := keyring.modTime
+	keyring.mu.Unlock()
+
+	info, err := os.Stat(keyring.file)
+	if err != nil {
+		log.Printf("cannot check token key file: %v", err)
+		return
+	}
+	if info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err := keyring.Reload(); err != nil {
+		log.Printf("cannot reload token key file, keeping the old keys: %v", err)
+		return
+	}
+	log.Printf("reloaded token key file %s", keyring.file)
+}
+
+func readKeyFile(file string) ([]Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key file: %w", err)
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("cannot parse key file: %w", err)
+	}
+
+	dir := filepath.Dir(file)
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	keys := make([]Key, 0, len(kf.Keys))
+	for i, entry := range kf.Keys {
+		key := Key{ID: entry.ID}
+
+		switch {
+		case len(entry.Secret) > 0:
+			key.Secret = []byte(entry.Secret)
+		case len(entry.PrivateKeyFile) > 0:
+			key.PrivateKey, err = LoadPrivateKey(resolve(entry.PrivateKeyFile))
+			if err != nil {
+				return nil, err
+			}
+			key.PublicKey = key.PrivateKey.Public()
+		case len(entry.PublicKeyFile) > 0:
+			key.PublicKey, err = LoadPublicKey(resolve(entry.PublicKeyFile))
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("key %d of the key file has no secret, private_key_file or public_key_file", i)
+		}
+
+		if len(key.ID) == 0 && key.PublicKey != nil {
+			key.ID, err = publicKeyID(key.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func validateKeys(keys []Key, check func(Key) error) error {
+	if len(keys) == 0 {
+		return errors.New("keyring has no keys")
+	}
+	if len(keys[0].Secret) == 0 && keys[0].PrivateKey == nil {
+		return fmt.Errorf("active key %q cannot sign tokens", keys[0].ID)
+	}
+
+	ids := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if len(key.ID) == 0 {
+			return errors.New("every key needs an id")
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ids[key.ID] = true
+
+		if check != nil {
+			if err := check(key); err != nil {
+				return fmt.Errorf("key %q: %w", key.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// symmetricKeyID names a secret key without giving it away
+func symmetricKeyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// symmetricKeyring is the keyring of TOKEN_SYMMETRIC_KEY and the retired keys
+func symmetricKeyring(source KeySource) (*Keyring, error) {
+	secrets := append([]string{source.SymmetricKey}, source.RetiredSymmetricKeys...)
+
+	keys := make([]Key, 0, len(secrets))
+	for i, secret := range secrets {
+		if i > 0 && len(secret) == 0 {
+			continue
+		}
+		keys = append(keys, Key{ID: symmetricKeyID([]byte(secret)), Secret: []byte(secret)})
+	}
+	return NewKeyring(keys...)
+}
+
+// asymmetricKey wraps a private key with its public key and thumbprint id
+func asymmetricKey(privateKey crypto.Signer) (Key, error) {
+	id, err := publicKeyID(privateKey.Public())
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{ID: id, PrivateKey: privateKey, PublicKey: privateKey.Public()}, nil
+}
+
+func publicKeyID(publicKey crypto.PublicKey) (string, error) {
+	jwk, err := NewJWK(publicKey, "")
+	if err != nil {
+		return "", err
+	}
+	return jwk.KeyID, nil
+}
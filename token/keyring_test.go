@@ -0,0 +1,214 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymmetricKeyRotation(t *testing.T) {
+	oldKey := util.RandomString(32)
+	newKey := util.RandomString(32)
+
+	for _, tokenType := range []string{PasetoType, JWTType} {
+		t.Run(tokenType, func(t *testing.T) {
+			oldMaker, err := NewMaker(tokenType, KeySource{SymmetricKey: oldKey})
+			require.NoError(t, err)
+
+			oldToken, _, err := oldMaker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+			require.NoError(t, err)
+
+			// the old key is retired but still trusted
+			maker, err := NewMaker(tokenType, KeySource{SymmetricKey: newKey, RetiredSymmetricKeys: []string{oldKey, ""}})
+			require.NoError(t, err)
+
+			_, err = maker.VerifyToken(oldToken)
+			require.NoError(t, err)
+
+			newToken, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+			require.NoError(t, err)
+
+			// new tokens are signed with the new key only
+			_, err = oldMaker.VerifyToken(newToken)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+
+			// once dropped, the old key no longer verifies
+			maker, err = NewMaker(tokenType, KeySource{SymmetricKey: newKey})
+			require.NoError(t, err)
+
+			_, err = maker.VerifyToken(oldToken)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			_, err = maker.VerifyToken(newToken)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTokensWithoutKeyID(t *testing.T) {
+	oldKey := util.RandomString(32)
+	payload, err := NewPayload(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	// tokens issued before key ids
+	pasetoToken, err := paseto.NewV2().Encrypt([]byte(oldKey), payload, nil)
+	require.NoError(t, err)
+	jwtToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, payload).SignedString([]byte(oldKey))
+	require.NoError(t, err)
+
+	source := KeySource{SymmetricKey: util.RandomString(32), RetiredSymmetricKeys: []string{oldKey}}
+	for tokenType, token := range map[string]string{PasetoType: pasetoToken, JWTType: jwtToken} {
+		maker, err := NewMaker(tokenType, source)
+		require.NoError(t, err)
+
+		verified, err := maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, payload.ID, verified.ID)
+	}
+}
+
+func TestUnknownKeyID(t *testing.T) {
+	maker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	// a token naming a key the maker doesn't know isn't tried with the other keys
+	other, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	token, _, err := other.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func writeKeyFile(t *testing.T, file string, keys ...map[string]string) {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, data, 0600))
+}
+
+func TestKeyFileReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	oldKey := map[string]string{"id": "2024-01", "secret": util.RandomString(32)}
+	newKey := map[string]string{"id": "2024-06", "secret": util.RandomString(32)}
+	writeKeyFile(t, file, oldKey)
+
+	keyring, err := LoadKeyFile(file)
+	require.NoError(t, err)
+	keyring.checkInterval = 0
+
+	maker, err := NewPasetoKeyringMaker(keyring)
+	require.NoError(t, err)
+
+	oldToken, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	// rotate: the new key signs, the old one only verifies
+	writeKeyFile(t, file, newKey, oldKey)
+	touch(t, file, time.Now().Add(time.Second))
+
+	require.Equal(t, "2024-06", keyring.Active().ID)
+	_, err = maker.VerifyToken(oldToken)
+	require.NoError(t, err)
+
+	newToken, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	// a broken file keeps the keys that were loaded
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0600))
+	touch(t, file, time.Now().Add(2*time.Second))
+
+	require.Error(t, keyring.Reload())
+	require.Equal(t, "2024-06", keyring.Active().ID)
+	_, err = maker.VerifyToken(newToken)
+	require.NoError(t, err)
+
+	// as does a key the maker can't use
+	writeKeyFile(t, file, map[string]string{"id": "short", "secret": "short"})
+	require.Error(t, keyring.Reload())
+	require.Len(t, keyring.Keys(), 2)
+
+	// retire the old key for good
+	writeKeyFile(t, file, newKey)
+	touch(t, file, time.Now().Add(3*time.Second))
+
+	_, err = maker.VerifyToken(oldToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	_, err = maker.VerifyToken(newToken)
+	require.NoError(t, err)
+}
+
+func touch(t *testing.T, file string, modTime time.Time) {
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func TestPublicKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	_, oldPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(newPrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	// the retired key only needs its public half
+	der, err = x509.MarshalPKIXPublicKey(oldPrivateKey.Public())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.pub.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	file := filepath.Join(dir, "keys.json")
+	writeKeyFile(t, file, map[string]string{"private_key_file": "new.pem"}, map[string]string{"public_key_file": "old.pub.pem"})
+
+	oldMaker, err := NewPasetoPublicMaker(oldPrivateKey)
+	require.NoError(t, err)
+	oldToken, _, err := oldMaker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	maker, err := NewMaker(PasetoPublicType, KeySource{KeyFile: file})
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(oldToken)
+	require.NoError(t, err)
+
+	// both keys are published, named by their thumbprints
+	jwks := maker.(KeyPublisher).PublicKeys()
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, oldMaker.(KeyPublisher).PublicKeys().Keys[0], jwks.Keys[1])
+
+	// a key that can only verify can't be the active one
+	writeKeyFile(t, file, map[string]string{"public_key_file": "old.pub.pem"})
+	_, err = LoadKeyFile(file)
+	require.Error(t, err)
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	secret := []byte(util.RandomString(32))
+
+	_, err := NewKeyring()
+	require.Error(t, err)
+
+	_, err = NewKeyring(Key{Secret: secret})
+	require.Error(t, err)
+
+	_, err = NewKeyring(Key{ID: "a", Secret: secret}, Key{ID: "a", Secret: secret})
+	require.Error(t, err)
+
+	// keys of the wrong kind are refused by the maker
+	keyring, err := NewKeyring(Key{ID: "a", Secret: secret})
+	require.NoError(t, err)
+	_, err = NewPasetoPublicKeyringMaker(keyring)
+	require.Error(t, err)
+}
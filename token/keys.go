@@ -0,0 +1,149 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+const minRSAKeyBits = 2048
+
+// KeyPublisher is implemented by makers that sign tokens with a private key,
+// anyone holding the published public keys can verify their tokens
+type KeyPublisher interface {
+	PublicKeys() JWKSet
+}
+
+// JWK is a public key in the JSON Web Key format of RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKSet is the document served to token verifiers
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadPrivateKey reads a PKCS #8 PEM encoded Ed25519 or RSA private key from file
+func LoadPrivateKey(file string) (crypto.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read private key: %w", err)
+	}
+	return ParsePrivateKey(data)
+}
+
+// LoadPublicKey reads a PEM encoded PKIX Ed25519 or RSA public key from file
+func LoadPublicKey(file string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read public key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// ParsePrivateKey parses a PKCS #8 PEM encoded Ed25519 or RSA private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("invalid key size: rsa keys must have at least %d bits", minRSAKeyBits)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// NewJWK describes publicKey as a JWK for alg, its key id is the RFC 7638 thumbprint of the key
+func NewJWK(publicKey crypto.PublicKey, alg string) (JWK, error) {
+	var jwk JWK
+	// the members of the thumbprint in lexicographic order, see RFC 7638
+	var thumbprint []byte
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		jwk = JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(key),
+		}
+		thumbprint, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X})
+	case *rsa.PublicKey:
+		jwk = JWK{
+			KeyType:  "RSA",
+			Modulus:  base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+		thumbprint, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.Exponent, jwk.KeyType, jwk.Modulus})
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	sum := sha256.Sum256(thumbprint)
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(sum[:])
+	jwk.Algorithm = alg
+	jwk.Use = "sig"
+	return jwk, nil
+}
+
+// publicKeySet publishes the keys of keyring, alg names the algorithm each key signs with
+func publicKeySet(keyring *Keyring, alg func(Key) string) JWKSet {
+	keys := keyring.Keys()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk, err := NewJWK(key.PublicKey, alg(key))
+		if err != nil {
+			continue
+		}
+		jwk.KeyID = key.ID
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
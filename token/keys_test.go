@@ -0,0 +1,69 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodePrivateKey(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "token.pem")
+	require.NoError(t, os.WriteFile(file, encodePrivateKey(t, ed25519Key), 0600))
+
+	key, err := LoadPrivateKey(file)
+	require.NoError(t, err)
+	require.Equal(t, ed25519Key, key)
+
+	_, err = LoadPrivateKey(filepath.Join(t.TempDir(), "missing.pem"))
+	require.Error(t, err)
+}
+
+func TestParsePrivateKeyInvalid(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+
+	_, err = ParsePrivateKey(encodePrivateKey(t, smallKey))
+	require.Error(t, err)
+}
+
+// TestJWKThumbprint checks the key id against the example of RFC 7638
+func TestJWKThumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+
+	jwk, err := NewJWK(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}, "RS256")
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jwk.KeyID)
+	require.Equal(t, "AQAB", jwk.Exponent)
+	require.Equal(t, "RSA", jwk.KeyType)
+	require.Equal(t, "sig", jwk.Use)
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	jwk, err = NewJWK(publicKey, "EdDSA")
+	require.NoError(t, err)
+	require.Equal(t, "OKP", jwk.KeyType)
+	require.Equal(t, "Ed25519", jwk.Curve)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(publicKey), jwk.X)
+}
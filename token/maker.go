@@ -1,9 +1,64 @@
 package token
 
-import "time"
+import (
+	"crypto"
+	"fmt"
+)
+
+// token types selectable with TOKEN_TYPE
+const (
+	PasetoType       = "paseto"
+	JWTType          = "jwt"
+	PasetoPublicType = "paseto-public"
+	JWTPublicType    = "jwt-public"
+)
 
 //Maker is an interface for managing tokens
 type Maker interface {
-	CreateToken(username string, duration time.Duration) (string, *Payload, error)
+	CreateToken(claims Claims) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
 }
+
+// NewMaker creates the maker of tokenType with the keys of source. The symmetric types use the symmetric keys,
+// the public types the private key file, and a key file can hold the keys of either. An empty tokenType is
+// a symmetric PASETO maker
+func NewMaker(tokenType string, source KeySource) (Maker, error) {
+	var keyring *Keyring
+	var err error
+
+	switch {
+	case len(source.KeyFile) > 0:
+		keyring, err = LoadKeyFile(source.KeyFile)
+	case tokenType == PasetoPublicType || tokenType == JWTPublicType:
+		var privateKey crypto.Signer
+		privateKey, err = LoadPrivateKey(source.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var key Key
+		key, err = asymmetricKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		keyring, err = NewKeyring(key)
+	default:
+		keyring, err = symmetricKeyring(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch tokenType {
+	case "", PasetoType:
+		return NewPasetoKeyringMaker(keyring)
+	case JWTType:
+		return NewJwtKeyringMaker(keyring)
+	case PasetoPublicType:
+		return NewPasetoPublicKeyringMaker(keyring)
+	case JWTPublicType:
+		return NewJWTPublicKeyringMaker(keyring)
+	default:
+		return nil, fmt.Errorf("unknown token type %q", tokenType)
+	}
+}
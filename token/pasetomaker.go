@@ -1,8 +1,6 @@
 package token
 
 import (
-	"time"
-
 	"github.com/aead/chacha20poly1305"
 	"github.com/o1egl/paseto"
 	"github.com/pkg/errors"
@@ -10,44 +8,72 @@ import (
 
 // PasetoMaker is a Paseto Web Token maker
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto  *paseto.V2
+	keyring *Keyring
 }
 
 func NewPasetoMaker(symmetricKey string) (Maker, error) {
-	if len(symmetricKey) != chacha20poly1305.KeySize {
-		return nil, errors.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
+	keyring, err := symmetricKeyring(KeySource{SymmetricKey: symmetricKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPasetoKeyringMaker(keyring)
+}
+
+// NewPasetoKeyringMaker creates a PasetoMaker that encrypts with the active key of keyring
+// and decrypts with any of its keys
+func NewPasetoKeyringMaker(keyring *Keyring) (Maker, error) {
+	if err := keyring.setCheck(checkPasetoKey); err != nil {
+		return nil, err
 	}
 
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+		paseto:  paseto.NewV2(),
+		keyring: keyring,
 	}
 
 	return maker, nil
 }
 
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+func checkPasetoKey(key Key) error {
+	if len(key.Secret) != chacha20poly1305.KeySize {
+		return errors.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
+	}
+	return nil
+}
+
+func (maker *PasetoMaker) CreateToken(claims Claims) (string, *Payload, error) {
+	payload, err := NewPayload(claims)
 
 	if err != nil {
 		return "", payload, err
 	}
-	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+
+	key := maker.keyring.Active()
+	token, err := maker.paseto.Encrypt(key.Secret, payload, pasetoFooter{KeyID: key.ID})
 	return token, payload, err
 }
 func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
-	payload := &Payload{}
+	// tokens from before key ids have no footer
+	var footer pasetoFooter
+	_ = paseto.ParseFooter(token, &footer)
 
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
-	if err != nil {
-		return nil, ErrInvalidToken
-	}
+	for _, key := range maker.keyring.verificationKeys(footer.KeyID) {
+		payload := &Payload{}
 
-	err = payload.Valid()
-	if err != nil {
-		return nil, err
+		err := maker.paseto.Decrypt(token, key.Secret, payload, nil)
+		if err != nil {
+			continue
+		}
+
+		err = payload.Valid()
+		if err != nil {
+			return nil, err
+		}
+
+		return payload, nil
 	}
 
-	return payload, err
+	return nil, ErrInvalidToken
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/muditshukla3/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
@@ -13,12 +14,23 @@ func TestJwtPasetoMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
+	sessionID := uuid.New()
+	scopes := []string{"accounts:read", "transfers:write"}
+	audience := "simplebank"
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, payload, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(Claims{
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+		Scopes:    scopes,
+		Audience:  audience,
+		Duration:  duration,
+	})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -29,6 +41,10 @@ func TestJwtPasetoMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, payload.Username, username)
+	require.Equal(t, payload.Role, role)
+	require.Equal(t, payload.SessionID, sessionID)
+	require.Equal(t, payload.Scopes, scopes)
+	require.Equal(t, payload.Audience, audience)
 	require.WithinDuration(t, payload.IssuedAt, time.Now(), time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 }
@@ -37,7 +53,7 @@ func TestExpiredPasetoToken(t *testing.T) {
 	maker, err := NewPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	token, payload, err := maker.CreateToken(util.RandomOwner(), -time.Minute)
+	token, payload, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: -time.Minute})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -48,3 +64,17 @@ func TestExpiredPasetoToken(t *testing.T) {
 	require.Nil(t, payload)
 
 }
+
+func TestPasetoTokenWithoutScopes(t *testing.T) {
+	maker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	// an empty scope list grants nothing, unlike a missing one
+	token, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Scopes: []string{}, Duration: time.Minute})
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotNil(t, payload.Scopes)
+	require.False(t, payload.HasScope("accounts:read"))
+}
@@ -0,0 +1,149 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const pasetoV4PublicHeader = "v4.public."
+
+// PasetoPublicMaker is a PASETO v4.public token maker. Tokens are signed with an Ed25519 key and carry
+// its key id in the footer
+type PasetoPublicMaker struct {
+	keyring *Keyring
+}
+
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+func NewPasetoPublicMaker(privateKey ed25519.PrivateKey) (Maker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid key size: must be an ed25519 private key")
+	}
+
+	key, err := asymmetricKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := NewKeyring(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPasetoPublicKeyringMaker(keyring)
+}
+
+// NewPasetoPublicKeyringMaker creates a PasetoPublicMaker that signs with the active key of keyring
+// and verifies with any of its keys
+func NewPasetoPublicKeyringMaker(keyring *Keyring) (Maker, error) {
+	if err := keyring.setCheck(checkPasetoPublicKey); err != nil {
+		return nil, err
+	}
+
+	return &PasetoPublicMaker{keyring: keyring}, nil
+}
+
+func checkPasetoPublicKey(key Key) error {
+	if _, ok := key.PublicKey.(ed25519.PublicKey); !ok {
+		return errors.New("must be an ed25519 key")
+	}
+	return nil
+}
+
+func (maker *PasetoPublicMaker) CreateToken(claims Claims) (string, *Payload, error) {
+	payload, err := NewPayload(claims)
+	if err != nil {
+		return "", payload, err
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", payload, err
+	}
+	key := maker.keyring.Active()
+	footer, err := json.Marshal(pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return "", payload, err
+	}
+
+	signature := ed25519.Sign(key.PrivateKey.(ed25519.PrivateKey), pasetoPAE([]byte(pasetoV4PublicHeader), message, footer, nil))
+	token := pasetoV4PublicHeader +
+		base64.RawURLEncoding.EncodeToString(append(message, signature...)) + "." +
+		base64.RawURLEncoding.EncodeToString(footer)
+	return token, payload, nil
+}
+
+func (maker *PasetoPublicMaker) VerifyToken(token string) (*Payload, error) {
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, pasetoV4PublicHeader), ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var f pasetoFooter
+	if err := json.Unmarshal(footer, &f); err != nil || len(f.KeyID) == 0 {
+		return nil, ErrInvalidToken
+	}
+	key, ok := maker.keyring.Key(f.KeyID)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	message := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(key.PublicKey.(ed25519.PublicKey), pasetoPAE([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid()
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns the keys that verify the tokens of maker, the retired ones included
+func (maker *PasetoPublicMaker) PublicKeys() JWKSet {
+	return publicKeySet(maker.keyring, func(Key) string { return "EdDSA" })
+}
+
+// pasetoPAE is the pre-authentication encoding of the PASETO specification, it is what gets signed
+func pasetoPAE(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	le64 := func(n int) {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&(1<<63-1))
+		buf.Write(b[:])
+	}
+
+	le64(len(pieces))
+	for _, piece := range pieces {
+		le64(len(piece))
+		buf.Write(piece)
+	}
+	return buf.Bytes()
+}
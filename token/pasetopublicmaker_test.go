@@ -0,0 +1,116 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPasetoPublicMaker(t *testing.T) Maker {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoPublicMaker(privateKey)
+	require.NoError(t, err)
+	return maker
+}
+
+func TestPasetoPublicMaker(t *testing.T) {
+	maker := newTestPasetoPublicMaker(t)
+
+	username := util.RandomOwner()
+	role := util.DepositorRole
+	sessionID := uuid.New()
+	duration := time.Minute
+	expiredAt := time.Now().Add(duration)
+
+	token, payload, err := maker.CreateToken(Claims{Username: username, Role: role, SessionID: sessionID, Duration: duration})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, "v4.public."))
+	require.NotEmpty(t, payload)
+
+	// the footer names the key
+	footer, err := base64.RawURLEncoding.DecodeString(token[strings.LastIndex(token, ".")+1:])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kid":"`+maker.(KeyPublisher).PublicKeys().Keys[0].KeyID+`"}`, string(footer))
+
+	payload, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotZero(t, payload.ID)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
+	require.Equal(t, sessionID, payload.SessionID)
+	require.WithinDuration(t, time.Now(), payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
+}
+
+func TestExpiredPasetoPublicToken(t *testing.T) {
+	maker := newTestPasetoPublicMaker(t)
+
+	token, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: -time.Minute})
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidPasetoPublicToken(t *testing.T) {
+	maker := newTestPasetoPublicMaker(t)
+
+	token, _, err := maker.CreateToken(Claims{Username: util.RandomOwner(), Role: util.DepositorRole, Duration: time.Minute})
+	require.NoError(t, err)
+
+	body := strings.Split(token, ".")[2]
+	decoded, err := base64.RawURLEncoding.DecodeString(body)
+	require.NoError(t, err)
+	decoded[0] ^= 1
+	tampered := strings.Replace(token, body, base64.RawURLEncoding.EncodeToString(decoded), 1)
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "OtherKey", token: func() string {
+			token, _, err := newTestPasetoPublicMaker(t).CreateToken(Claims{Username: util.RandomOwner(), Role: util.BankerRole, Duration: time.Minute})
+			require.NoError(t, err)
+			return token
+		}()},
+		{name: "TamperedPayload", token: tampered},
+		{name: "NoFooter", token: token[:strings.LastIndex(token, ".")]},
+		{name: "LocalToken", token: strings.Replace(token, "v4.public.", "v4.local.", 1)},
+		{name: "Garbage", token: "v4.public.!!!.!!!"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := maker.VerifyToken(tc.token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			require.Nil(t, payload)
+		})
+	}
+}
+
+// TestPasetoV4PublicVector checks the signature against test vector 4-S-1 of the PASETO specification
+func TestPasetoV4PublicVector(t *testing.T) {
+	secretKey, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	require.NoError(t, err)
+
+	message := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	signature := ed25519.Sign(ed25519.PrivateKey(secretKey), pasetoPAE([]byte(pasetoV4PublicHeader), message, nil, nil))
+
+	token := pasetoV4PublicHeader + base64.RawURLEncoding.EncodeToString(append(message, signature...))
+	require.Equal(t, "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9"+
+		"bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA", token)
+}
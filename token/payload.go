@@ -8,8 +8,15 @@ import (
 )
 
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	// SessionID ties an access token to the login session it was issued for, it is empty on refresh tokens
+	SessionID uuid.UUID `json:"session_id"`
+	// Scopes limit what the token can be used for, tokens issued before scopes existed have none
+	Scopes []string `json:"scopes"`
+	// Audience is who the token is meant for, tokens issued before audiences existed have none
+	Audience  string    `json:"audience"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
@@ -19,7 +26,18 @@ var (
 	ErrExpiredToken = errors.New("token has expired")
 )
 
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+// Claims are what a new token asserts about its user, and for how long
+type Claims struct {
+	Username string
+	Role     string
+	// SessionID ties an access token to the login session it is issued for
+	SessionID uuid.UUID
+	Scopes    []string
+	Audience  string
+	Duration  time.Duration
+}
+
+func NewPayload(claims Claims) (*Payload, error) {
 
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
@@ -28,14 +46,42 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 
 	payload := &Payload{
 		ID:        tokenID,
-		Username:  username,
+		Username:  claims.Username,
+		Role:      claims.Role,
+		SessionID: claims.SessionID,
+		Scopes:    claims.Scopes,
+		Audience:  claims.Audience,
 		IssuedAt:  time.Now(),
-		ExpiredAt: time.Now().Add(duration),
+		ExpiredAt: time.Now().Add(claims.Duration),
 	}
 
 	return payload, nil
 }
 
+// HasScope reports whether the token grants scope. Tokens without scopes grant every scope
+func (payload *Payload) HasScope(scope string) bool {
+	if payload.Scopes == nil {
+		return true
+	}
+	for _, s := range payload.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAudience reports whether the token is meant for audience. Tokens without an audience are meant for anyone
+func (payload *Payload) HasAudience(audience string) bool {
+	return payload.Audience == "" || payload.Audience == audience
+}
+
+// RefreshAudience is the audience of the refresh tokens issued along access tokens for audience,
+// so that one can't be used as the other
+func RefreshAudience(audience string) string {
+	return audience + "/refresh"
+}
+
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.ExpiredAt) {
 		return ErrExpiredToken
@@ -0,0 +1,33 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadScopes(t *testing.T) {
+	payload := &Payload{Scopes: []string{"accounts:read"}}
+	require.True(t, payload.HasScope("accounts:read"))
+	require.False(t, payload.HasScope("transfers:write"))
+
+	payload.Scopes = []string{}
+	require.False(t, payload.HasScope("accounts:read"))
+
+	// tokens from before scopes
+	payload.Scopes = nil
+	require.True(t, payload.HasScope("transfers:write"))
+}
+
+func TestPayloadAudience(t *testing.T) {
+	payload := &Payload{Audience: "simplebank"}
+	require.True(t, payload.HasAudience("simplebank"))
+	require.False(t, payload.HasAudience(RefreshAudience("simplebank")))
+
+	payload.Audience = RefreshAudience("simplebank")
+	require.False(t, payload.HasAudience("simplebank"))
+
+	// tokens from before audiences
+	payload.Audience = ""
+	require.True(t, payload.HasAudience("simplebank"))
+}
@@ -0,0 +1,25 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets when enabling two-factor authentication
+const RecoveryCodeCount = 10
+
+// GenerateRecoveryCodes returns n single-use codes that stand in for a totp code when the authenticator is lost,
+// formatted like 1a2b3-c4d5e
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	buf := make([]byte, 5)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := hex.EncodeToString(buf)
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+	}
+	return codes, nil
+}
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the test vectors in RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCodeRFCVectors(t *testing.T) {
+	// the RFC lists 8 digit codes, these are their last 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		got, err := GenerateCode(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, got, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	now := time.Now()
+	passcode, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, passcode, now)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// the previous period is still accepted, older ones are not
+	step, ok = Validate(secret, passcode, now.Add(Period))
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	_, ok = Validate(secret, passcode, now.Add(3*Period))
+	require.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now)
+	require.False(t, ok)
+
+	_, ok = Validate("not base32!", passcode, now)
+	require.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Simple Bank", "alice", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/Simple Bank:alice", parsed.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	require.Equal(t, "Simple Bank", parsed.Query().Get("issuer"))
+	require.Equal(t, "6", parsed.Query().Get("digits"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Len(t, code, 11)
+		require.Equal(t, 5, strings.Index(code, "-"))
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+}
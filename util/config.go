@@ -10,9 +10,48 @@ type Config struct {
 	DBDriver             string        `mapstructure:"DB_DRIVER"`
 	DBSource             string        `mapstructure:"DB_SOURCE"`
 	ServerAddress        string        `mapstructure:"SERVER_ADDRESS"`
+	GRPCServerAddress    string        `mapstructure:"GRPC_SERVER_ADDRESS"`
 	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenType            string        `mapstructure:"TOKEN_TYPE"`
+	TokenPrivateKeyFile  string        `mapstructure:"TOKEN_PRIVATE_KEY_FILE"`
+	TokenKeyFile         string        `mapstructure:"TOKEN_KEY_FILE"`
 	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	FXRatesFile          string        `mapstructure:"FX_RATES_FILE"`
+	FundingProvider      string        `mapstructure:"FUNDING_PROVIDER"`
+	SchedulerInterval    time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
+	EmailSender          string        `mapstructure:"EMAIL_SENDER"`
+	EmailSenderName      string        `mapstructure:"EMAIL_SENDER_NAME"`
+	EmailSenderAddress   string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
+	EmailOutboxDir       string        `mapstructure:"EMAIL_OUTBOX_DIR"`
+	SMTPHost             string        `mapstructure:"SMTP_HOST"`
+	SMTPPort             string        `mapstructure:"SMTP_PORT"`
+	SMTPUsername         string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword         string        `mapstructure:"SMTP_PASSWORD"`
+	VerifyEmailURL       string        `mapstructure:"VERIFY_EMAIL_URL"`
+	ResetPasswordURL     string        `mapstructure:"RESET_PASSWORD_URL"`
+	// UnverifiedTransferLimit is the largest amount a user without a verified email may transfer, zero disables the check
+	UnverifiedTransferLimit int64 `mapstructure:"UNVERIFIED_TRANSFER_LIMIT"`
+	// TOTPTransferThreshold is the amount above which users with two-factor authentication must send a fresh code,
+	// zero disables the check
+	TOTPTransferThreshold int64 `mapstructure:"TOTP_TRANSFER_THRESHOLD"`
+	// LoginMaxFailures is the number of failed logins that locks a username, zero disables the username limit
+	LoginMaxFailures      int           `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginMaxFailuresPerIP int           `mapstructure:"LOGIN_MAX_FAILURES_PER_IP"`
+	LoginFailureWindow    time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginBaseDelay        time.Duration `mapstructure:"LOGIN_BASE_DELAY"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	// TrustedProxies are the ips or CIDRs of the reverse proxies whose X-Forwarded-For header names the client ip,
+	// comma separated. None are trusted by default, so the client ip is the address of the connection
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+	// TokenRetiredSymmetricKeys are former TOKEN_SYMMETRIC_KEY values that still verify tokens, comma separated
+	TokenRetiredSymmetricKeys []string `mapstructure:"TOKEN_RETIRED_SYMMETRIC_KEYS"`
+	// TokenAudience is the audience of the access tokens the servers issue and accept
+	TokenAudience string `mapstructure:"TOKEN_AUDIENCE"`
+	// HoldDuration is how long a hold reserves money unless it is placed with an earlier expires_at
+	HoldDuration time.Duration `mapstructure:"HOLD_DURATION"`
+	// HoldSweepInterval is how often expired holds are released, zero disables the sweeper
+	HoldSweepInterval time.Duration `mapstructure:"HOLD_SWEEP_INTERVAL"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -0,0 +1,16 @@
+package util
+
+const (
+	FundingKindDeposit    = "deposit"
+	FundingKindWithdrawal = "withdrawal"
+)
+
+const (
+	FundingStatusPending = "pending"
+	FundingStatusSettled = "settled"
+	FundingStatusFailed  = "failed"
+)
+
+// SettlementOwner owns the per-currency settlement accounts that deposits and withdrawals are booked against.
+// The user and its accounts are created by the add_fundings migration
+const SettlementOwner = "simplebank"
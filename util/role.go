@@ -0,0 +1,15 @@
+package util
+
+const (
+	DepositorRole = "depositor"
+	BankerRole    = "banker"
+)
+
+func IsRoleSupported(role string) bool {
+	switch role {
+	case DepositorRole, BankerRole:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package util
+
+import "time"
+
+const (
+	FrequencyOnce    = "once"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusPaused    = "paused"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusFailed    = "failed"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// NextScheduledRun returns the occurrence that follows runs earlier occurrences of a schedule starting at startAt,
+// or false once a one-off schedule has run. Occurrences are counted from startAt rather than from the previous run,
+// so a late or retried run doesn't shift the ones after it, and a monthly schedule starting on the 31st
+// runs on the last day of shorter months
+func NextScheduledRun(frequency string, startAt time.Time, runs int32) (time.Time, bool) {
+	switch frequency {
+	case FrequencyDaily:
+		return startAt.AddDate(0, 0, int(runs)), true
+	case FrequencyWeekly:
+		return startAt.AddDate(0, 0, 7*int(runs)), true
+	case FrequencyMonthly:
+		year, month, day := startAt.Date()
+		// day 0 of the following month is the last day of the target month
+		lastDay := time.Date(year, month+time.Month(runs)+1, 0, 0, 0, 0, 0, startAt.Location()).Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		hour, min, sec := startAt.Clock()
+		return time.Date(year, month+time.Month(runs), day, hour, min, sec, startAt.Nanosecond(), startAt.Location()), true
+	}
+
+	if runs == 0 {
+		return startAt, true
+	}
+	return time.Time{}, false
+}
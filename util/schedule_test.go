@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextScheduledRun(t *testing.T) {
+	startAt := time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)
+
+	next, ok := NextScheduledRun(FrequencyOnce, startAt, 0)
+	require.True(t, ok)
+	require.Equal(t, startAt, next)
+
+	_, ok = NextScheduledRun(FrequencyOnce, startAt, 1)
+	require.False(t, ok)
+
+	next, ok = NextScheduledRun(FrequencyDaily, startAt, 2)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.February, 2, 9, 30, 0, 0, time.UTC), next)
+
+	next, ok = NextScheduledRun(FrequencyWeekly, startAt, 1)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.February, 7, 9, 30, 0, 0, time.UTC), next)
+
+	// a monthly schedule is clamped to the end of shorter months without drifting afterwards
+	next, ok = NextScheduledRun(FrequencyMonthly, startAt, 1)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.February, 29, 9, 30, 0, 0, time.UTC), next)
+
+	next, ok = NextScheduledRun(FrequencyMonthly, startAt, 2)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.March, 31, 9, 30, 0, 0, time.UTC), next)
+
+	next, ok = NextScheduledRun(FrequencyMonthly, startAt, 12)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2025, time.January, 31, 9, 30, 0, 0, time.UTC), next)
+}
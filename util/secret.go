@@ -0,0 +1,27 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// secretTokenBytes is the entropy of a secret token, it is hex encoded to twice as many characters
+const secretTokenBytes = 32
+
+// NewSecretToken returns a random token for links that grant access on their own, such as password resets
+func NewSecretToken() (string, error) {
+	buf := make([]byte, secretTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashSecretToken is what gets stored of a secret token. Tokens are random enough that
+// a plain sha256 suffices, unlike passwords they don't need bcrypt
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretToken(t *testing.T) {
+	token1, err := NewSecretToken()
+	require.NoError(t, err)
+	require.Len(t, token1, 2*secretTokenBytes)
+
+	token2, err := NewSecretToken()
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+
+	hash := HashSecretToken(token1)
+	require.Len(t, hash, 64)
+	require.NotEqual(t, token1, hash)
+	require.Equal(t, hash, HashSecretToken(token1))
+	require.NotEqual(t, hash, HashSecretToken(token2))
+}
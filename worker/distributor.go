@@ -0,0 +1,68 @@
+// Package worker runs background tasks out of a postgres job table, so no extra infrastructure such as redis is needed.
+// Tasks are enqueued by a TaskDistributor, usually in the transaction of the change that triggers them,
+// and executed at least once by a TaskProcessor.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+)
+
+// defaultMaxAttempts is how often a task is tried before it is dead-lettered
+const defaultMaxAttempts = 10
+
+type TaskDistributor interface {
+	DistributeTaskSendVerifyEmail(ctx context.Context, q db.Querier, payload *PayloadSendVerifyEmail, opts ...Option) error
+	DistributeTaskSendResetPassword(ctx context.Context, q db.Querier, payload *PayloadSendResetPassword, opts ...Option) error
+}
+
+// Option changes how a task is enqueued
+type Option func(arg *db.CreateTaskParams)
+
+// MaxAttempts sets how often a task is tried before it is dead-lettered
+func MaxAttempts(attempts int32) Option {
+	return func(arg *db.CreateTaskParams) {
+		arg.MaxAttempts = attempts
+	}
+}
+
+// ProcessIn delays a task by delay
+func ProcessIn(delay time.Duration) Option {
+	return func(arg *db.CreateTaskParams) {
+		arg.RunAt = arg.RunAt.Add(delay)
+	}
+}
+
+type PostgresTaskDistributor struct{}
+
+func NewPostgresTaskDistributor() TaskDistributor {
+	return &PostgresTaskDistributor{}
+}
+
+// enqueue writes a task with q, pass the queries of an open transaction to enqueue it atomically with other changes
+func (distributor *PostgresTaskDistributor) enqueue(ctx context.Context, q db.Querier, taskType string, payload interface{}, opts []Option) (db.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return db.Task{}, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	arg := db.CreateTaskParams{
+		Type:        taskType,
+		Payload:     data,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+
+	task, err := q.CreateTask(ctx, arg)
+	if err != nil {
+		return task, fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	return task, nil
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributeTaskSendVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomOwner()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateTask(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+			require.Equal(t, TaskSendVerifyEmail, arg.Type)
+			require.Equal(t, int32(3), arg.MaxAttempts)
+			require.WithinDuration(t, time.Now().Add(time.Minute), arg.RunAt, time.Second)
+
+			var payload PayloadSendVerifyEmail
+			require.NoError(t, json.Unmarshal(arg.Payload, &payload))
+			require.Equal(t, username, payload.Username)
+			return db.Task{ID: 1, Type: arg.Type}, nil
+		})
+
+	distributor := NewPostgresTaskDistributor()
+	err := distributor.DistributeTaskSendVerifyEmail(
+		context.Background(),
+		store,
+		&PayloadSendVerifyEmail{Username: username},
+		MaxAttempts(3),
+		ProcessIn(time.Minute),
+	)
+	require.NoError(t, err)
+
+	store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).Return(db.Task{}, sql.ErrConnDone)
+	err = distributor.DistributeTaskSendVerifyEmail(context.Background(), store, &PayloadSendVerifyEmail{Username: username})
+	require.ErrorIs(t, err, sql.ErrConnDone)
+}
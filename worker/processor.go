@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const (
+	defaultConcurrency = 4
+	// pollInterval is how long an idle processor waits before it looks for due tasks again
+	pollInterval = time.Second
+	// taskLease is how long a task stays claimed. A task that is still running when its lease runs out,
+	// e.g. because its processor crashed, is picked up again
+	taskLease = 5 * time.Minute
+	// retryBackoff is the delay before the first retry, it doubles with every further attempt up to maxRetryDelay
+	retryBackoff  = 10 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// ErrSkipRetry makes a failed task go to the dead letters right away, for errors that retrying can't fix
+var ErrSkipRetry = errors.New("skip retry for the task")
+
+type TaskProcessor interface {
+	Start()
+	Shutdown(ctx context.Context) error
+	ProcessTaskSendVerifyEmail(ctx context.Context, task db.Task) error
+	ProcessTaskSendResetPassword(ctx context.Context, task db.Task) error
+}
+
+type PostgresTaskProcessor struct {
+	config      util.Config
+	store       db.Store
+	mailer      mail.Sender
+	concurrency int
+	handlers    map[string]func(ctx context.Context, task db.Task) error
+
+	wg sync.WaitGroup
+	// stopPolling stops claiming new tasks, cancelTasks aborts the tasks that are still running
+	stopPolling context.CancelFunc
+	cancelTasks context.CancelFunc
+	taskCtx     context.Context
+}
+
+func NewPostgresTaskProcessor(config util.Config, store db.Store, mailer mail.Sender) TaskProcessor {
+	processor := &PostgresTaskProcessor{
+		config:      config,
+		store:       store,
+		mailer:      mailer,
+		concurrency: defaultConcurrency,
+	}
+	processor.handlers = map[string]func(ctx context.Context, task db.Task) error{
+		TaskSendVerifyEmail:   processor.ProcessTaskSendVerifyEmail,
+		TaskSendResetPassword: processor.ProcessTaskSendResetPassword,
+	}
+	return processor
+}
+
+// Start runs the processor in the background until Shutdown is called
+func (processor *PostgresTaskProcessor) Start() {
+	var pollCtx context.Context
+	pollCtx, processor.stopPolling = context.WithCancel(context.Background())
+	processor.taskCtx, processor.cancelTasks = context.WithCancel(context.Background())
+
+	for i := 0; i < processor.concurrency; i++ {
+		processor.wg.Add(1)
+		go processor.poll(pollCtx)
+	}
+}
+
+// Shutdown stops claiming tasks and waits for the running ones to finish.
+// If ctx is done first the running tasks are canceled and retried later
+func (processor *PostgresTaskProcessor) Shutdown(ctx context.Context) error {
+	processor.stopPolling()
+
+	done := make(chan struct{})
+	go func() {
+		processor.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		processor.cancelTasks()
+		return nil
+	case <-ctx.Done():
+		processor.cancelTasks()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (processor *PostgresTaskProcessor) poll(ctx context.Context) {
+	defer processor.wg.Done()
+
+	for {
+		for ctx.Err() == nil {
+			processed, err := processor.processNext(ctx, time.Now())
+			if err != nil {
+				log.Printf("cannot process task %v", err)
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// processNext claims the task that has been due the longest and runs it.
+// It reports false if no task was due
+func (processor *PostgresTaskProcessor) processNext(ctx context.Context, now time.Time) (bool, error) {
+	task, err := processor.store.ClaimTask(ctx, db.ClaimTaskParams{
+		Now:        now,
+		LeaseUntil: now.Add(taskLease),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// tasks don't run with the polling context, so that Shutdown lets them finish
+	taskErr := processor.handle(processor.taskCtx, task)
+	return true, processor.recordResult(task, taskErr, time.Now())
+}
+
+func (processor *PostgresTaskProcessor) handle(ctx context.Context, task db.Task) error {
+	handler, ok := processor.handlers[task.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown task type %q", ErrSkipRetry, task.Type)
+	}
+	return handler(ctx, task)
+}
+
+// recordResult completes, retries or dead-letters a task. A task whose lease ran out and that has been claimed again
+// meanwhile is left to its new processor
+func (processor *PostgresTaskProcessor) recordResult(task db.Task, taskErr error, now time.Time) error {
+	// the outcome is recorded even while shutting down, otherwise the task would run again
+	ctx := context.Background()
+
+	var err error
+	switch {
+	case taskErr == nil:
+		_, err = processor.store.CompleteTask(ctx, db.CompleteTaskParams{
+			ID:       task.ID,
+			Attempts: task.Attempts,
+		})
+	case errors.Is(taskErr, ErrSkipRetry) || task.Attempts >= task.MaxAttempts:
+		log.Printf("task %d of type %s is dead after %d attempts: %v", task.ID, task.Type, task.Attempts, taskErr)
+		_, err = processor.store.DeadLetterTask(ctx, db.DeadLetterTaskParams{
+			ID:        task.ID,
+			Attempts:  task.Attempts,
+			LastError: taskErr.Error(),
+		})
+	default:
+		log.Printf("task %d of type %s failed (attempt %d): %v", task.ID, task.Type, task.Attempts, taskErr)
+		_, err = processor.store.RetryTask(ctx, db.RetryTaskParams{
+			ID:        task.ID,
+			Attempts:  task.Attempts,
+			RunAt:     now.Add(retryDelay(task.Attempts)),
+			LastError: taskErr.Error(),
+		})
+	}
+	return err
+}
+
+// retryDelay is the backoff after the given number of failed attempts
+func retryDelay(attempts int32) time.Duration {
+	delay := retryBackoff
+	for i := int32(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
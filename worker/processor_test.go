@@ -0,0 +1,262 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProcessor(store db.Store, mailer mail.Sender) *PostgresTaskProcessor {
+	config := util.Config{
+		VerifyEmailURL:   "http://localhost:8080/verify_email",
+		ResetPasswordURL: "http://localhost:3000/reset_password",
+	}
+	processor := NewPostgresTaskProcessor(config, store, mailer).(*PostgresTaskProcessor)
+	processor.taskCtx = context.Background()
+	return processor
+}
+
+func randomVerifyEmailTask(t *testing.T, username string) db.Task {
+	payload, err := json.Marshal(PayloadSendVerifyEmail{Username: username})
+	require.NoError(t, err)
+
+	return db.Task{
+		ID:          util.RandomInt(1, 1000),
+		Type:        TaskSendVerifyEmail,
+		Payload:     payload,
+		Status:      "pending",
+		Attempts:    1,
+		MaxAttempts: defaultMaxAttempts,
+	}
+}
+
+func TestProcessNext(t *testing.T) {
+	now := time.Now()
+	user := db.User{Username: util.RandomOwner(), Email: util.RandomEmail()}
+	task := randomVerifyEmailTask(t, user.Username)
+
+	lastAttempt := task
+	lastAttempt.Attempts = lastAttempt.MaxAttempts
+
+	unknown := task
+	unknown.Type = "task:unknown"
+
+	claim := db.ClaimTaskParams{Now: now, LeaseUntil: now.Add(taskLease)}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, processed bool, err error)
+	}{
+		{
+			name: "Completed",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(task, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmail{ID: 1}, nil)
+				store.EXPECT().
+					CompleteTask(gomock.Any(), gomock.Eq(db.CompleteTaskParams{ID: task.ID, Attempts: task.Attempts})).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.True(t, processed)
+			},
+		},
+		{
+			name: "Retried",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(task, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					RetryTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RetryTaskParams) (int64, error) {
+						require.Equal(t, task.ID, arg.ID)
+						require.Equal(t, task.Attempts, arg.Attempts)
+						require.WithinDuration(t, time.Now().Add(retryBackoff), arg.RunAt, time.Second)
+						require.Contains(t, arg.LastError, sql.ErrConnDone.Error())
+						return 1, nil
+					})
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.True(t, processed)
+			},
+		},
+		{
+			name: "DeadAfterLastAttempt",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(lastAttempt, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					DeadLetterTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.True(t, processed)
+			},
+		},
+		{
+			name: "DeadWithoutRetry",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(task, nil)
+				// retrying can't bring back a user that doesn't exist
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					DeadLetterTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.True(t, processed)
+			},
+		},
+		{
+			name: "UnknownType",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(unknown, nil)
+				store.EXPECT().
+					DeadLetterTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.DeadLetterTaskParams) (int64, error) {
+						require.Contains(t, arg.LastError, "unknown task type")
+						return 1, nil
+					})
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.True(t, processed)
+			},
+		},
+		{
+			name: "NoneDue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(db.Task{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.NoError(t, err)
+				require.False(t, processed)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClaimTask(gomock.Any(), gomock.Eq(claim)).Times(1).Return(db.Task{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, processed bool, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.False(t, processed)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			processed, err := newTestProcessor(store, mail.NewInMemorySender()).processNext(context.Background(), now)
+			tc.checkResponse(t, processed, err)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, retryBackoff, retryDelay(1))
+	require.Equal(t, 2*retryBackoff, retryDelay(2))
+	require.Equal(t, 8*retryBackoff, retryDelay(4))
+	require.Equal(t, maxRetryDelay, retryDelay(100))
+}
+
+func TestShutdownWaitsForRunningTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := randomVerifyEmailTask(t, util.RandomOwner())
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimTask(gomock.Any(), gomock.Any()).Return(task, nil).Times(1)
+	store.EXPECT().ClaimTask(gomock.Any(), gomock.Any()).Return(db.Task{}, sql.ErrNoRows).AnyTimes()
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, username string) (db.User, error) {
+			close(started)
+			<-release
+			return db.User{Username: username, IsEmailVerified: true}, ctx.Err()
+		})
+	// the task finished before the shutdown deadline, so it completes rather than being retried
+	store.EXPECT().CompleteTask(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+
+	processor := NewPostgresTaskProcessor(util.Config{}, store, mail.NewInMemorySender())
+	processor.Start()
+	<-started
+
+	shutdownErr := make(chan error)
+	go func() {
+		shutdownErr <- processor.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before the running task finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-shutdownErr)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := randomVerifyEmailTask(t, util.RandomOwner())
+	started := make(chan struct{})
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimTask(gomock.Any(), gomock.Any()).Return(task, nil).Times(1)
+	store.EXPECT().ClaimTask(gomock.Any(), gomock.Any()).Return(db.Task{}, sql.ErrNoRows).AnyTimes()
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, username string) (db.User, error) {
+			close(started)
+			<-ctx.Done()
+			return db.User{}, ctx.Err()
+		})
+	// the canceled task is retried later
+	store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+
+	processor := NewPostgresTaskProcessor(util.Config{}, store, mail.NewInMemorySender())
+	processor.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := processor.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const TaskSendResetPassword = "task:send_reset_password"
+
+// resetPasswordDuration is how long the link in a password reset email stays valid
+const resetPasswordDuration = time.Hour
+
+// PayloadSendResetPassword carries the email the reset was requested for, which may not belong to any user
+type PayloadSendResetPassword struct {
+	Email string `json:"email"`
+}
+
+func (distributor *PostgresTaskDistributor) DistributeTaskSendResetPassword(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendResetPassword,
+	opts ...Option,
+) error {
+	task, err := distributor.enqueue(ctx, q, TaskSendResetPassword, payload, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("enqueued task %d of type %s", task.ID, task.Type)
+	return nil
+}
+
+func (processor *PostgresTaskProcessor) ProcessTaskSendResetPassword(ctx context.Context, task db.Task) error {
+	var payload PayloadSendResetPassword
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal payload: %v", ErrSkipRetry, err)
+	}
+
+	user, err := processor.store.GetUserByEmail(ctx, payload.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// nobody is told whether the email is registered, so there is nothing to send
+			log.Printf("skipped task %d of type %s, no user has the email", task.ID, task.Type)
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	resetToken, err := util.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = processor.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: util.HashSecretToken(resetToken),
+		ExpiredAt: time.Now().Add(resetPasswordDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	err = processor.mailer.SendEmail(ctx, resetPasswordMessage(processor.config.ResetPasswordURL, user, resetToken))
+	if err != nil {
+		return fmt.Errorf("failed to send reset password email: %w", err)
+	}
+
+	log.Printf("processed task %d of type %s for user %s", task.ID, task.Type, user.Username)
+	return nil
+}
+
+func resetPasswordMessage(resetURL string, user db.User, resetToken string) mail.Message {
+	query := url.Values{}
+	query.Set("token", resetToken)
+	link := fmt.Sprintf("%s?%s", resetURL, query.Encode())
+
+	content := fmt.Sprintf(`Hello %s,<br/>
+We received a request to reset the password of your Simple Bank account.<br/>
+Please <a href="%s">click here</a> to choose a new password, the link expires in %d minutes.<br/>
+If you didn't ask for this, you can ignore this email.<br/>`,
+		html.EscapeString(user.FullName), html.EscapeString(link), int(resetPasswordDuration.Minutes()))
+
+	return mail.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your Simple Bank password",
+		Content: content,
+	}
+}
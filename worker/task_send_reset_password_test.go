@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"html"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessTaskSendResetPassword(t *testing.T) {
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomOwner(),
+		Email:    util.RandomEmail(),
+	}
+
+	payload, err := json.Marshal(PayloadSendResetPassword{Email: user.Email})
+	require.NoError(t, err)
+	task := db.Task{ID: 1, Type: TaskSendResetPassword, Payload: payload, Attempts: 1, MaxAttempts: defaultMaxAttempts}
+
+	t.Run("OK", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var tokenHash string
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+		store.EXPECT().
+			CreatePasswordReset(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(ctx context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+				require.Equal(t, user.Username, arg.Username)
+				require.WithinDuration(t, time.Now().Add(resetPasswordDuration), arg.ExpiredAt, time.Second)
+				tokenHash = arg.TokenHash
+				return db.PasswordReset{ID: 1, Username: arg.Username, TokenHash: arg.TokenHash}, nil
+			})
+
+		mailer := mail.NewInMemorySender()
+		err := newTestProcessor(store, mailer).ProcessTaskSendResetPassword(context.Background(), task)
+		require.NoError(t, err)
+
+		messages := mailer.Messages()
+		require.Len(t, messages, 1)
+		require.Equal(t, []string{user.Email}, messages[0].To)
+
+		// only the hash of the mailed token is stored
+		link := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(messages[0].Content)
+		require.Len(t, link, 2)
+		resetURL, err := url.Parse(html.UnescapeString(link[1]))
+		require.NoError(t, err)
+		resetToken := resetURL.Query().Get("token")
+		require.NotEmpty(t, resetToken)
+		require.NotEqual(t, resetToken, tokenHash)
+		require.Equal(t, util.HashSecretToken(resetToken), tokenHash)
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(db.User{}, sql.ErrNoRows)
+		store.EXPECT().CreatePasswordReset(gomock.Any(), gomock.Any()).Times(0)
+
+		mailer := mail.NewInMemorySender()
+		err := newTestProcessor(store, mailer).ProcessTaskSendResetPassword(context.Background(), task)
+		require.NoError(t, err)
+		require.Empty(t, mailer.Messages())
+	})
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"time"
+
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+)
+
+const TaskSendVerifyEmail = "task:send_verify_email"
+
+// verifyEmailDuration is how long the link in a verification email stays valid
+const verifyEmailDuration = 24 * time.Hour
+
+type PayloadSendVerifyEmail struct {
+	Username string `json:"username"`
+}
+
+func (distributor *PostgresTaskDistributor) DistributeTaskSendVerifyEmail(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendVerifyEmail,
+	opts ...Option,
+) error {
+	task, err := distributor.enqueue(ctx, q, TaskSendVerifyEmail, payload, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("enqueued task %d of type %s for user %s", task.ID, task.Type, payload.Username)
+	return nil
+}
+
+func (processor *PostgresTaskProcessor) ProcessTaskSendVerifyEmail(ctx context.Context, task db.Task) error {
+	var payload PayloadSendVerifyEmail
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal payload: %v", ErrSkipRetry, err)
+	}
+
+	user, err := processor.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: user %s doesn't exist", ErrSkipRetry, payload.Username)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.IsEmailVerified {
+		log.Printf("skipped task %d of type %s, user %s is already verified", task.ID, task.Type, user.Username)
+		return nil
+	}
+
+	// a retry sends a new code, the codes of failed attempts stay valid until they expire
+	verifyEmail, err := processor.store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: util.RandomString(32),
+		ExpiredAt:  time.Now().Add(verifyEmailDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create verify email: %w", err)
+	}
+
+	err = processor.mailer.SendEmail(ctx, verifyEmailMessage(processor.config.VerifyEmailURL, user, verifyEmail))
+	if err != nil {
+		return fmt.Errorf("failed to send verify email: %w", err)
+	}
+
+	log.Printf("processed task %d of type %s for user %s <%s>", task.ID, task.Type, user.Username, user.Email)
+	return nil
+}
+
+func verifyEmailMessage(verifyURL string, user db.User, verifyEmail db.VerifyEmail) mail.Message {
+	query := url.Values{}
+	query.Set("email_id", fmt.Sprint(verifyEmail.ID))
+	query.Set("secret_code", verifyEmail.SecretCode)
+	link := fmt.Sprintf("%s?%s", verifyURL, query.Encode())
+
+	content := fmt.Sprintf(`Hello %s,<br/>
+Thank you for registering with us!<br/>
+Please <a href="%s">click here</a> to verify your email address.<br/>
+The link expires in %d hours.<br/>`, html.EscapeString(user.FullName), html.EscapeString(link), int(verifyEmailDuration.Hours()))
+
+	return mail.Message{
+		To:      []string{user.Email},
+		Subject: "Welcome to Simple Bank",
+		Content: content,
+	}
+}
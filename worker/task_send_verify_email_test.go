@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/muditshukla3/simplebank/db/mock"
+	db "github.com/muditshukla3/simplebank/db/sqlc"
+	"github.com/muditshukla3/simplebank/mail"
+	"github.com/muditshukla3/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+type failingSender struct{}
+
+func (failingSender) SendEmail(ctx context.Context, message mail.Message) error {
+	return errors.New("smtp server is down")
+}
+
+func TestProcessTaskSendVerifyEmail(t *testing.T) {
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomOwner(),
+		Email:    util.RandomEmail(),
+	}
+	verified := user
+	verified.IsEmailVerified = true
+
+	testCases := []struct {
+		name          string
+		mailer        mail.Sender
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, mailer mail.Sender, err error)
+	}{
+		{
+			name:   "OK",
+			mailer: mail.NewInMemorySender(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateVerifyEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Email, arg.Email)
+						require.Len(t, arg.SecretCode, 32)
+						require.WithinDuration(t, time.Now().Add(verifyEmailDuration), arg.ExpiredAt, time.Second)
+						return db.VerifyEmail{
+							ID:         42,
+							Username:   arg.Username,
+							Email:      arg.Email,
+							SecretCode: arg.SecretCode,
+							ExpiredAt:  arg.ExpiredAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, mailer mail.Sender, err error) {
+				require.NoError(t, err)
+
+				messages := mailer.(*mail.InMemorySender).Messages()
+				require.Len(t, messages, 1)
+				require.Equal(t, []string{user.Email}, messages[0].To)
+
+				link := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(messages[0].Content)
+				require.Len(t, link, 2)
+				verifyURL, err := url.Parse(html.UnescapeString(link[1]))
+				require.NoError(t, err)
+				require.Equal(t, "/verify_email", verifyURL.Path)
+				require.Equal(t, "42", verifyURL.Query().Get("email_id"))
+				require.Len(t, verifyURL.Query().Get("secret_code"), 32)
+			},
+		},
+		{
+			name:   "AlreadyVerified",
+			mailer: mail.NewInMemorySender(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(verified, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, mailer mail.Sender, err error) {
+				require.NoError(t, err)
+				require.Empty(t, mailer.(*mail.InMemorySender).Messages())
+			},
+		},
+		{
+			name:   "SendFailed",
+			mailer: failingSender{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmail{ID: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, mailer mail.Sender, err error) {
+				// the task is retried
+				require.Error(t, err)
+				require.NotErrorIs(t, err, ErrSkipRetry)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			task := randomVerifyEmailTask(t, user.Username)
+			err := newTestProcessor(store, tc.mailer).ProcessTaskSendVerifyEmail(context.Background(), task)
+			tc.checkResponse(t, tc.mailer, err)
+		})
+	}
+}